@@ -0,0 +1,81 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tcglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	securityFSLogPath = "/sys/kernel/security/tpm0/binary_bios_measurements"
+	acpiTPM2TablePath = "/sys/firmware/acpi/tables/TPM2"
+)
+
+// acpiTPM2LogAreaOffset is the offset of the LAML field in the TPM2 ACPI table, which
+// is followed by the LASA field. See the "ACPI Table for TPM 2.0" section of the "TCG ACPI
+// Specification".
+const acpiTPM2LogAreaOffset = 64
+
+// readACPITPM2LogArea returns the physical address and length of the event log from the
+// Log Area Start Address (LASA) and Log Area Minimum Length (LAML) fields of the TPM2
+// ACPI table.
+func readACPITPM2LogArea() (base, length int64, err error) {
+	table, err := ioutil.ReadFile(acpiTPM2TablePath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(table) < 8 || string(table[:4]) != "TPM2" {
+		return 0, 0, errors.New("invalid TPM2 ACPI table")
+	}
+	tableLen := binary.LittleEndian.Uint32(table[4:])
+	if int64(tableLen) > int64(len(table)) {
+		return 0, 0, fmt.Errorf("invalid TPM2 ACPI table length (%d bytes, got %d bytes)", tableLen, len(table))
+	}
+	if tableLen < acpiTPM2LogAreaOffset+12 {
+		return 0, 0, errors.New("the TPM2 ACPI table does not contain a log area")
+	}
+
+	laml := binary.LittleEndian.Uint32(table[acpiTPM2LogAreaOffset:])
+	lasa := binary.LittleEndian.Uint64(table[acpiTPM2LogAreaOffset+4:])
+	if laml == 0 || lasa == 0 || lasa > 1<<63-1 {
+		return 0, 0, errors.New("the TPM2 ACPI table does not contain a log area")
+	}
+
+	return int64(lasa), int64(laml), nil
+}
+
+// OpenSystemLog reads the event log for the current boot. The log is read from
+// securityfs if the kernel exposes it there. If it doesn't, the location of the log
+// is obtained from the TPM2 ACPI table and it is read from physical memory as
+// described in ReadLogFromPhysicalMemory, which requires appropriate privileges.
+// See ReadLog for further details.
+func OpenSystemLog(options *LogOptions) (*Log, error) {
+	f, err := os.Open(securityFSLogPath)
+	switch {
+	case os.IsNotExist(err):
+		// Fall back to the log area from the ACPI table.
+	case err != nil:
+		return nil, err
+	default:
+		defer f.Close()
+		return ReadLog(f, options)
+	}
+
+	base, length, err := readACPITPM2LogArea()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot obtain log area from ACPI: %w", err)
+	}
+	return ReadLogFromPhysicalMemory(base, length, options)
+}
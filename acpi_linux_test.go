@@ -0,0 +1,126 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tcglog_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type acpiSuite struct {
+	restore []func()
+}
+
+var _ = Suite(&acpiSuite{})
+
+func (s *acpiSuite) SetUpTest(c *C) {
+	s.restore = nil
+}
+
+func (s *acpiSuite) TearDownTest(c *C) {
+	for _, fn := range s.restore {
+		fn()
+	}
+}
+
+// makeTPM2Table returns a TPM2 ACPI table with the specified log area. If length is
+// zero, the table doesn't contain the log area fields.
+func (s *acpiSuite) makeTPM2Table(base uint64, length uint32) []byte {
+	table := make([]byte, 52)
+	copy(table, "TPM2")
+	if length > 0 {
+		table = append(table, make([]byte, 12)...)
+		var buf [12]byte
+		binary.LittleEndian.PutUint32(buf[0:], length)
+		binary.LittleEndian.PutUint64(buf[4:], base)
+		table = append(table, buf[:]...)
+	}
+	binary.LittleEndian.PutUint32(table[4:], uint32(len(table)))
+	return table
+}
+
+func (s *acpiSuite) mockTPM2Table(c *C, table []byte) {
+	path := filepath.Join(c.MkDir(), "TPM2")
+	c.Assert(ioutil.WriteFile(path, table, 0600), IsNil)
+	s.restore = append(s.restore, MockACPITPM2TablePath(path))
+}
+
+func (s *acpiSuite) mockNoSecurityFSLog(c *C) {
+	s.restore = append(s.restore, MockSecurityFSLogPath(filepath.Join(c.MkDir(), "binary_bios_measurements")))
+}
+
+func (s *acpiSuite) TestOpenSystemLogFromSecurityFS(c *C) {
+	s.restore = append(s.restore, MockSecurityFSLogPath("testdata/binary_bios_measurements"))
+	s.restore = append(s.restore, MockACPITPM2TablePath(filepath.Join(c.MkDir(), "TPM2")))
+
+	log, err := OpenSystemLog(&LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Events, HasLen, 115)
+}
+
+func (s *acpiSuite) TestOpenSystemLogFromACPI(c *C) {
+	offset := 2*os.Getpagesize() + 123
+	data, restore := new(devmemSuite).mockDevMem(c, offset)
+	s.restore = append(s.restore, restore)
+	s.mockNoSecurityFSLog(c)
+	s.mockTPM2Table(c, s.makeTPM2Table(uint64(offset), uint32(len(data)+1024)))
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	log, err := OpenSystemLog(&LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec, Equals, expected.Spec)
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events {
+		c.Check(event.Equal(expected.Events[i]), Equals, true, Commentf("event %d", i))
+	}
+}
+
+func (s *acpiSuite) TestOpenSystemLogNoACPITable(c *C) {
+	s.mockNoSecurityFSLog(c)
+	s.restore = append(s.restore, MockACPITPM2TablePath(filepath.Join(c.MkDir(), "TPM2")))
+
+	_, err := OpenSystemLog(&LogOptions{})
+	c.Check(err, ErrorMatches, `cannot obtain log area from ACPI: open .*/TPM2: no such file or directory`)
+	c.Check(os.IsNotExist(err), Equals, false)
+}
+
+func (s *acpiSuite) TestOpenSystemLogNoLogArea(c *C) {
+	s.mockNoSecurityFSLog(c)
+	s.mockTPM2Table(c, s.makeTPM2Table(0, 0))
+
+	_, err := OpenSystemLog(&LogOptions{})
+	c.Check(err, ErrorMatches, `cannot obtain log area from ACPI: the TPM2 ACPI table does not contain a log area`)
+}
+
+func (s *acpiSuite) TestOpenSystemLogInvalidTable(c *C) {
+	s.mockNoSecurityFSLog(c)
+	table := s.makeTPM2Table(0x1000, 0x10000)
+	copy(table, "TCPA")
+	s.mockTPM2Table(c, table)
+
+	_, err := OpenSystemLog(&LogOptions{})
+	c.Check(err, ErrorMatches, `cannot obtain log area from ACPI: invalid TPM2 ACPI table`)
+}
+
+func (s *acpiSuite) TestOpenSystemLogTruncatedTable(c *C) {
+	s.mockNoSecurityFSLog(c)
+	table := s.makeTPM2Table(0x1000, 0x10000)
+	s.mockTPM2Table(c, table[:70])
+
+	_, err := OpenSystemLog(&LogOptions{})
+	c.Check(err, ErrorMatches, `cannot obtain log area from ACPI: invalid TPM2 ACPI table length \(76 bytes, got 70 bytes\)`)
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"github.com/canonical/go-tpm2"
+)
+
+// AllowedEvent describes an event that is permitted to appear in a log.
+type AllowedEvent struct {
+	PCRIndex  PCRIndex             // The PCR that the event is measured to
+	EventType EventType            // The type of the event
+	Algorithm tpm2.HashAlgorithmId // The digest algorithm of Digest
+	Digest    Digest               // The digest of the event for the bank associated with Algorithm
+}
+
+func (e *AllowedEvent) matches(event *Event) bool {
+	return event.PCRIndex == e.PCRIndex && event.EventType == e.EventType && event.Digests[e.Algorithm].Equal(e.Digest)
+}
+
+// LogCheckReport is the result of checking a log against an allowlist or against
+// the current values of EFI variables.
+type LogCheckReport struct {
+	// UnexpectedEvents contains the indices of events in the log that don't
+	// match any entry in the allowlist.
+	UnexpectedEvents []int
+
+	// MissingEntries contains the indices of entries in the allowlist that
+	// don't match any event in the log.
+	MissingEntries []int
+
+	// MismatchedEvents contains the indices of events in the log that measure
+	// data that doesn't match the supplied current value.
+	MismatchedEvents []int
+
+	// UncheckedEvents contains the indices of events in the log that couldn't
+	// be checked because no current value was supplied for them or because
+	// their data couldn't be decoded. These don't affect the result of Ok.
+	UncheckedEvents []int
+}
+
+// Ok indicates whether the check was successful. For a check against an
+// allowlist, this means that every event in the log is in the allowlist and
+// every entry in the allowlist is in the log. For a check against the current
+// values of EFI variables, this means that every checked event matches the
+// current value.
+func (r *LogCheckReport) Ok() bool {
+	return len(r.UnexpectedEvents) == 0 && len(r.MissingEntries) == 0 && len(r.MismatchedEvents) == 0
+}
+
+// CheckAgainstAllowlist checks the events in this log against the supplied
+// allowlist, and returns a report of the events that aren't in the allowlist and
+// the allowlist entries that don't appear in the log. Unlike VerifyAgainstRIM,
+// the order of events is not significant and an allowlist entry may match more
+// than one event. EV_NO_ACTION events are ignored because they aren't measured.
+func (l *Log) CheckAgainstAllowlist(allow []AllowedEvent) *LogCheckReport {
+	report := new(LogCheckReport)
+	seen := make([]bool, len(allow))
+
+	for i, event := range l.Events {
+		if event.EventType == EventTypeNoAction {
+			continue
+		}
+
+		allowed := false
+		for j := range allow {
+			if !allow[j].matches(event) {
+				continue
+			}
+			allowed = true
+			seen[j] = true
+		}
+		if !allowed {
+			report.UnexpectedEvents = append(report.UnexpectedEvents, i)
+		}
+	}
+
+	for i, s := range seen {
+		if !s {
+			report.MissingEntries = append(report.MissingEntries, i)
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"github.com/canonical/go-tpm2"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type allowlistSuite struct{}
+
+var _ = Suite(&allowlistSuite{})
+
+// makeAllowlist creates an allowlist containing the SHA-256 digest of every
+// measured event in the supplied log.
+func (s *allowlistSuite) makeAllowlist(log *Log) (out []AllowedEvent) {
+	for _, event := range log.Events {
+		if event.EventType == EventTypeNoAction {
+			continue
+		}
+		out = append(out, AllowedEvent{
+			PCRIndex:  event.PCRIndex,
+			EventType: event.EventType,
+			Algorithm: tpm2.HashAlgorithmSHA256,
+			Digest:    append(Digest(nil), event.Digests[tpm2.HashAlgorithmSHA256]...)})
+	}
+	return out
+}
+
+func (s *allowlistSuite) TestCheckAgainstAllowlistGood(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	report := log.CheckAgainstAllowlist(s.makeAllowlist(log))
+	c.Check(report.Ok(), Equals, true)
+	c.Check(report.UnexpectedEvents, HasLen, 0)
+	c.Check(report.MissingEntries, HasLen, 0)
+}
+
+func (s *allowlistSuite) TestCheckAgainstAllowlistUnexpectedEvent(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	allow := s.makeAllowlist(log)
+
+	// Remove the entry for the PCR 7 separator, which is the 9th entry.
+	c.Assert(allow[8].PCRIndex, Equals, PCRIndex(7))
+	c.Assert(allow[8].EventType, Equals, EventTypeSeparator)
+	allow = append(allow[:8], allow[9:]...)
+
+	report := log.CheckAgainstAllowlist(allow)
+	c.Check(report.Ok(), Equals, false)
+	c.Check(report.UnexpectedEvents, DeepEquals, []int{9})
+	c.Check(report.MissingEntries, HasLen, 0)
+}
+
+func (s *allowlistSuite) TestCheckAgainstAllowlistMissingEntry(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	allow := append(s.makeAllowlist(log), AllowedEvent{
+		PCRIndex:  7,
+		EventType: EventTypeEFIVariableAuthority,
+		Algorithm: tpm2.HashAlgorithmSHA256,
+		Digest:    make(Digest, 32)})
+
+	report := log.CheckAgainstAllowlist(allow)
+	c.Check(report.Ok(), Equals, false)
+	c.Check(report.UnexpectedEvents, HasLen, 0)
+	c.Check(report.MissingEntries, DeepEquals, []int{len(allow) - 1})
+}
+
+func (s *allowlistSuite) TestCheckAgainstAllowlistDigestMismatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	allow := s.makeAllowlist(log)
+	allow[8].Digest = make(Digest, 32)
+
+	report := log.CheckAgainstAllowlist(allow)
+	c.Check(report.UnexpectedEvents, DeepEquals, []int{9})
+	c.Check(report.MissingEntries, DeepEquals, []int{8})
+}
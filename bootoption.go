@@ -0,0 +1,148 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/canonical/go-efilib"
+	"golang.org/x/xerrors"
+)
+
+// BootOptionCheck is the result of correlating the first boot application measured
+// to PCR 4 with the boot options measured to PCR 1.
+type BootOptionCheck struct {
+	// ApplicationEvent is the index of the first EV_EFI_BOOT_SERVICES_APPLICATION
+	// event in PCR 4.
+	ApplicationEvent int
+
+	// ActiveOption is the name of the load option variable referenced by the first
+	// entry in BootOrder, eg, "Boot0001".
+	ActiveOption string
+
+	// MatchingOption is the name of the first load option variable, in boot order,
+	// with a device path that corresponds to the boot application. This is empty
+	// if none of the measured load options correspond to it.
+	MatchingOption string
+}
+
+// Ok indicates whether the boot application corresponds to the active boot option.
+// If this is false but MatchingOption is set, the platform booted a later entry in
+// BootOrder, which might be because booting the earlier entries failed.
+func (c *BootOptionCheck) Ok() bool {
+	return c.MatchingOption == c.ActiveOption
+}
+
+// devicePathMatchesLoadOption indicates whether the device path of a loaded image
+// corresponds to the device path of a load option. Load options may contain a short
+// form device path that begins with a hard drive or file path node, in which case it
+// matches the end of the full device path of the loaded image.
+func devicePathMatchesLoadOption(image, option efi.DevicePath) bool {
+	if len(option) == 0 {
+		return false
+	}
+	expected := option.String()
+	for i := range image {
+		if image[i:].String() == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckBootOption correlates the device path of the first EV_EFI_BOOT_SERVICES_APPLICATION
+// event in PCR 4 with the load options measured to PCR 1 by EV_EFI_VARIABLE_BOOT and
+// EV_EFI_VARIABLE_BOOT2 events. The first application loaded from a boot device is
+// expected to correspond to the boot option that was selected by the firmware, which
+// is normally the first entry in BootOrder. A mismatch might indicate that the boot
+// order was tampered with and a different application was launched than the one that
+// the boot option refers to.
+//
+// Note that load options that use device paths which can't be compared directly with
+// the device path of the loaded image, such as those that are expanded by the firmware
+// from a USB WWID or URI, won't match.
+//
+// An error is returned if the log doesn't contain the measurements required to
+// perform the check, or if any of them can't be decoded.
+func (l *Log) CheckBootOption() (*BootOptionCheck, error) {
+	vars := make(map[string][]byte)
+	for _, event := range l.Events {
+		if event.PCRIndex != 1 {
+			continue
+		}
+		switch event.EventType {
+		case EventTypeEFIVariableBoot, EventTypeEFIVariableBoot2:
+		default:
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok || data.VariableName != efi.GlobalVariable {
+			continue
+		}
+		if _, exists := vars[data.UnicodeName]; exists {
+			continue
+		}
+		vars[data.UnicodeName] = data.VariableData
+	}
+
+	bootOrderData, ok := vars["BootOrder"]
+	if !ok {
+		return nil, errors.New("the log does not contain a measurement of BootOrder")
+	}
+	if len(bootOrderData) == 0 || len(bootOrderData)%2 != 0 {
+		return nil, fmt.Errorf("invalid BootOrder length %d", len(bootOrderData))
+	}
+	bootOrder := make([]uint16, len(bootOrderData)/2)
+	for i := range bootOrder {
+		bootOrder[i] = binary.LittleEndian.Uint16(bootOrderData[i*2:])
+	}
+
+	out := &BootOptionCheck{ApplicationEvent: -1, ActiveOption: fmt.Sprintf("Boot%04X", bootOrder[0])}
+
+	var image efi.DevicePath
+	for i, event := range l.Events {
+		if event.PCRIndex != 4 || event.EventType != EventTypeEFIBootServicesApplication {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIImageLoadEvent)
+		if !ok {
+			return nil, fmt.Errorf("cannot decode the event data for event %d", i)
+		}
+		out.ApplicationEvent = i
+		image = data.DevicePath
+		break
+	}
+	if out.ApplicationEvent < 0 {
+		return nil, errors.New("the log does not contain any EV_EFI_BOOT_SERVICES_APPLICATION events in PCR 4")
+	}
+
+	if _, ok := vars[out.ActiveOption]; !ok {
+		return nil, fmt.Errorf("the log does not contain a measurement of %s", out.ActiveOption)
+	}
+
+	for _, n := range bootOrder {
+		name := fmt.Sprintf("Boot%04X", n)
+		data, ok := vars[name]
+		if !ok {
+			continue
+		}
+
+		option, err := efi.ReadLoadOption(bytes.NewReader(data))
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode %s: %w", name, err)
+		}
+		if devicePathMatchesLoadOption(image, option.FilePath) {
+			out.MatchingOption = name
+			break
+		}
+	}
+
+	return out, nil
+}
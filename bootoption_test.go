@@ -0,0 +1,91 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type bootoptionSuite struct{}
+
+var _ = Suite(&bootoptionSuite{})
+
+func (s *bootoptionSuite) TestCheckBootOption(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	check, err := log.CheckBootOption()
+	c.Assert(err, IsNil)
+	c.Check(check, DeepEquals, &BootOptionCheck{ApplicationEvent: 32, ActiveOption: "Boot0003", MatchingOption: "Boot0003"})
+	c.Check(check.Ok(), Equals, true)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionLaterEntry(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	// Make Windows Boot Manager the first entry in BootOrder.
+	data := log.Events[24].Data.(*EFIVariableData)
+	c.Assert(data.UnicodeName, Equals, "BootOrder")
+	data.VariableData = []byte{0x00, 0x00, 0x03, 0x00, 0x01, 0x00}
+
+	check, err := log.CheckBootOption()
+	c.Assert(err, IsNil)
+	c.Check(check, DeepEquals, &BootOptionCheck{ApplicationEvent: 32, ActiveOption: "Boot0000", MatchingOption: "Boot0003"})
+	c.Check(check.Ok(), Equals, false)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionNoMatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	// Replace the application with the one loaded by shim, which isn't referenced by
+	// any boot option.
+	log.Events[32].Data.(*EFIImageLoadEvent).DevicePath = log.Events[36].Data.(*EFIImageLoadEvent).DevicePath
+
+	check, err := log.CheckBootOption()
+	c.Assert(err, IsNil)
+	c.Check(check, DeepEquals, &BootOptionCheck{ApplicationEvent: 32, ActiveOption: "Boot0003"})
+	c.Check(check.Ok(), Equals, false)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionMissingBootOrder(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events[24].Data.(*EFIVariableData).UnicodeName = "BootOrde"
+
+	_, err := log.CheckBootOption()
+	c.Check(err, ErrorMatches, `the log does not contain a measurement of BootOrder`)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionMissingActiveOption(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events[24].Data.(*EFIVariableData).VariableData = []byte{0x05, 0x00, 0x03, 0x00}
+
+	_, err := log.CheckBootOption()
+	c.Check(err, ErrorMatches, `the log does not contain a measurement of Boot0005`)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionInvalidBootOrder(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events[24].Data.(*EFIVariableData).VariableData = []byte{0x03, 0x00, 0x00}
+
+	_, err := log.CheckBootOption()
+	c.Check(err, ErrorMatches, `invalid BootOrder length 3`)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionInvalidLoadOption(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events[25].Data.(*EFIVariableData).VariableData = []byte{0x01, 0x00}
+
+	_, err := log.CheckBootOption()
+	c.Check(err, ErrorMatches, `cannot decode Boot0003: .*`)
+}
+
+func (s *bootoptionSuite) TestCheckBootOptionLazy(c *C) {
+	log := readTestLog(c, &LogOptions{LazyData: true})
+
+	check, err := log.CheckBootOption()
+	c.Assert(err, IsNil)
+	c.Check(check, DeepEquals, &BootOptionCheck{ApplicationEvent: 32, ActiveOption: "Boot0003", MatchingOption: "Boot0003"})
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/xerrors"
+)
+
+var devMemPath = "/dev/mem"
+
+// ReadLogFromPhysicalMemory reads an event log from the region of physical memory that
+// starts at base and is length bytes long, by mapping /dev/mem. This is useful on systems
+// where the log isn't exposed via securityfs, in which case its location can be obtained
+// from the TPM2 or TCPA ACPI table. This requires appropriate privileges, and may not work
+// on kernels that restrict access to /dev/mem. See ReadLog for further details.
+func ReadLogFromPhysicalMemory(base, length int64, options *LogOptions) (*Log, error) {
+	if base < 0 || length <= 0 {
+		return nil, errors.New("invalid memory region")
+	}
+
+	f, err := os.Open(devMemPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// The offset passed to mmap must be page aligned.
+	pageSize := int64(os.Getpagesize())
+	offset := base &^ (pageSize - 1)
+
+	data, err := unix.Mmap(int(f.Fd()), offset, int(base-offset+length), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot map memory: %w", err)
+	}
+	defer unix.Munmap(data)
+
+	// ReadLog copies event data out of the supplied reader, so the returned
+	// log does not reference the mapping.
+	return ReadLog(bytes.NewReader(data[base-offset:]), options)
+}
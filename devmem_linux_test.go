@@ -0,0 +1,67 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tcglog_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type devmemSuite struct{}
+
+var _ = Suite(&devmemSuite{})
+
+func (s *devmemSuite) mockDevMem(c *C, offset int) (data []byte, restore func()) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	mem := new(bytes.Buffer)
+	mem.Write(make([]byte, offset))
+	mem.Write(data)
+	mem.Write(bytes.Repeat([]byte{0xff}, 4096))
+
+	path := filepath.Join(c.MkDir(), "mem")
+	c.Assert(ioutil.WriteFile(path, mem.Bytes(), 0600), IsNil)
+
+	return data, MockDevMemPath(path)
+}
+
+func (s *devmemSuite) testReadLogFromPhysicalMemory(c *C, offset int) {
+	data, restore := s.mockDevMem(c, offset)
+	defer restore()
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	log, err := ReadLogFromPhysicalMemory(int64(offset), int64(len(data)), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec, Equals, expected.Spec)
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events {
+		c.Check(event.Equal(expected.Events[i]), Equals, true, Commentf("event %d", i))
+	}
+}
+
+func (s *devmemSuite) TestReadLogFromPhysicalMemoryAligned(c *C) {
+	s.testReadLogFromPhysicalMemory(c, 2*os.Getpagesize())
+}
+
+func (s *devmemSuite) TestReadLogFromPhysicalMemoryUnaligned(c *C) {
+	s.testReadLogFromPhysicalMemory(c, os.Getpagesize()+123)
+}
+
+func (s *devmemSuite) TestReadLogFromPhysicalMemoryInvalidRegion(c *C) {
+	_, err := ReadLogFromPhysicalMemory(0, 0, &LogOptions{})
+	c.Check(err, ErrorMatches, `invalid memory region`)
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/canonical/go-tpm2"
 
@@ -18,6 +19,72 @@ import (
 	"github.com/canonical/tcglog-parser/internal/ioerr"
 )
 
+// ErrTruncated is returned when an event declares more data than is available.
+// Errors wrapping this also wrap io.ErrUnexpectedEOF when they result from reaching
+// the end of the log early.
+var ErrTruncated = errors.New("event is truncated")
+
+// truncatedError indicates that an event was truncated.
+type truncatedError struct {
+	err error
+}
+
+func (e *truncatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *truncatedError) Unwrap() error {
+	return e.err
+}
+
+func (e *truncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+// eventTruncatedIfUnexpectedEOF converts io.EOF errors into io.ErrUnexpectedEOF,
+// and marks errors that indicate that the end of the log was reached early as
+// being a result of a truncated event.
+func eventTruncatedIfUnexpectedEOF(args ...interface{}) error {
+	err := ioerr.EOFIsUnexpected(args...)
+	if !xerrors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	return &truncatedError{err}
+}
+
+// maxEventDataPrealloc is the maximum amount of memory that will be allocated
+// for event data before it has been read. This prevents a log from triggering
+// excessive allocations by declaring a large event size.
+const maxEventDataPrealloc = 64 * 1024
+
+// readEventRecord reads event data of the specified size from r, and returns the
+// complete event record consisting of the supplied prefix followed by the event
+// data. The event data is the part of the returned slice after the prefix. A size
+// of zero is valid, and the parser still advances because the header has already
+// been consumed from r.
+func readEventRecord(r io.Reader, prefix []byte, size uint32) ([]byte, error) {
+	if size <= maxEventDataPrealloc {
+		record := make([]byte, len(prefix)+int(size))
+		copy(record, prefix)
+		if _, err := io.ReadFull(r, record[len(prefix):]); err != nil {
+			return nil, eventTruncatedIfUnexpectedEOF(err)
+		}
+		return record, nil
+	}
+
+	// Grow the buffer as data is read instead.
+	buf := bytes.NewBuffer(make([]byte, 0, len(prefix)+maxEventDataPrealloc))
+	buf.Write(prefix)
+	n, err := io.CopyN(buf, r, int64(size))
+	switch {
+	case err == io.EOF:
+		return nil, &truncatedError{xerrors.Errorf("event data is %d bytes, but only %d bytes remain: %w", size, n, io.ErrUnexpectedEOF)}
+	case err != nil:
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 type eventHeader struct {
 	PCRIndex  PCRIndex
 	EventType EventType
@@ -28,12 +95,313 @@ type eventHeaderCryptoAgile struct {
 	Count uint32
 }
 
+// endOfLogPCRIndex is the PCR index conventionally used to mark the end of the
+// events in a log buffer.
+const endOfLogPCRIndex PCRIndex = 0xffffffff
+
+// isPadding indicates whether this crypto-agile header is part of a padding region
+// after the last event in a log buffer, which some firmware fills with 0xff or 0x00
+// bytes. An all-zero header can't be a valid crypto-agile event, as it must contain
+// at least one digest. This must not be used for events in the TPM 1.2 format, which
+// don't have a digest count - see isPaddingEvent for those.
+func (h *eventHeaderCryptoAgile) isPadding() bool {
+	if h.PCRIndex == endOfLogPCRIndex {
+		return true
+	}
+	return h.PCRIndex == 0 && h.EventType == EventTypePrebootCert && h.Count == 0
+}
+
+// isPaddingEvent indicates whether the supplied event in the TPM 1.2 format is
+// part of a padding region of 0x00 bytes after the last event in a log buffer,
+// which is the case if every field is zero.
+func isPaddingEvent(header eventHeader, digest Digest, size uint32) bool {
+	if header.PCRIndex != 0 || header.EventType != EventTypePrebootCert || size != 0 {
+		return false
+	}
+	for _, b := range digest {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // Event corresponds to a single event in an event log.
+//
+// For crypto-agile logs, Digests contains an entry for every algorithm listed in the
+// spec ID event, including algorithms that aren't known to this package. These are
+// carried as raw bytes of the size declared in the spec ID event, and are omitted
+// from Log.Algorithms.
 type Event struct {
 	PCRIndex  PCRIndex  // PCR index to which this event was measured
 	EventType EventType // The type of this event
 	Digests   DigestMap // The digests corresponding to this event for the supported algorithms
 	Data      EventData // The data recorded with this event
+
+	raw []byte
+}
+
+// RawBytes returns the serialized form of this event exactly as it appeared in the
+// log it was read from, which is a TCG_PCR_EVENT2 structure for crypto-agile logs
+// and a TCG_PCR_EVENT structure otherwise. The event data returned from Data.Bytes()
+// shares memory with the returned slice, which must not be modified. This returns
+// nil for events that weren't read from a log.
+func (e *Event) RawBytes() []byte {
+	return e.raw
+}
+
+// DecodedData returns the decoded form of the event data. If the log was read with
+// LogOptions.LazyData set, the data is decoded on the first call. Otherwise, this
+// returns Data. Callers that type-assert the event data should use this rather than
+// Data so that they work regardless of whether the log was read with LazyData.
+func (e *Event) DecodedData() EventData {
+	return resolveEventData(e.Data)
+}
+
+// OpaqueData returns the event data along with the type of this event if the event
+// data could not be decoded by this package and is OpaqueEventData. This returns
+// nil for any other event data.
+func (e *Event) OpaqueData() *TypedOpaqueEventData {
+	data, ok := e.DecodedData().(OpaqueEventData)
+	if !ok {
+		return nil
+	}
+	return &TypedOpaqueEventData{OpaqueEventData: data, EventType: e.EventType}
+}
+
+// ActionData returns a description of the action string measured by this event if
+// it is an EV_ACTION event, which identifies the actions that are defined by the TCG
+// specifications. This returns nil for any other event.
+func (e *Event) ActionData() *ActionEventData {
+	if e.EventType != EventTypeAction {
+		return nil
+	}
+	data, ok := e.DecodedData().(StringEventData)
+	if !ok {
+		return nil
+	}
+	return newActionEventData(data.Bytes())
+}
+
+// Category returns a short human readable description of the part of the boot
+// process that this event belongs to, such as "Firmware", "Bootloader" or
+// "Kernel". It is derived from the PCR index, event type and event data, based on
+// the conventional usage of each PCR, and is intended for presenting a log to
+// users rather than for making security decisions.
+func (e *Event) Category() string {
+	switch e.EventType {
+	case EventTypeNoAction:
+		return "Log metadata"
+	case EventTypeSeparator:
+		return "Separator"
+	}
+
+	switch e.PCRIndex {
+	case 0:
+		return "Firmware"
+	case 1, 3:
+		return "Firmware configuration"
+	case 2:
+		return "Option ROMs and drivers"
+	case 4:
+		switch e.EventType {
+		case EventTypeIPL, EventTypeEFIBootServicesApplication:
+			return "Bootloader"
+		default:
+			return "Boot manager"
+		}
+	case 5:
+		if e.EventType == EventTypeEFIGPTEvent || e.EventType == EventTypeIPLPartitionData {
+			return "Partition table"
+		}
+		return "Boot configuration"
+	case 6:
+		return "Platform manufacturer"
+	case 7:
+		return "Secure boot policy"
+	case 8, 9:
+		switch d := resolveEventData(e.Data).(type) {
+		case *GrubStringEventData:
+			if d.Type == KernelCmdline {
+				return "Kernel"
+			}
+			return "Bootloader"
+		case *SystemdEFIStubCommandline:
+			return "Kernel"
+		}
+		if e.PCRIndex == 9 {
+			return "Loaded files"
+		}
+		return "Operating system loader"
+	case 10, 11, 12, 13, 14, 15:
+		return "Operating system"
+	default:
+		return "Other"
+	}
+}
+
+// PCRString returns the PCR index of this event in the form "PCRnn".
+func (e *Event) PCRString() string {
+	return fmt.Sprintf("PCR%02d", e.PCRIndex)
+}
+
+// Summary returns a compact, single line description of this event, containing the
+// PCR index, the event type, an abbreviated digest for the strongest algorithm and a
+// description of the event data. eg:
+//
+//	PCR07 EV_EFI_VARIABLE_AUTHORITY sha256:96c30ea6... UEFI_VARIABLE_DATA{ ... }
+func (e *Event) Summary() string {
+	var alg tpm2.HashAlgorithmId
+	for a := range e.Digests {
+		if !a.IsValid() {
+			continue
+		}
+		if !alg.IsValid() || a.Size() > alg.Size() {
+			alg = a
+		}
+	}
+
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "%s %s", e.PCRString(), e.EventType)
+	if alg.IsValid() {
+		digest := e.Digests[alg]
+		if len(digest) > 4 {
+			digest = digest[:4]
+		}
+		fmt.Fprintf(&builder, " %s:%x...", strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%v", alg), "TPM_ALG_")), digest)
+	}
+	if e.Data != nil {
+		if data := strings.Join(strings.Fields(e.Data.String()), " "); data != "" {
+			fmt.Fprintf(&builder, " %s", data)
+		}
+	}
+	return builder.String()
+}
+
+// IsPreOSOnly indicates whether this event can only be measured in the pre-OS phase,
+// and so must precede the EV_SEPARATOR event measured to the same PCR. This is a
+// conservative test that only returns true for events that are measured by the
+// platform's core root of trust or for the secure boot configuration - some firmware
+// implementations measure other firmware events after the separators.
+func (e *Event) IsPreOSOnly() bool {
+	switch e.EventType {
+	case EventTypeSCRTMContents, EventTypeSCRTMVersion, EventTypePostCode, EventTypeCPUMicrocode, EventTypeEFIHCRTMEvent:
+		return true
+	case EventTypeEFIPlatformFirmwareBlob, EventTypeEFIPlatformFirmwareBlob2:
+		return e.PCRIndex == 0
+	case EventTypeEFIVariableDriverConfig:
+		return e.PCRIndex == 7
+	default:
+		return false
+	}
+}
+
+// DevicePathString returns the formatted device path of the image associated with
+// this event, if it is an EV_EFI_BOOT_SERVICES_APPLICATION, EV_EFI_BOOT_SERVICES_DRIVER
+// or EV_EFI_RUNTIME_SERVICES_DRIVER event with event data that was decoded
+// successfully. Otherwise, this returns false.
+func (e *Event) DevicePathString() (string, bool) {
+	switch e.EventType {
+	case EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver:
+	default:
+		return "", false
+	}
+
+	data, ok := resolveEventData(e.Data).(*EFIImageLoadEvent)
+	if !ok || data.DevicePath == nil {
+		return "", false
+	}
+	return data.DevicePath.String(), true
+}
+
+// HasBank indicates whether this event has a digest for the specified algorithm,
+// with the size expected for that algorithm.
+func (e *Event) HasBank(alg tpm2.HashAlgorithmId) bool {
+	digest, ok := e.Digests[alg]
+	if !ok {
+		return false
+	}
+	return alg.IsValid() && len(digest) == alg.Size()
+}
+
+// Digest returns the digest recorded in this event for the specified algorithm, and
+// whether this event has a digest for it. Unlike HasBank, this doesn't check that the
+// digest has the size expected for the algorithm, so it can be used to obtain digests
+// for algorithms that aren't known to this package.
+func (e *Event) Digest(alg tpm2.HashAlgorithmId) (Digest, bool) {
+	digest, ok := e.Digests[alg]
+	return digest, ok
+}
+
+// Equal indicates whether this event is equal to other. Events are equal if
+// they have the same PCR index, event type, set of digests and serialized
+// event data. Events with event data that cannot be serialized are not
+// equal.
+func (e *Event) Equal(other *Event) bool {
+	switch {
+	case e == nil || other == nil:
+		return e == other
+	case e.PCRIndex != other.PCRIndex:
+		return false
+	case e.EventType != other.EventType:
+		return false
+	case !e.Digests.Equal(other.Digests):
+		return false
+	case (e.Data == nil) != (other.Data == nil):
+		return false
+	case e.Data == nil:
+		return true
+	default:
+		a := new(bytes.Buffer)
+		if err := e.Data.Write(a); err != nil {
+			return false
+		}
+		b := new(bytes.Buffer)
+		if err := other.Data.Write(b); err != nil {
+			return false
+		}
+		return bytes.Equal(a.Bytes(), b.Bytes())
+	}
+}
+
+// EquivalentTo indicates whether this event represents the same measurement as other,
+// which may have been read from a log with a different set of digest algorithms, such
+// as a log from a machine with a different TPM bank configuration. Events are
+// equivalent if they have the same PCR index and event type, and they have equal
+// digests for every algorithm that they both have a digest for. If the events don't
+// have any digest algorithms in common, they are equivalent if their decoded event
+// data is equal.
+func (e *Event) EquivalentTo(other *Event) bool {
+	switch {
+	case e == nil || other == nil:
+		return e == other
+	case e.PCRIndex != other.PCRIndex:
+		return false
+	case e.EventType != other.EventType:
+		return false
+	}
+
+	common := false
+	for alg, digest := range e.Digests {
+		otherDigest, ok := other.Digests[alg]
+		if !ok {
+			continue
+		}
+		common = true
+		if !digest.Equal(otherDigest) {
+			return false
+		}
+	}
+	if common {
+		return true
+	}
+
+	switch {
+	case e.Data == nil || other.Data == nil:
+		return e.Data == nil && other.Data == nil
+	default:
+		return e.Data.Equal(other.Data)
+	}
 }
 
 // Write serializes this event in non crypto-agile form to w. If the event
@@ -126,11 +494,108 @@ func isPCRIndexInRange(index PCRIndex) bool {
 	return index <= maxPCRIndex
 }
 
+// eventDataDecoder is a function that produces event data from the supplied
+// raw event data and event fields.
+type eventDataDecoder func(data []byte, pcrIndex PCRIndex, eventType EventType, digests DigestMap) EventData
+
+// eagerEventDataDecoder returns a decoder that decodes event data immediately,
+// regardless of whether LazyData is set.
+func (o *LogOptions) eagerEventDataDecoder() eventDataDecoder {
+	return func(data []byte, pcrIndex PCRIndex, eventType EventType, digests DigestMap) EventData {
+		return decodeEventData(data, pcrIndex, eventType, digests, o)
+	}
+}
+
+func (o *LogOptions) eventDataDecoder() eventDataDecoder {
+	if o.LazyData {
+		return func(data []byte, pcrIndex PCRIndex, eventType EventType, digests DigestMap) EventData {
+			return &LazyEventData{
+				rawEventData: data,
+				pcrIndex:     pcrIndex,
+				eventType:    eventType,
+				digests:      digests,
+				options:      o}
+		}
+	}
+	return o.eagerEventDataDecoder()
+}
+
+func decodeEventDataRaw(data []byte, _ PCRIndex, _ EventType, _ DigestMap) EventData {
+	return OpaqueEventData(data)
+}
+
 func ReadEvent(r io.Reader, options *LogOptions) (*Event, error) {
-	var header eventHeader
-	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+	return readEvent(r, options.eventDataDecoder())
+}
+
+func readEvent(r io.Reader, decode eventDataDecoder) (*Event, error) {
+	return new(eventReader).readEvent(r, decode)
+}
+
+func ReadEventCryptoAgile(r io.Reader, digestSizes []EFISpecIdEventAlgorithmSize, options *LogOptions) (*Event, error) {
+	if err := checkDigestSizes(digestSizes); err != nil {
 		return nil, err
 	}
+	return readEventCryptoAgile(r, digestSizes, options.eventDataDecoder())
+}
+
+func readEventCryptoAgile(r io.Reader, digestSizes []EFISpecIdEventAlgorithmSize, decode eventDataDecoder) (*Event, error) {
+	return new(eventReader).readEventCryptoAgile(r, digestSizes, decode)
+}
+
+// eventReader reads events from a log. It uses a scratch buffer to decode the
+// fixed size fields of each event, so that a single instance can read many events
+// without allocating for each field. The bytes that precede the event data in the
+// current event are accumulated in prefix so that the raw event can be retained.
+type eventReader struct {
+	scratch [12]byte
+	prefix  []byte
+}
+
+func (er *eventReader) readHeader(r io.Reader, cryptoAgile bool) (hdr eventHeaderCryptoAgile, err error) {
+	buf := er.scratch[:8]
+	if cryptoAgile {
+		buf = er.scratch[:12]
+	}
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = &truncatedError{err}
+		}
+		return hdr, err
+	}
+	er.prefix = append(er.prefix[:0], buf...)
+	hdr.PCRIndex = PCRIndex(binary.LittleEndian.Uint32(buf[0:]))
+	hdr.EventType = EventType(binary.LittleEndian.Uint32(buf[4:]))
+	if cryptoAgile {
+		hdr.Count = binary.LittleEndian.Uint32(buf[8:])
+	}
+	return hdr, nil
+}
+
+func (er *eventReader) readUint16(r io.Reader) (uint16, error) {
+	if _, err := io.ReadFull(r, er.scratch[:2]); err != nil {
+		return 0, err
+	}
+	er.prefix = append(er.prefix, er.scratch[:2]...)
+	return binary.LittleEndian.Uint16(er.scratch[:]), nil
+}
+
+func (er *eventReader) readUint32(r io.Reader) (uint32, error) {
+	if _, err := io.ReadFull(r, er.scratch[:4]); err != nil {
+		return 0, err
+	}
+	er.prefix = append(er.prefix, er.scratch[:4]...)
+	return binary.LittleEndian.Uint32(er.scratch[:]), nil
+}
+
+func (er *eventReader) readEvent(r io.Reader, decode eventDataDecoder) (*Event, error) {
+	header, err := er.readHeader(r, false)
+	if err != nil {
+		return nil, err
+	}
+	if header.PCRIndex == endOfLogPCRIndex {
+		return nil, io.EOF
+	}
 
 	if !isPCRIndexInRange(header.PCRIndex) {
 		return nil, fmt.Errorf("log entry has an out-of-range PCR index (%d)", header.PCRIndex)
@@ -138,46 +603,62 @@ func ReadEvent(r io.Reader, options *LogOptions) (*Event, error) {
 
 	digest := make(Digest, tpm2.HashAlgorithmSHA1.Size())
 	if _, err := io.ReadFull(r, digest); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, eventTruncatedIfUnexpectedEOF(err)
 	}
+	er.prefix = append(er.prefix, digest...)
 	digests := make(DigestMap)
 	digests[tpm2.HashAlgorithmSHA1] = digest
 
-	var eventSize uint32
-	if err := binary.Read(r, binary.LittleEndian, &eventSize); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+	eventSize, err := er.readUint32(r)
+	if err != nil {
+		return nil, eventTruncatedIfUnexpectedEOF(err)
+	}
+	if isPaddingEvent(header.eventHeader, digest, eventSize) {
+		return nil, io.EOF
 	}
 
-	event := make([]byte, eventSize)
-	if _, err := io.ReadFull(r, event); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+	record, err := readEventRecord(r, er.prefix, eventSize)
+	if err != nil {
+		return nil, err
 	}
+	event := record[len(er.prefix):]
 
 	return &Event{
 		PCRIndex:  header.PCRIndex,
 		EventType: header.EventType,
 		Digests:   digests,
-		Data:      decodeEventData(event, header.PCRIndex, header.EventType, digests, options),
+		Data:      decode(event, header.PCRIndex, header.EventType, digests),
+		raw:       record,
 	}, nil
 }
 
-func ReadEventCryptoAgile(r io.Reader, digestSizes []EFISpecIdEventAlgorithmSize, options *LogOptions) (*Event, error) {
-	var header eventHeaderCryptoAgile
-	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+func (er *eventReader) readEventCryptoAgile(r io.Reader, digestSizes []EFISpecIdEventAlgorithmSize, decode eventDataDecoder) (*Event, error) {
+	header, err := er.readHeader(r, true)
+	if err != nil {
 		return nil, err
 	}
+	if header.isPadding() {
+		return nil, io.EOF
+	}
 
 	if !isPCRIndexInRange(header.PCRIndex) {
 		return nil, fmt.Errorf("log entry has an out-of-range PCR index (%d)", header.PCRIndex)
 	}
 
+	// Each algorithm must appear exactly once, so don't attempt to read more digests
+	// than the log declares algorithms for.
+	if header.Count > uint32(len(digestSizes)) {
+		return nil, fmt.Errorf("event contains more digests (%d) than the log declares algorithms for (%d)", header.Count, len(digestSizes))
+	}
+
 	digests := make(DigestMap)
 
 	for i := uint32(0); i < header.Count; i++ {
-		var algorithmId tpm2.HashAlgorithmId
-		if err := binary.Read(r, binary.LittleEndian, &algorithmId); err != nil {
-			return nil, ioerr.EOFIsUnexpected(err)
+		a, err := er.readUint16(r)
+		if err != nil {
+			return nil, eventTruncatedIfUnexpectedEOF(err)
 		}
+		algorithmId := tpm2.HashAlgorithmId(a)
 
 		var digestSize uint16
 		var j int
@@ -194,8 +675,9 @@ func ReadEventCryptoAgile(r io.Reader, digestSizes []EFISpecIdEventAlgorithmSize
 
 		digest := make(Digest, digestSize)
 		if _, err := io.ReadFull(r, digest); err != nil {
-			return nil, ioerr.EOFIsUnexpected("cannot read digest for algorithm %v: %w", algorithmId, err)
+			return nil, eventTruncatedIfUnexpectedEOF("cannot read digest for algorithm %v: %w", algorithmId, err)
 		}
+		er.prefix = append(er.prefix, digest...)
 
 		if _, exists := digests[algorithmId]; exists {
 			return nil, fmt.Errorf("event contains more than one digest value for algorithm %v", algorithmId)
@@ -209,27 +691,22 @@ func ReadEventCryptoAgile(r io.Reader, digestSizes []EFISpecIdEventAlgorithmSize
 		}
 	}
 
-	for alg, _ := range digests {
-		if alg.IsValid() {
-			continue
-		}
-		delete(digests, alg)
-	}
-
-	var eventSize uint32
-	if err := binary.Read(r, binary.LittleEndian, &eventSize); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+	eventSize, err := er.readUint32(r)
+	if err != nil {
+		return nil, eventTruncatedIfUnexpectedEOF(err)
 	}
 
-	event := make([]byte, eventSize)
-	if _, err := io.ReadFull(r, event); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+	record, err := readEventRecord(r, er.prefix, eventSize)
+	if err != nil {
+		return nil, err
 	}
+	event := record[len(er.prefix):]
 
 	return &Event{
 		PCRIndex:  header.PCRIndex,
 		EventType: header.EventType,
 		Digests:   digests,
-		Data:      decodeEventData(event, header.PCRIndex, header.EventType, digests, options),
+		Data:      decode(event, header.PCRIndex, header.EventType, digests),
+		raw:       record,
 	}, nil
 }
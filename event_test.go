@@ -7,10 +7,16 @@ package tcglog_test
 import (
 	"bytes"
 	"crypto"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
 
 	"github.com/canonical/go-efilib"
 	"github.com/canonical/go-tpm2"
 
+	"golang.org/x/xerrors"
+
 	. "gopkg.in/check.v1"
 
 	. "github.com/canonical/tcglog-parser"
@@ -108,3 +114,426 @@ func (s *eventSuite) TestReadEventiCryptoAgile(c *C) {
 	c.Check(data.UnicodeName, Equals, "BootOrder")
 	c.Check(data.VariableData, DeepEquals, []byte{0x03, 0x00, 0x00, 0x00, 0x01, 0x00})
 }
+
+func (s *eventSuite) TestReadEventCryptoAgileInvalidDigestSize(c *C) {
+	_, err := ReadEventCryptoAgile(
+		bytes.NewReader(decodeHexString(c, "01000000020000800200000004005fa6e9a74105c1e2297cce17c68288c84a8bda070b009d0689"+
+			"e46d7c710571256af5b8e8638f0dbc6b008f5ea4688c1c70f3005943e43800000061dfe48bca93d211aa0d00e098032b8c09000000000000000600000000000"+
+			"00042006f006f0074004f007200640065007200030000000100")),
+		[]EFISpecIdEventAlgorithmSize{
+			{AlgorithmId: tpm2.HashAlgorithmSHA1, DigestSize: uint16(tpm2.HashAlgorithmSHA1.Size())},
+			{AlgorithmId: tpm2.HashAlgorithmSHA256, DigestSize: uint16(tpm2.HashAlgorithmSHA1.Size())}},
+		&LogOptions{})
+	c.Check(err, ErrorMatches, `digest size for algorithm TPM_ALG_SHA256 \(20\) does not match the expected size \(32\)`)
+}
+
+func (s *eventSuite) TestEventEqual(c *C) {
+	newEvent := func() *Event {
+		return &Event{
+			PCRIndex:  7,
+			EventType: EventTypeSeparator,
+			Digests: DigestMap{
+				tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+				tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+			Data: &SeparatorEventData{Value: SeparatorEventNormalValue}}
+	}
+
+	a := newEvent()
+	c.Check(a.Equal(newEvent()), Equals, true)
+	c.Check(a.Equal(a), Equals, true)
+	c.Check(a.Equal(nil), Equals, false)
+	c.Check((*Event)(nil).Equal(nil), Equals, true)
+
+	b := newEvent()
+	b.PCRIndex = 4
+	c.Check(a.Equal(b), Equals, false)
+
+	b = newEvent()
+	b.EventType = EventTypeAction
+	c.Check(a.Equal(b), Equals, false)
+
+	b = newEvent()
+	delete(b.Digests, tpm2.HashAlgorithmSHA256)
+	c.Check(a.Equal(b), Equals, false)
+
+	b = newEvent()
+	b.Digests[tpm2.HashAlgorithmSHA1][0] = 1
+	c.Check(a.Equal(b), Equals, false)
+
+	b = newEvent()
+	b.Data = &SeparatorEventData{Value: SeparatorEventErrorValue}
+	c.Check(a.Equal(b), Equals, false)
+
+	b = newEvent()
+	b.Data = nil
+	c.Check(a.Equal(b), Equals, false)
+}
+
+func (s *eventSuite) TestReadEventCryptoAgileUnknownAlgorithm(c *C) {
+	digestSizes := []EFISpecIdEventAlgorithmSize{
+		{AlgorithmId: tpm2.HashAlgorithmSHA256, DigestSize: uint16(tpm2.HashAlgorithmSHA256.Size())},
+		{AlgorithmId: 0x1234, DigestSize: 8}}
+
+	event := &Event{
+		PCRIndex:  7,
+		EventType: EventTypeSeparator,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA256: decodeHexString(c, "df3f619804a92fdb4057192dc43dd748ea778adc52bc498ce80524c014b81119"),
+			0x1234:                   decodeHexString(c, "0102030405060708")},
+		Data: &SeparatorEventData{Value: SeparatorEventNormalValue}}
+
+	w := new(bytes.Buffer)
+	c.Check(event.WriteCryptoAgile(w, digestSizes), IsNil)
+	b := w.Bytes()
+
+	event, err := ReadEventCryptoAgile(bytes.NewReader(b), digestSizes, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(event.Digests, DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA256: decodeHexString(c, "df3f619804a92fdb4057192dc43dd748ea778adc52bc498ce80524c014b81119"),
+		0x1234:                   decodeHexString(c, "0102030405060708")})
+
+	data, ok := event.Data.(*SeparatorEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Value, Equals, uint32(SeparatorEventNormalValue))
+
+	w = new(bytes.Buffer)
+	c.Check(event.WriteCryptoAgile(w, digestSizes), IsNil)
+	c.Check(w.Bytes(), DeepEquals, b)
+}
+
+func (s *eventSuite) TestReadEventCryptoAgileOnlyUnknownAlgorithm(c *C) {
+	digestSizes := []EFISpecIdEventAlgorithmSize{{AlgorithmId: 0x1234, DigestSize: 8}}
+
+	event := &Event{
+		PCRIndex:  7,
+		EventType: EventTypeSeparator,
+		Digests:   DigestMap{0x1234: decodeHexString(c, "0102030405060708")},
+		Data:      &SeparatorEventData{Value: SeparatorEventNormalValue}}
+
+	w := new(bytes.Buffer)
+	c.Check(event.WriteCryptoAgile(w, digestSizes), IsNil)
+
+	event, err := ReadEventCryptoAgile(w, digestSizes, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(event.Digests, DeepEquals, DigestMap{0x1234: decodeHexString(c, "0102030405060708")})
+
+	data, ok := event.Data.(*SeparatorEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Value, Equals, uint32(SeparatorEventNormalValue))
+}
+
+func (s *eventSuite) TestEventHasBank(c *C) {
+	event := &Event{
+		PCRIndex:  7,
+		EventType: EventTypeSeparator,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			0x1234:                   make(Digest, 8)}}
+	c.Check(event.HasBank(tpm2.HashAlgorithmSHA1), Equals, true)
+	c.Check(event.HasBank(tpm2.HashAlgorithmSHA256), Equals, false)
+	c.Check(event.HasBank(tpm2.HashAlgorithmSHA384), Equals, false)
+	c.Check(event.HasBank(0x1234), Equals, false)
+}
+
+func (s *eventSuite) TestEventDigest(c *C) {
+	sha1 := decodeHexString(c, "9069ca78e7450a285173431b3e52c5c25299e473")
+	event := &Event{
+		PCRIndex:  7,
+		EventType: EventTypeSeparator,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1: sha1,
+			0x1234:                 make(Digest, 8)}}
+
+	digest, ok := event.Digest(tpm2.HashAlgorithmSHA1)
+	c.Check(ok, Equals, true)
+	c.Check(digest, DeepEquals, Digest(sha1))
+
+	digest, ok = event.Digest(0x1234)
+	c.Check(ok, Equals, true)
+	c.Check(digest, DeepEquals, make(Digest, 8))
+
+	digest, ok = event.Digest(tpm2.HashAlgorithmSHA256)
+	c.Check(ok, Equals, false)
+	c.Check(digest, IsNil)
+}
+
+func (s *eventSuite) TestEventPCRString(c *C) {
+	c.Check((&Event{PCRIndex: 7}).PCRString(), Equals, "PCR07")
+	c.Check((&Event{PCRIndex: 14}).PCRString(), Equals, "PCR14")
+}
+
+func (s *eventSuite) TestEventSummary(c *C) {
+	event := &Event{
+		PCRIndex:  7,
+		EventType: EventTypeSeparator,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   decodeHexString(c, "9069ca78e7450a285173431b3e52c5c25299e473"),
+			tpm2.HashAlgorithmSHA256: decodeHexString(c, "df3f619804a92fdb4057192dc43dd748ea778adc52bc498ce80524c014b81119")},
+		Data: &SeparatorEventData{Value: SeparatorEventNormalValue}}
+	c.Check(event.Summary(), Equals, "PCR07 EV_SEPARATOR sha256:df3f6198...")
+
+	event.Data = NewErrorSeparatorEventData([]byte{0x01, 0x00, 0x00, 0x00})
+	c.Check(event.Summary(), Equals, "PCR07 EV_SEPARATOR sha256:df3f6198... ERROR: 0x01000000")
+}
+
+func (s *eventSuite) TestEventSummaryLog(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for _, event := range log.Events {
+		summary := event.Summary()
+		c.Check(strings.Contains(summary, "\n"), Equals, false)
+		c.Check(strings.HasPrefix(summary, event.PCRString()+" "+event.EventType.String()), Equals, true)
+	}
+}
+
+func (s *eventSuite) TestReadEventCryptoAgileTruncated(c *C) {
+	data := decodeHexString(c, "01000000020000800200000004005fa6e9a74105c1e2297cce17c68288c84a8bda070b009d0689"+
+		"e46d7c710571256af5b8e8638f0dbc6b008f5ea4688c1c70f3005943e43800000061dfe48bca93d211aa0d00e098032b8c09000000000000000600000000000"+
+		"00042006f006f0074004f007200640065007200030000000100")
+	digestSizes := []EFISpecIdEventAlgorithmSize{
+		{AlgorithmId: tpm2.HashAlgorithmSHA1, DigestSize: uint16(tpm2.HashAlgorithmSHA1.Size())},
+		{AlgorithmId: tpm2.HashAlgorithmSHA256, DigestSize: uint16(tpm2.HashAlgorithmSHA256.Size())}}
+
+	for _, n := range []int{6, 20, 70, len(data) - 1} {
+		_, err := ReadEventCryptoAgile(bytes.NewReader(data[:n]), digestSizes, &LogOptions{})
+		c.Check(err, ErrorMatches, `.*unexpected EOF`)
+		c.Check(xerrors.Is(err, ErrTruncated), Equals, true)
+		c.Check(xerrors.Is(err, io.ErrUnexpectedEOF), Equals, true)
+	}
+}
+
+func (s *eventSuite) TestReadEventCryptoAgileTooManyDigests(c *C) {
+	_, err := ReadEventCryptoAgile(
+		bytes.NewReader(decodeHexString(c, "0100000002000080ffffffff04005fa6e9a74105c1e2297cce17c68288c84a8bda070b")),
+		[]EFISpecIdEventAlgorithmSize{
+			{AlgorithmId: tpm2.HashAlgorithmSHA1, DigestSize: uint16(tpm2.HashAlgorithmSHA1.Size())},
+			{AlgorithmId: tpm2.HashAlgorithmSHA256, DigestSize: uint16(tpm2.HashAlgorithmSHA256.Size())}},
+		&LogOptions{})
+	c.Check(err, ErrorMatches, `event contains more digests \(4294967295\) than the log declares algorithms for \(2\)`)
+}
+
+func (s *eventSuite) TestReadEventLargeEventSize(c *C) {
+	w := new(bytes.Buffer)
+	w.Write(decodeHexString(c, "0100000002000080"))
+	w.Write(make([]byte, tpm2.HashAlgorithmSHA1.Size()))
+	w.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	w.Write(make([]byte, 100))
+
+	_, err := ReadEvent(w, &LogOptions{})
+	c.Check(err, ErrorMatches, `event data is 4294967295 bytes, but only 100 bytes remain: unexpected EOF`)
+	c.Check(xerrors.Is(err, ErrTruncated), Equals, true)
+	c.Check(xerrors.Is(err, io.ErrUnexpectedEOF), Equals, true)
+}
+
+func (s *eventSuite) TestReadEventLargeEventData(c *C) {
+	expected := &Event{
+		PCRIndex:  1,
+		EventType: EventTypeAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+		Data:      OpaqueEventData(bytes.Repeat([]byte{0xa5}, 200*1024))}
+	w := new(bytes.Buffer)
+	c.Assert(expected.Write(w), IsNil)
+
+	event, err := ReadEvent(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(event.Data.Bytes(), DeepEquals, expected.Data.Bytes())
+}
+
+func (s *eventSuite) TestReadLogSpecIdEventTooManyAlgorithms(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	// Patch numberOfAlgorithms in the spec ID event.
+	binary.LittleEndian.PutUint32(data[56:], 0xffffffff)
+
+	event, err := ReadEvent(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+	decodeErr, ok := event.Data.(error)
+	c.Assert(ok, Equals, true)
+	c.Check(decodeErr, ErrorMatches, `.*numberOfAlgorithms \(4294967295\) is too large for the event size: event is truncated`)
+	c.Check(xerrors.Is(decodeErr, ErrTruncated), Equals, true)
+}
+
+func (s *eventSuite) TestEventIsPreOSOnly(c *C) {
+	for _, t := range []struct {
+		pcr       PCRIndex
+		eventType EventType
+		expected  bool
+	}{
+		{pcr: 0, eventType: EventTypeSCRTMVersion, expected: true},
+		{pcr: 0, eventType: EventTypeEFIPlatformFirmwareBlob, expected: true},
+		{pcr: 2, eventType: EventTypeEFIPlatformFirmwareBlob, expected: false},
+		{pcr: 7, eventType: EventTypeEFIVariableDriverConfig, expected: true},
+		{pcr: 1, eventType: EventTypeEFIVariableDriverConfig, expected: false},
+		{pcr: 7, eventType: EventTypeEFIVariableAuthority, expected: false},
+		{pcr: 4, eventType: EventTypeEFIBootServicesApplication, expected: false},
+	} {
+		event := &Event{PCRIndex: t.pcr, EventType: t.eventType}
+		c.Check(event.IsPreOSOnly(), Equals, t.expected, Commentf("%d %v", t.pcr, t.eventType))
+	}
+}
+
+func (s *eventSuite) TestEventIsPreOSOnlyLog(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	separated := make(map[PCRIndex]bool)
+	for _, event := range log.Events {
+		if separated[event.PCRIndex] {
+			c.Check(event.IsPreOSOnly(), Equals, false)
+		}
+		if event.EventType == EventTypeSeparator {
+			separated[event.PCRIndex] = true
+		}
+	}
+}
+
+func (s *eventSuite) TestEventRawBytes(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	raw := new(bytes.Buffer)
+	for _, event := range log.Events {
+		c.Check(bytes.HasSuffix(event.RawBytes(), event.Data.Bytes()), Equals, true)
+		raw.Write(event.RawBytes())
+	}
+	c.Check(raw.Bytes(), DeepEquals, data)
+}
+
+func (s *eventSuite) TestEventRawBytesNonCryptoAgile(c *C) {
+	event := &Event{
+		PCRIndex:  1,
+		EventType: EventTypeAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+		Data:      StringEventData("foo")}
+	c.Check(event.RawBytes(), IsNil)
+
+	w := new(bytes.Buffer)
+	c.Assert(event.Write(w), IsNil)
+	expected := w.Bytes()
+
+	event, err := ReadEvent(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(event.RawBytes(), DeepEquals, expected)
+}
+
+func (s *eventSuite) TestEventDevicePathString(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	var paths []string
+	for _, event := range log.Events {
+		if path, ok := event.DevicePathString(); ok {
+			paths = append(paths, path)
+		}
+	}
+	c.Check(paths, DeepEquals, []string{
+		`\PciRoot(0x0)\Pci(0x1d,0x0)\Pci(0x0,0x0)\NVMe(0x1,00-00-00-00-00-00-00-00)\HD(1,GPT,66de947b-fdb2-4525-b752-30d66bb2b960)\\EFI\ubuntu\shimx64.efi`,
+		`\\EFI\ubuntu\grubx64.efi`})
+}
+
+func (s *eventSuite) TestEventDevicePathStringLazy(c *C) {
+	log := readTestLog(c, &LogOptions{LazyData: true})
+	event, _ := log.FindFirst(func(event *Event) bool {
+		return event.EventType == EventTypeEFIBootServicesApplication
+	})
+	c.Assert(event, NotNil)
+	path, ok := event.DevicePathString()
+	c.Check(ok, Equals, true)
+	c.Check(path, Matches, `.*\\EFI\\ubuntu\\shimx64.efi`)
+}
+
+func (s *eventSuite) TestEventDevicePathStringNotImageLoad(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, ok := log.Events[9].DevicePathString()
+	c.Check(ok, Equals, false)
+}
+
+func (s *eventSuite) TestEventEquivalentTo(c *C) {
+	newEvent := func(data string) *Event {
+		return &Event{
+			PCRIndex:  4,
+			EventType: EventTypeEFIAction,
+			Digests: DigestMap{
+				tpm2.HashAlgorithmSHA1:   ComputeStringEventDigest(crypto.SHA1, data),
+				tpm2.HashAlgorithmSHA256: ComputeStringEventDigest(crypto.SHA256, data)},
+			Data: StringEventData(data)}
+	}
+
+	a := newEvent("foo")
+	c.Check(a.EquivalentTo(newEvent("foo")), Equals, true)
+	c.Check(a.EquivalentTo(newEvent("bar")), Equals, false)
+	c.Check(a.EquivalentTo(nil), Equals, false)
+	c.Check((*Event)(nil).EquivalentTo(nil), Equals, true)
+
+	b := newEvent("foo")
+	b.PCRIndex = 5
+	c.Check(a.EquivalentTo(b), Equals, false)
+
+	b = newEvent("foo")
+	b.EventType = EventTypeAction
+	c.Check(a.EquivalentTo(b), Equals, false)
+
+	// A subset of banks is compared on the common banks.
+	b = newEvent("foo")
+	delete(b.Digests, tpm2.HashAlgorithmSHA1)
+	c.Check(a.EquivalentTo(b), Equals, true)
+	b.Digests[tpm2.HashAlgorithmSHA256] = make(Digest, tpm2.HashAlgorithmSHA256.Size())
+	c.Check(a.EquivalentTo(b), Equals, false)
+
+	// Events with no common banks are compared on their event data.
+	a = newEvent("foo")
+	delete(a.Digests, tpm2.HashAlgorithmSHA256)
+	b = newEvent("foo")
+	delete(b.Digests, tpm2.HashAlgorithmSHA1)
+	c.Check(a.EquivalentTo(b), Equals, true)
+	b = newEvent("bar")
+	delete(b.Digests, tpm2.HashAlgorithmSHA1)
+	c.Check(a.EquivalentTo(b), Equals, false)
+}
+
+func (s *eventSuite) TestEventCategory(c *C) {
+	for _, data := range []struct {
+		pcr       PCRIndex
+		eventType EventType
+		data      EventData
+		expected  string
+	}{
+		{pcr: 0, eventType: EventTypeNoAction, expected: "Log metadata"},
+		{pcr: 4, eventType: EventTypeSeparator, expected: "Separator"},
+		{pcr: 0, eventType: EventTypeSCRTMVersion, expected: "Firmware"},
+		{pcr: 1, eventType: EventTypeEFIVariableBoot, expected: "Firmware configuration"},
+		{pcr: 2, eventType: EventTypeEFIBootServicesDriver, expected: "Option ROMs and drivers"},
+		{pcr: 4, eventType: EventTypeEFIBootServicesApplication, expected: "Bootloader"},
+		{pcr: 4, eventType: EventTypeEFIAction, expected: "Boot manager"},
+		{pcr: 5, eventType: EventTypeEFIGPTEvent, expected: "Partition table"},
+		{pcr: 5, eventType: EventTypeEFIVariableBoot, expected: "Boot configuration"},
+		{pcr: 6, eventType: EventTypeCompactHash, expected: "Platform manufacturer"},
+		{pcr: 7, eventType: EventTypeEFIVariableAuthority, expected: "Secure boot policy"},
+		{pcr: 8, eventType: EventTypeIPL, data: &GrubStringEventData{Type: GrubCmd, Str: "linux /vmlinuz"}, expected: "Bootloader"},
+		{pcr: 8, eventType: EventTypeIPL, data: &GrubStringEventData{Type: KernelCmdline, Str: "/vmlinuz ro"}, expected: "Kernel"},
+		{pcr: 12, eventType: EventTypeIPL, data: &SystemdEFIStubCommandline{Str: "ro"}, expected: "Operating system"},
+		{pcr: 8, eventType: EventTypeIPL, data: &SystemdEFIStubCommandline{Str: "ro"}, expected: "Kernel"},
+		{pcr: 8, eventType: EventTypeIPL, data: StringEventData("foo"), expected: "Operating system loader"},
+		{pcr: 9, eventType: EventTypeIPL, data: StringEventData("/vmlinuz"), expected: "Loaded files"},
+		{pcr: 14, eventType: EventTypeIPL, expected: "Operating system"},
+		{pcr: 23, eventType: EventTypeIPL, expected: "Other"},
+	} {
+		event := &Event{PCRIndex: data.pcr, EventType: data.eventType, Data: data.data}
+		c.Check(event.Category(), Equals, data.expected, Commentf("PCR %d, type %v", data.pcr, data.eventType))
+	}
+}
+
+func (s *eventSuite) TestEventDecodedData(c *C) {
+	eager := readTestLog(c, &LogOptions{})
+	lazy := readTestLog(c, &LogOptions{LazyData: true})
+	c.Assert(lazy.Events, HasLen, len(eager.Events))
+
+	c.Check(eager.Events[4].DecodedData(), Equals, eager.Events[4].Data)
+
+	c.Assert(lazy.Events[4].Data, FitsTypeOf, (*LazyEventData)(nil))
+	data, ok := lazy.Events[4].DecodedData().(*EFIVariableData)
+	c.Assert(ok, Equals, true)
+	c.Check(data, DeepEquals, eager.Events[4].Data)
+	c.Check(lazy.Events[4].DecodedData(), Equals, EventData(data))
+}
@@ -5,9 +5,12 @@
 package tcglog
 
 import (
+	"bytes"
 	"crypto"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -25,6 +28,20 @@ type EventData interface {
 
 	// Write will serialize this event data to the supplied io.Writer.
 	Write(w io.Writer) error
+
+	// Equal indicates whether this event data is semantically equal to other, ie,
+	// it is the same type and has the same decoded contents. Event data that is
+	// lazily decoded is compared using its decoded form.
+	Equal(other EventData) bool
+}
+
+// resolveEventData returns the decoded form of the supplied event data if it is
+// lazily decoded.
+func resolveEventData(data EventData) EventData {
+	if lazy, ok := data.(*LazyEventData); ok {
+		return lazy.Decode()
+	}
+	return data
 }
 
 type rawEventData []byte
@@ -48,6 +65,11 @@ func (e *invalidEventData) Write(w io.Writer) error {
 	return err
 }
 
+func (e *invalidEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*invalidEventData)
+	return ok && bytes.Equal(e.rawEventData, o.rawEventData)
+}
+
 func (e *invalidEventData) Error() string {
 	return e.err.Error()
 }
@@ -56,6 +78,43 @@ func (e *invalidEventData) Unwrap() error {
 	return e.err
 }
 
+// LazyEventData is event data that is decoded on first use, and is used when
+// LogOptions.LazyData is set. The raw event data bytes are available without
+// decoding, and Decode returns the decoded event data.
+type LazyEventData struct {
+	rawEventData
+	pcrIndex  PCRIndex
+	eventType EventType
+	digests   DigestMap
+	options   *LogOptions
+
+	once    sync.Once
+	decoded EventData
+}
+
+// Decode decodes the event data, returning the same type that would have been
+// produced if LogOptions.LazyData was not set. The data is only decoded on the
+// first call, and this is safe to call from multiple goroutines.
+func (d *LazyEventData) Decode() EventData {
+	d.once.Do(func() {
+		d.decoded = decodeEventData(d.rawEventData, d.pcrIndex, d.eventType, d.digests, d.options)
+	})
+	return d.decoded
+}
+
+func (d *LazyEventData) String() string {
+	return d.Decode().String()
+}
+
+func (d *LazyEventData) Write(w io.Writer) error {
+	_, err := w.Write(d.rawEventData)
+	return err
+}
+
+func (d *LazyEventData) Equal(other EventData) bool {
+	return d.Decode().Equal(other)
+}
+
 // OpaqueEventData is event data whose format is unknown or implementation defined.
 type OpaqueEventData []byte
 
@@ -87,6 +146,33 @@ func (d OpaqueEventData) Write(w io.Writer) error {
 	return err
 }
 
+func (d OpaqueEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(OpaqueEventData)
+	return ok && bytes.Equal(d, o)
+}
+
+// TypedOpaqueEventData is opaque event data that records the type of the event that
+// it is associated with, which is used to provide a more informative description of
+// event data that this package doesn't decode. The event data for these events is
+// still OpaqueEventData, and a TypedOpaqueEventData for them is obtained from
+// Event.OpaqueData.
+type TypedOpaqueEventData struct {
+	OpaqueEventData
+	EventType EventType
+}
+
+func (d *TypedOpaqueEventData) String() string {
+	if s := d.OpaqueEventData.String(); s != "" {
+		return s
+	}
+	return fmt.Sprintf("opaque data for %v (%d bytes)", d.EventType, len(d.OpaqueEventData))
+}
+
+func (d *TypedOpaqueEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*TypedOpaqueEventData)
+	return ok && d.EventType == o.EventType && bytes.Equal(d.OpaqueEventData, o.OpaqueEventData)
+}
+
 // ComputeEventDigest computes the digest associated with the supplied event data bytes,
 // for events where the digest is a tagged hash of the event data.
 func ComputeEventDigest(alg crypto.Hash, data []byte) []byte {
@@ -96,6 +182,16 @@ func ComputeEventDigest(alg crypto.Hash, data []byte) []byte {
 }
 
 func decodeEventData(data []byte, pcrIndex PCRIndex, eventType EventType, digests DigestMap, options *LogOptions) EventData {
+	if fn := options.customEventDataDecoder(data, eventType); fn != nil {
+		out, err := fn(data, binary.LittleEndian)
+		switch {
+		case err != nil:
+			return &invalidEventData{rawEventData: data, err: err}
+		case out != nil:
+			return out
+		}
+	}
+
 	if options.EnableGrub && (pcrIndex == 8 || pcrIndex == 9) {
 		if out := decodeEventDataGRUB(data, pcrIndex, eventType); out != nil {
 			return out
@@ -109,7 +205,19 @@ func decodeEventData(data []byte, pcrIndex PCRIndex, eventType EventType, digest
 
 	}
 
-	out, err := decodeEventDataTCG(data, pcrIndex, eventType, digests)
+	if options.EnableSystemdBoot {
+		if out := decodeEventDataSystemdBoot(data, eventType); out != nil {
+			return out
+		}
+	}
+
+	if options.EnableWindowsSIPA {
+		if out := decodeEventDataSIPA(data, eventType); out != nil {
+			return out
+		}
+	}
+
+	out, err := decodeEventDataTCG(data, pcrIndex, eventType, options.computableDigests(digests), options.spec)
 	if err != nil {
 		return &invalidEventData{rawEventData: data, err: err}
 	}
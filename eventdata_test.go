@@ -53,3 +53,84 @@ func (s *eventdataSuite) TestComputeEventDigest(c *C) {
 	c.Check(ComputeEventDigest(crypto.SHA256, []byte("foo")), DeepEquals, decodeHexString(c, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"))
 	c.Check(ComputeEventDigest(crypto.SHA1, []byte("bar")), DeepEquals, decodeHexString(c, "62cdb7020ff920e5aa642c3d4066950dd1f01f4d"))
 }
+
+func (s *eventdataSuite) TestOpaqueEventDataEqual(c *C) {
+	c.Check(OpaqueEventData("foo").Equal(OpaqueEventData("foo")), Equals, true)
+	c.Check(OpaqueEventData("foo").Equal(OpaqueEventData("bar")), Equals, false)
+	c.Check(OpaqueEventData("foo").Equal(StringEventData("foo")), Equals, false)
+}
+
+func (s *eventdataSuite) TestEventDataEqualLog(c *C) {
+	log1 := readTestLog(c, &LogOptions{EnableGrub: true})
+	log2 := readTestLog(c, &LogOptions{EnableGrub: true})
+	c.Assert(log1.Events, HasLen, len(log2.Events))
+	for i := range log1.Events {
+		c.Check(log1.Events[i].Data.Equal(log2.Events[i].Data), Equals, true, Commentf("event %d", i))
+	}
+	for i := 1; i < len(log1.Events); i++ {
+		if log1.Events[i].EventType == log1.Events[i-1].EventType && log1.Events[i].Data.String() == log1.Events[i-1].Data.String() {
+			continue
+		}
+		c.Check(log1.Events[i].Data.Equal(log1.Events[i-1].Data), Equals, false, Commentf("event %d", i))
+	}
+}
+
+func (s *eventdataSuite) TestEventDataEqualLazy(c *C) {
+	log1 := readTestLog(c, &LogOptions{})
+	log2 := readTestLog(c, &LogOptions{LazyData: true})
+	c.Assert(log1.Events, HasLen, len(log2.Events))
+	for i := range log1.Events {
+		c.Check(log1.Events[i].Data.Equal(log2.Events[i].Data), Equals, true, Commentf("event %d", i))
+		c.Check(log2.Events[i].Data.Equal(log1.Events[i].Data), Equals, true, Commentf("event %d", i))
+	}
+}
+
+func (s *eventdataSuite) TestTypedOpaqueEventDataString(c *C) {
+	data := &TypedOpaqueEventData{OpaqueEventData: []byte{1, 2, 3, 4}, EventType: EventTypeEFIHandoffTables}
+	c.Check(data.String(), Equals, "opaque data for EV_EFI_HANDOFF_TABLES (4 bytes)")
+
+	data = &TypedOpaqueEventData{OpaqueEventData: []byte("foo"), EventType: EventTypeSCRTMVersion}
+	c.Check(data.String(), Equals, "foo")
+}
+
+func (s *eventdataSuite) TestTypedOpaqueEventDataEqual(c *C) {
+	data := &TypedOpaqueEventData{OpaqueEventData: []byte("foo"), EventType: EventTypeIPL}
+	c.Check(data.Equal(&TypedOpaqueEventData{OpaqueEventData: []byte("foo"), EventType: EventTypeIPL}), Equals, true)
+	c.Check(data.Equal(&TypedOpaqueEventData{OpaqueEventData: []byte("bar"), EventType: EventTypeIPL}), Equals, false)
+	c.Check(data.Equal(&TypedOpaqueEventData{OpaqueEventData: []byte("foo"), EventType: EventTypePostCode}), Equals, false)
+	c.Check(data.Equal(OpaqueEventData("foo")), Equals, false)
+}
+
+func (s *eventdataSuite) TestEventOpaqueData(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.Events[4].OpaqueData(), IsNil)
+	for _, event := range log.Events {
+		if event.EventType != EventTypeEFIHandoffTables {
+			continue
+		}
+		c.Check(event.Data, FitsTypeOf, OpaqueEventData(nil))
+		data := event.OpaqueData()
+		c.Assert(data, NotNil)
+		c.Check(data.EventType, Equals, EventTypeEFIHandoffTables)
+		c.Check(data.String(), Equals, "opaque data for EV_EFI_HANDOFF_TABLES (32 bytes)")
+
+		w := new(bytes.Buffer)
+		c.Check(data.Write(w), IsNil)
+		c.Check(w.Bytes(), DeepEquals, data.Bytes())
+	}
+}
+
+func (s *eventdataSuite) TestEventOpaqueDataLazy(c *C) {
+	log := readTestLog(c, &LogOptions{LazyData: true})
+	n := 0
+	for _, event := range log.Events {
+		if event.EventType != EventTypeEFIHandoffTables {
+			continue
+		}
+		data := event.OpaqueData()
+		c.Assert(data, NotNil)
+		c.Check(data.EventType, Equals, EventTypeEFIHandoffTables)
+		n++
+	}
+	c.Check(n > 0, Equals, true)
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+//go:build linux
+// +build linux
+
+package tcglog
+
+func MockDevMemPath(path string) (restore func()) {
+	orig := devMemPath
+	devMemPath = path
+	return func() {
+		devMemPath = orig
+	}
+}
+
+func MockSecurityFSLogPath(path string) (restore func()) {
+	orig := securityFSLogPath
+	securityFSLogPath = path
+	return func() {
+		securityFSLogPath = orig
+	}
+}
+
+func MockACPITPM2TablePath(path string) (restore func()) {
+	orig := acpiTPM2TablePath
+	acpiTPM2TablePath = path
+	return func() {
+		acpiTPM2TablePath = orig
+	}
+}
@@ -10,5 +10,7 @@ var (
 	DecodeEventDataEFIVariable    = decodeEventDataEFIVariable
 	DecodeEventDataNoAction       = decodeEventDataNoAction
 	DecodeEventDataSeparator      = decodeEventDataSeparator
+	DecodeEventDataSIPA           = decodeEventDataSIPA
+	DecodeEventDataSystemdBoot    = decodeEventDataSystemdBoot
 	DecodeEventDataSystemdEFIStub = decodeEventDataSystemdEFIStub
 )
@@ -0,0 +1,168 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/tcglog-parser/internal/ioerr"
+)
+
+const finalEventsTableVersion = 1
+
+// finalEventsTableHeader corresponds to the header of the EFI_TCG2_FINAL_EVENTS_TABLE type.
+type finalEventsTableHeader struct {
+	Version        uint64
+	NumberOfEvents uint64
+}
+
+// ReadFinalEventsTable reads the events from the EFI_TCG2_FINAL_EVENTS_TABLE read from r.
+// This table contains the events measured by the firmware after EFI_TCG2_PROTOCOL.GetEventLog
+// was first called. It doesn't contain a spec ID event, so the digest sizes are obtained from
+// the supplied log, which must be a crypto-agile log.
+//
+// If an error occurs during parsing, this may return an incomplete list of events with the
+// error.
+func ReadFinalEventsTable(r io.Reader, log *Log, options *LogOptions) ([]*Event, error) {
+	if len(log.Events) == 0 {
+		return nil, errors.New("cannot read final events table without a main log")
+	}
+	spec, ok := log.Events[specIdEventIndex(log.Events)].Data.(*SpecIdEvent03)
+	if !ok {
+		return nil, errors.New("the final events table is only valid for crypto-agile logs")
+	}
+
+	var header finalEventsTableHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read header: %w", err)
+	}
+	if header.Version != finalEventsTableVersion {
+		return nil, fmt.Errorf("unexpected final events table version (%d)", header.Version)
+	}
+
+	var events []*Event
+	for i := uint64(0); i < header.NumberOfEvents; i++ {
+		event, err := readEventCryptoAgile(r, spec.DigestSizes, options.eventDataDecoder())
+		if err != nil {
+			return events, ioerr.EOFIsUnexpected("cannot read event %d: %w", i, err)
+		}
+		options.onEvent(event)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// appendEvents returns a new log containing the events from this log followed by the
+// supplied events. This log is not modified.
+func (l *Log) appendEvents(events []*Event) *Log {
+	out := &Log{
+		Spec:       l.Spec,
+		Algorithms: l.Algorithms,
+		Events:     make([]*Event, 0, len(l.Events)+len(events)),
+		Warnings:   append([]error(nil), l.Warnings...)}
+	out.Events = append(out.Events, l.Events...)
+	for _, event := range events {
+		out.checkEventData(len(out.Events), -1, event)
+		out.Events = append(out.Events, event)
+	}
+	return out
+}
+
+// AppendFinalEvents returns a new log containing the events from this log followed
+// by the supplied events from the final events table, obtained from ReadFinalEventsTable.
+// Replaying the returned log produces the PCR values that include measurements made
+// after the main log was retrieved from the firmware. This log is not modified.
+func (l *Log) AppendFinalEvents(events []*Event) *Log {
+	return l.appendEvents(events)
+}
+
+// AppendIMAEvents returns a new log containing the events from this log followed by
+// the supplied events from the Linux IMA runtime measurement log, obtained from
+// ReadIMALog. Replaying the returned log produces the PCR values that include the
+// measurements made by the kernel. This log is not modified.
+func (l *Log) AppendIMAEvents(events []*Event) *Log {
+	return l.appendEvents(events)
+}
+
+// CompleteLogSources contains the sources of events that are combined with the main
+// log by ReadCompleteLog. Each of them is optional.
+type CompleteLogSources struct {
+	// FinalEvents is the EFI_TCG2_FINAL_EVENTS_TABLE.
+	FinalEvents io.Reader
+
+	// FinalEventsInLog is the number of events at the start of the final events table
+	// that are also recorded at the end of the main log. This is the value of the
+	// NumberOfEvents field of the final events table at the time that the main log
+	// was retrieved from the firmware, which Linux records as the size of these
+	// events in the final_events_preboot_size field of its copy of the log. The
+	// firmware continues to add events to the final events table after this, such
+	// as those measured during ExitBootServices, and these are the only events in
+	// the table that are not already in the main log.
+	FinalEventsInLog int
+
+	// IMALog is the Linux IMA runtime measurement log in the binary format that is
+	// read by ReadIMALog.
+	IMALog io.Reader
+}
+
+// ReadCompleteLog reads the main event log from r and combines it with the events
+// from the supplied sources, returning a single log that contains the events from the
+// main log, followed by the events from the EFI_TCG2_FINAL_EVENTS_TABLE that are not
+// already in the main log, followed by the events from the Linux IMA runtime
+// measurement log. Replaying the returned log produces the current values of the PCRs
+// that the firmware and the kernel measure to.
+//
+// The events at the start of the final events table that are also in the main log are
+// identified by their position according to sources.FinalEventsInLog, rather than by
+// comparing them, so that identical events that are measured more than once are
+// preserved. An error is returned if these events don't match the events at the end of
+// the main log.
+//
+// If sources is nil, this is equivalent to ReadLog.
+func ReadCompleteLog(r io.Reader, sources *CompleteLogSources, options *LogOptions) (*Log, error) {
+	log, err := ReadLog(r, options)
+	if err != nil {
+		return nil, err
+	}
+	if sources == nil {
+		return log, nil
+	}
+
+	if sources.FinalEvents != nil {
+		events, err := ReadFinalEventsTable(sources.FinalEvents, log, options)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read final events table: %w", err)
+		}
+
+		n := sources.FinalEventsInLog
+		if n < 0 || n > len(events) || n > len(log.Events) {
+			return nil, fmt.Errorf("invalid number of final events in the main log (%d)", n)
+		}
+		for i, event := range events[:n] {
+			j := len(log.Events) - n + i
+			if !log.Events[j].Equal(event) {
+				return nil, fmt.Errorf("final events table event %d does not match event %d in the main log", i, j)
+			}
+		}
+
+		log = log.AppendFinalEvents(events[n:])
+	}
+
+	if sources.IMALog != nil {
+		events, err := ReadIMALog(sources.IMALog, log.Algorithms)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read IMA log: %w", err)
+		}
+		log = log.AppendIMAEvents(events)
+	}
+
+	return log, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/canonical/go-tpm2"
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type finaleventsSuite struct{}
+
+var _ = Suite(&finaleventsSuite{})
+
+func (s *finaleventsSuite) makeFinalEventsTable(c *C, log *Log, version uint64, events []*Event) []byte {
+	w := new(bytes.Buffer)
+	c.Check(binary.Write(w, binary.LittleEndian, version), IsNil)
+	c.Check(binary.Write(w, binary.LittleEndian, uint64(len(events))), IsNil)
+	digestSizes := log.Events[0].Data.(*SpecIdEvent03).DigestSizes
+	for _, event := range events {
+		c.Check(event.WriteCryptoAgile(w, digestSizes), IsNil)
+	}
+	return w.Bytes()
+}
+
+func (s *finaleventsSuite) TestReadFinalEventsTable(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	events := log.Events[len(log.Events)-3:]
+	table := s.makeFinalEventsTable(c, log, 1, events)
+
+	final, err := ReadFinalEventsTable(bytes.NewReader(table), log, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(final, HasLen, len(events))
+	for i, event := range final {
+		c.Check(event.PCRIndex, Equals, events[i].PCRIndex)
+		c.Check(event.EventType, Equals, events[i].EventType)
+		c.Check(event.Digests, DeepEquals, events[i].Digests)
+		c.Check(event.Data.Bytes(), DeepEquals, events[i].Data.Bytes())
+	}
+}
+
+func (s *finaleventsSuite) TestReadFinalEventsTableSpecIdNotFirst(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	table := s.makeFinalEventsTable(c, log, 1, log.Events[len(log.Events)-3:])
+
+	log.Events = append([]*Event{{
+		PCRIndex:  0,
+		EventType: EventTypeNoAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, 20), tpm2.HashAlgorithmSHA256: make(Digest, 32)},
+		Data:      &StartupLocalityEventData{StartupLocality: 3}}}, log.Events...)
+
+	final, err := ReadFinalEventsTable(bytes.NewReader(table), log, &LogOptions{})
+	c.Check(err, IsNil)
+	c.Check(final, HasLen, 3)
+}
+
+func (s *finaleventsSuite) TestReadFinalEventsTableEmpty(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	final, err := ReadFinalEventsTable(bytes.NewReader(s.makeFinalEventsTable(c, log, 1, nil)), log, &LogOptions{})
+	c.Check(err, IsNil)
+	c.Check(final, HasLen, 0)
+}
+
+func (s *finaleventsSuite) TestReadFinalEventsTableInvalidVersion(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	_, err := ReadFinalEventsTable(bytes.NewReader(s.makeFinalEventsTable(c, log, 2, nil)), log, &LogOptions{})
+	c.Check(err, ErrorMatches, `unexpected final events table version \(2\)`)
+}
+
+func (s *finaleventsSuite) TestReadFinalEventsTableTruncated(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	table := s.makeFinalEventsTable(c, log, 1, log.Events[len(log.Events)-2:])
+	final, err := ReadFinalEventsTable(bytes.NewReader(table[:len(table)-4]), log, &LogOptions{})
+	c.Check(err, ErrorMatches, `cannot read event 1: .*`)
+	c.Check(xerrors.Is(err, io.ErrUnexpectedEOF), Equals, true)
+	c.Check(final, HasLen, 1)
+}
+
+func (s *finaleventsSuite) TestReadFinalEventsTableNotCryptoAgile(c *C) {
+	log := NewLogForTesting([]*Event{{
+		PCRIndex:  0,
+		EventType: EventTypeNoAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, 20)},
+		Data:      &SpecIdEvent00{}}})
+
+	_, err := ReadFinalEventsTable(bytes.NewReader(nil), log, &LogOptions{})
+	c.Check(err, ErrorMatches, `the final events table is only valid for crypto-agile logs`)
+}
+
+func (s *finaleventsSuite) TestAppendFinalEvents(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	n := len(log.Events)
+
+	final := []*Event{{
+		PCRIndex:  7,
+		EventType: EventTypeEFIVariableAuthority,
+		Digests:   log.Events[n-1].Digests}}
+
+	merged := log.AppendFinalEvents(final)
+	c.Check(merged.Spec, Equals, log.Spec)
+	c.Check(merged.Algorithms, DeepEquals, log.Algorithms)
+	c.Assert(merged.Events, HasLen, n+1)
+	c.Check(merged.Events[:n], DeepEquals, log.Events)
+	c.Check(merged.Events[n], Equals, final[0])
+	c.Check(log.Events, HasLen, n)
+}
+
+func (s *finaleventsSuite) testReadCompleteLog(c *C, mainEvents, finalStart int) {
+	expected := readTestLog(c, &LogOptions{})
+
+	main := NewLogForTesting(expected.Events[:mainEvents])
+	w := new(bytes.Buffer)
+	c.Assert(main.Write(w), IsNil)
+
+	table := s.makeFinalEventsTable(c, expected, 1, expected.Events[finalStart:])
+
+	log, err := ReadCompleteLog(w, &CompleteLogSources{
+		FinalEvents:      bytes.NewReader(table),
+		FinalEventsInLog: mainEvents - finalStart}, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec, Equals, expected.Spec)
+	c.Check(log.Algorithms, DeepEquals, expected.Algorithms)
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events {
+		c.Check(event.Equal(expected.Events[i]), Equals, true, Commentf("event %d", i))
+	}
+}
+
+func (s *finaleventsSuite) TestReadCompleteLogNoOverlap(c *C) {
+	n := len(readTestLog(c, &LogOptions{}).Events)
+	s.testReadCompleteLog(c, n-3, n-3)
+}
+
+func (s *finaleventsSuite) TestReadCompleteLogOverlap(c *C) {
+	n := len(readTestLog(c, &LogOptions{}).Events)
+	s.testReadCompleteLog(c, n-3, n-5)
+}
+
+func (s *finaleventsSuite) TestReadCompleteLogNoFinalEvents(c *C) {
+	f, err := os.Open("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	log, err := ReadCompleteLog(f, nil, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Events, HasLen, len(readTestLog(c, &LogOptions{}).Events))
+}
+
+func (s *finaleventsSuite) TestReadCompleteLogRepeatedEvent(c *C) {
+	// The final events table starts with an event that is identical to the last
+	// event in the main log, but which was measured again afterwards.
+	expected := readTestLog(c, &LogOptions{})
+	n := len(expected.Events)
+	w := new(bytes.Buffer)
+	c.Assert(expected.Write(w), IsNil)
+
+	table := s.makeFinalEventsTable(c, expected, 1, expected.Events[n-1:])
+
+	log, err := ReadCompleteLog(w, &CompleteLogSources{FinalEvents: bytes.NewReader(table)}, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(log.Events, HasLen, n+1)
+	c.Check(log.Events[n].Equal(expected.Events[n-1]), Equals, true)
+	c.Check(log.Events[n-1].Equal(expected.Events[n-1]), Equals, true)
+}
+
+func (s *finaleventsSuite) TestReadCompleteLogFinalEventsMismatch(c *C) {
+	expected := readTestLog(c, &LogOptions{})
+	n := len(expected.Events)
+
+	main := NewLogForTesting(expected.Events[:n-3])
+	w := new(bytes.Buffer)
+	c.Assert(main.Write(w), IsNil)
+
+	table := s.makeFinalEventsTable(c, expected, 1, expected.Events[n-4:])
+
+	_, err := ReadCompleteLog(w, &CompleteLogSources{
+		FinalEvents:      bytes.NewReader(table),
+		FinalEventsInLog: 2}, &LogOptions{})
+	c.Check(err, ErrorMatches, fmt.Sprintf(`final events table event 0 does not match event %d in the main log`, n-5))
+}
+
+func (s *finaleventsSuite) TestReadCompleteLogInvalidFinalEventsInLog(c *C) {
+	expected := readTestLog(c, &LogOptions{})
+	n := len(expected.Events)
+	w := new(bytes.Buffer)
+	c.Assert(expected.Write(w), IsNil)
+
+	table := s.makeFinalEventsTable(c, expected, 1, expected.Events[n-2:])
+
+	_, err := ReadCompleteLog(w, &CompleteLogSources{
+		FinalEvents:      bytes.NewReader(table),
+		FinalEventsInLog: 3}, &LogOptions{})
+	c.Check(err, ErrorMatches, `invalid number of final events in the main log \(3\)`)
+}
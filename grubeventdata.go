@@ -5,6 +5,7 @@
 package tcglog
 
 import (
+	"crypto"
 	"fmt"
 	"io"
 	"strings"
@@ -26,6 +27,16 @@ const (
 	KernelCmdline = "kernel_cmdline"
 )
 
+const (
+	grubStringPCR PCRIndex = 8 // The PCR that GRUB measures commands and kernel commandlines to
+	grubFilePCR   PCRIndex = 9 // The PCR that GRUB measures files to
+)
+
+// PCRIndex returns the PCR that GRUB is expected to measure events of this type to.
+func (t GrubStringEventType) PCRIndex() PCRIndex {
+	return grubStringPCR
+}
+
 // GrubStringEventData represents the data associated with an event measured by GRUB.
 type GrubStringEventData struct {
 	rawEventData
@@ -42,23 +53,39 @@ func (e *GrubStringEventData) Write(w io.Writer) error {
 	return err
 }
 
+func (e *GrubStringEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*GrubStringEventData)
+	return ok && e.Type == o.Type && e.Str == o.Str
+}
+
+// ComputeGrubStringEventDigest computes the digest measured by GRUB for the supplied
+// command or kernel commandline event data. Whilst the event data recorded in the log
+// contains a type prefix and a terminating NULL byte, GRUB only measures the command or
+// kernel commandline string itself, without the prefix or terminator.
+func ComputeGrubStringEventDigest(alg crypto.Hash, data *GrubStringEventData) []byte {
+	return ComputeStringEventDigest(alg, data.Str)
+}
+
 func decodeEventDataGRUB(data []byte, pcrIndex PCRIndex, eventType EventType) EventData {
 	if eventType != EventTypeIPL {
 		return nil
 	}
 
+	// Commands and kernel commandlines are decoded regardless of which
+	// PCR they are measured to, so that measurements to an unexpected PCR
+	// can be identified.
+	str := string(data)
+	switch {
+	case strings.HasPrefix(str, kernelCmdlinePrefix):
+		return &GrubStringEventData{rawEventData: data, Type: KernelCmdline, Str: strings.TrimSuffix(strings.TrimPrefix(str, kernelCmdlinePrefix), "\x00")}
+	case strings.HasPrefix(str, grubCmdPrefix):
+		return &GrubStringEventData{rawEventData: data, Type: GrubCmd, Str: strings.TrimSuffix(strings.TrimPrefix(str, grubCmdPrefix), "\x00")}
+	}
+
 	switch pcrIndex {
-	case 8:
-		str := string(data)
-		switch {
-		case strings.HasPrefix(str, kernelCmdlinePrefix):
-			return &GrubStringEventData{rawEventData: data, Type: KernelCmdline, Str: strings.TrimSuffix(strings.TrimPrefix(str, kernelCmdlinePrefix), "\x00")}
-		case strings.HasPrefix(str, grubCmdPrefix):
-			return &GrubStringEventData{rawEventData: data, Type: GrubCmd, Str: strings.TrimSuffix(strings.TrimPrefix(str, grubCmdPrefix), "\x00")}
-		default:
-			return nil
-		}
-	case 9:
+	case grubStringPCR:
+		return nil
+	case grubFilePCR:
 		return StringEventData(data)
 	default:
 		panic("unhandled PCR index")
@@ -0,0 +1,108 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"crypto"
+
+	"github.com/canonical/go-tpm2"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type grubeventdataSuite struct{}
+
+var _ = Suite(&grubeventdataSuite{})
+
+func (s *grubeventdataSuite) TestGrubStringEventTypePCRIndex(c *C) {
+	c.Check(GrubCmd.PCRIndex(), Equals, PCRIndex(8))
+	c.Check(GrubStringEventType(KernelCmdline).PCRIndex(), Equals, PCRIndex(8))
+}
+
+func (s *grubeventdataSuite) TestDecodeLog(c *C) {
+	log := readTestLog(c, &LogOptions{EnableGrub: true})
+
+	var n8, n9 int
+	for _, event := range log.Events {
+		if event.EventType != EventTypeIPL {
+			continue
+		}
+		switch event.PCRIndex {
+		case 8:
+			n8++
+			data, ok := event.Data.(*GrubStringEventData)
+			c.Assert(ok, Equals, true)
+			c.Check(data.Type.PCRIndex(), Equals, PCRIndex(8))
+		case 9:
+			n9++
+			_, ok := event.Data.(StringEventData)
+			c.Check(ok, Equals, true)
+		}
+	}
+	c.Check(n8 > 0, Equals, true)
+	c.Check(n9 > 0, Equals, true)
+}
+
+func (s *grubeventdataSuite) readEvent(c *C, pcrIndex PCRIndex, data string) *Event {
+	event := &Event{
+		PCRIndex:  pcrIndex,
+		EventType: EventTypeIPL,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+		Data:      OpaqueEventData(data)}
+	w := new(bytes.Buffer)
+	c.Assert(event.Write(w), IsNil)
+
+	event, err := ReadEvent(w, &LogOptions{EnableGrub: true})
+	c.Assert(err, IsNil)
+	return event
+}
+
+func (s *grubeventdataSuite) TestDecodeCmdInPCR9(c *C) {
+	event := s.readEvent(c, 9, "grub_cmd: linux /vmlinuz\x00")
+	data, ok := event.Data.(*GrubStringEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Type, Equals, GrubCmd)
+	c.Check(data.Str, Equals, "linux /vmlinuz")
+	c.Check(data.Type.PCRIndex(), Not(Equals), event.PCRIndex)
+}
+
+func (s *grubeventdataSuite) TestDecodeFileInPCR9(c *C) {
+	event := s.readEvent(c, 9, "/vmlinuz\x00")
+	c.Check(event.Data, Equals, StringEventData("/vmlinuz\x00"))
+}
+
+func (s *grubeventdataSuite) TestDecodeFileInPCR8(c *C) {
+	event := s.readEvent(c, 8, "/vmlinuz\x00")
+	_, ok := event.Data.(*GrubStringEventData)
+	c.Check(ok, Equals, false)
+}
+
+func (s *grubeventdataSuite) TestComputeGrubStringEventDigestFromLog(c *C) {
+	log := readTestLog(c, &LogOptions{EnableGrub: true})
+
+	var n int
+	for _, event := range log.Events {
+		data, ok := event.Data.(*GrubStringEventData)
+		if !ok {
+			continue
+		}
+		n++
+		for alg, digest := range event.Digests {
+			c.Check(Digest(ComputeGrubStringEventDigest(alg.GetHash(), data)), DeepEquals, digest, Commentf("event data: %q", data.Bytes()))
+		}
+	}
+	c.Check(n > 0, Equals, true)
+}
+
+func (s *grubeventdataSuite) TestComputeGrubStringEventDigestCmd(c *C) {
+	event := s.readEvent(c, 8, "grub_cmd: linux /vmlinuz root=/dev/sda1\x00")
+	data, ok := event.Data.(*GrubStringEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(ComputeGrubStringEventDigest(crypto.SHA256, data), DeepEquals,
+		decodeHexString(c, "926d5e5013b583ad078b8cdf0ca5a405bc7bdf1f169cc8027309257d9d13bd0b"))
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/canonical/go-tpm2"
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/tcglog-parser/internal/ioerr"
+)
+
+// EventTypeIMA is the event type of events read from a Linux IMA runtime measurement
+// log by ReadIMALog. It isn't defined by any TCG specification, as IMA logs don't
+// record an event type.
+const EventTypeIMA EventType = 0x494d4100
+
+const (
+	// imaTemplateNameLenMax is the maximum length of a template name
+	// (IMA_TEMPLATE_NAME_LEN_MAX).
+	imaTemplateNameLenMax = 15
+
+	// imaEventNameLenMax is the length that the file name is padded to when
+	// computing the template hash for the original "ima" template
+	// (IMA_EVENT_NAME_LEN_MAX + 1).
+	imaEventNameLenMax = 256
+)
+
+// IMATemplateEventData is the event data for a measurement read from a Linux IMA runtime
+// measurement log. TemplateData is the template data in the format defined by the
+// template, which consists of a length-prefixed list of fields for every template other
+// than the original "ima" template.
+type IMATemplateEventData struct {
+	TemplateName string
+	TemplateData []byte
+}
+
+func (e *IMATemplateEventData) String() string {
+	return fmt.Sprintf("IMATemplate{ name: %s, data: %d bytes }", e.TemplateName, len(e.TemplateData))
+}
+
+func (e *IMATemplateEventData) Bytes() []byte {
+	return e.TemplateData
+}
+
+func (e *IMATemplateEventData) Write(w io.Writer) error {
+	_, err := w.Write(e.TemplateData)
+	return err
+}
+
+func (e *IMATemplateEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*IMATemplateEventData)
+	return ok && e.TemplateName == o.TemplateName && bytes.Equal(e.TemplateData, o.TemplateData)
+}
+
+// computeTemplateDigest computes the template hash for the specified algorithm, which
+// is the digest that IMA extends to the PCR bank for that algorithm.
+func (e *IMATemplateEventData) computeTemplateDigest(alg tpm2.HashAlgorithmId) (Digest, error) {
+	h := alg.NewHash()
+	if e.TemplateName != "ima" {
+		h.Write(e.TemplateData)
+		return h.Sum(nil), nil
+	}
+
+	// The original "ima" template contains a SHA-1 file digest followed by the
+	// length-prefixed file name, which is padded to a fixed size and hashed without
+	// its length.
+	r := bytes.NewReader(e.TemplateData)
+	var digest [20]byte
+	var nameLen uint32
+	if _, err := io.ReadFull(r, digest[:]); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read file digest: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read file name length: %w", err)
+	}
+	if nameLen >= imaEventNameLenMax || int(nameLen) != r.Len() {
+		return nil, fmt.Errorf("invalid file name length (%d)", nameLen)
+	}
+	name := make([]byte, imaEventNameLenMax)
+	r.Read(name[:nameLen])
+
+	h.Write(digest[:])
+	h.Write(name)
+	return h.Sum(nil), nil
+}
+
+// ReadIMALog reads the events from the Linux IMA runtime measurement log in the binary
+// format read from r, which is available from
+// /sys/kernel/security/ima/binary_runtime_measurements. Each entry consists of a PCR
+// index, a SHA-1 template hash, a length-prefixed template name and length-prefixed
+// template data. The log must be in little-endian byte order.
+//
+// The log only records the SHA-1 template hash, so the digest for each of the other
+// specified algorithms is computed from the template data in the same way that the
+// kernel does when extending the other PCR banks. Entries with a zero template hash
+// are measurement violations, which the kernel extends to each bank as a digest that
+// contains only 0xff bytes. The returned events have an event type of EventTypeIMA, and
+// the event data for each of them is a *IMATemplateEventData.
+//
+// If an error occurs during parsing, this may return an incomplete list of events with
+// the error.
+func ReadIMALog(r io.Reader, algs AlgorithmIdList) ([]*Event, error) {
+	for _, alg := range algs {
+		if !alg.Available() {
+			return nil, fmt.Errorf("digest algorithm %v is not available", alg)
+		}
+	}
+
+	var events []*Event
+	for i := 0; ; i++ {
+		var pcr PCRIndex
+		if err := binary.Read(r, binary.LittleEndian, &pcr); err != nil {
+			if err == io.EOF {
+				return events, nil
+			}
+			return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read PCR index: %w", i, err)
+		}
+		if !isPCRIndexInRange(pcr) {
+			return events, fmt.Errorf("cannot read event %d: out-of-range PCR index (%d)", i, pcr)
+		}
+
+		templateHash := make(Digest, tpm2.HashAlgorithmSHA1.Size())
+		if _, err := io.ReadFull(r, templateHash); err != nil {
+			return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template hash: %w", i, err)
+		}
+
+		var nameLen uint32
+		if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil {
+			return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template name length: %w", i, err)
+		}
+		if nameLen == 0 || nameLen > imaTemplateNameLenMax {
+			return events, fmt.Errorf("cannot read event %d: invalid template name length (%d)", i, nameLen)
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template name: %w", i, err)
+		}
+
+		data := &IMATemplateEventData{TemplateName: string(name)}
+		if data.TemplateName == "ima" {
+			// The original template data isn't length-prefixed.
+			var fixed [24]byte
+			if _, err := io.ReadFull(r, fixed[:]); err != nil {
+				return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template data: %w", i, err)
+			}
+			n := binary.LittleEndian.Uint32(fixed[20:])
+			if n >= imaEventNameLenMax {
+				return events, fmt.Errorf("cannot read event %d: invalid file name length (%d)", i, n)
+			}
+			record, err := readEventRecord(r, fixed[:], n)
+			if err != nil {
+				return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template data: %w", i, err)
+			}
+			data.TemplateData = record
+		} else {
+			var n uint32
+			if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+				return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template data length: %w", i, err)
+			}
+			record, err := readEventRecord(r, nil, n)
+			if err != nil {
+				return events, ioerr.EOFIsUnexpected("cannot read event %d: cannot read template data: %w", i, err)
+			}
+			data.TemplateData = record
+		}
+
+		violation := templateHash.Equal(ZeroDigest(tpm2.HashAlgorithmSHA1))
+
+		digests := make(DigestMap)
+		for _, alg := range algs {
+			switch {
+			case violation:
+				digests[alg] = Digest(bytes.Repeat([]byte{0xff}, alg.Size()))
+			case alg == tpm2.HashAlgorithmSHA1:
+				digests[alg] = templateHash
+			default:
+				digest, err := data.computeTemplateDigest(alg)
+				if err != nil {
+					return events, xerrors.Errorf("cannot compute %v template hash for event %d: %w", alg, i, err)
+				}
+				digests[alg] = digest
+			}
+		}
+
+		events = append(events, &Event{
+			PCRIndex:  pcr,
+			EventType: EventTypeIMA,
+			Digests:   digests,
+			Data:      data})
+	}
+}
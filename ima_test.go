@@ -0,0 +1,160 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"io"
+
+	"github.com/canonical/go-tpm2"
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type imaSuite struct{}
+
+var _ = Suite(&imaSuite{})
+
+// imaNgTemplateData returns the template data for an ima-ng entry with the supplied
+// file digest and path.
+func (s *imaSuite) imaNgTemplateData(c *C, digest []byte, path string) []byte {
+	w := new(bytes.Buffer)
+	d := append([]byte("sha256:\x00"), digest...)
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(len(d))), IsNil)
+	w.Write(d)
+	n := append([]byte(path), 0)
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(len(n))), IsNil)
+	w.Write(n)
+	return w.Bytes()
+}
+
+// writeIMAEntry writes an entry for a template other than "ima" to w.
+func (s *imaSuite) writeIMAEntry(c *C, w io.Writer, pcr PCRIndex, templateHash []byte, name string, data []byte) {
+	c.Check(binary.Write(w, binary.LittleEndian, pcr), IsNil)
+	w.Write(templateHash)
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(len(name))), IsNil)
+	w.Write([]byte(name))
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(len(data))), IsNil)
+	w.Write(data)
+}
+
+func (s *imaSuite) TestReadIMALogImaNg(c *C) {
+	data := s.imaNgTemplateData(c, ComputeEventDigest(crypto.SHA256, []byte("foo")), "/usr/bin/foo")
+	w := new(bytes.Buffer)
+	s.writeIMAEntry(c, w, 10, ComputeEventDigest(crypto.SHA1, data), "ima-ng", data)
+
+	events, err := ReadIMALog(w, AlgorithmIdList{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].PCRIndex, Equals, PCRIndex(10))
+	c.Check(events[0].EventType, Equals, EventTypeIMA)
+	c.Check(events[0].Digests, DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   ComputeEventDigest(crypto.SHA1, data),
+		tpm2.HashAlgorithmSHA256: ComputeEventDigest(crypto.SHA256, data)})
+	c.Check(events[0].Data, DeepEquals, &IMATemplateEventData{TemplateName: "ima-ng", TemplateData: data})
+	c.Check(events[0].Data.String(), Equals, "IMATemplate{ name: ima-ng, data: 61 bytes }")
+}
+
+func (s *imaSuite) TestReadIMALogIma(c *C) {
+	fileDigest := ComputeEventDigest(crypto.SHA1, []byte("foo"))
+	path := "boot_aggregate"
+
+	hashed := make([]byte, 20+256)
+	copy(hashed, fileDigest)
+	copy(hashed[20:], path)
+
+	w := new(bytes.Buffer)
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(10)), IsNil)
+	w.Write(ComputeEventDigest(crypto.SHA1, hashed))
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(3)), IsNil)
+	w.Write([]byte("ima"))
+	w.Write(fileDigest)
+	c.Check(binary.Write(w, binary.LittleEndian, uint32(len(path))), IsNil)
+	w.Write([]byte(path))
+
+	events, err := ReadIMALog(w, AlgorithmIdList{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Digests, DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   ComputeEventDigest(crypto.SHA1, hashed),
+		tpm2.HashAlgorithmSHA256: ComputeEventDigest(crypto.SHA256, hashed)})
+
+	data, ok := events[0].Data.(*IMATemplateEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.TemplateName, Equals, "ima")
+	expectedData := new(bytes.Buffer)
+	expectedData.Write(fileDigest)
+	expectedData.Write([]byte{0x0e, 0x00, 0x00, 0x00})
+	expectedData.Write([]byte(path))
+	c.Check(data.TemplateData, DeepEquals, expectedData.Bytes())
+}
+
+func (s *imaSuite) TestReadIMALogViolation(c *C) {
+	data := s.imaNgTemplateData(c, make([]byte, 32), "/var/log/foo")
+	w := new(bytes.Buffer)
+	s.writeIMAEntry(c, w, 10, make([]byte, 20), "ima-ng", data)
+
+	events, err := ReadIMALog(w, AlgorithmIdList{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Digests, DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   Digest(bytes.Repeat([]byte{0xff}, 20)),
+		tpm2.HashAlgorithmSHA256: Digest(bytes.Repeat([]byte{0xff}, 32))})
+}
+
+func (s *imaSuite) TestReadIMALogTruncated(c *C) {
+	data := s.imaNgTemplateData(c, make([]byte, 32), "/usr/bin/foo")
+	w := new(bytes.Buffer)
+	s.writeIMAEntry(c, w, 10, ComputeEventDigest(crypto.SHA1, data), "ima-ng", data)
+	s.writeIMAEntry(c, w, 10, ComputeEventDigest(crypto.SHA1, data), "ima-ng", data)
+
+	events, err := ReadIMALog(bytes.NewReader(w.Bytes()[:w.Len()-4]), AlgorithmIdList{tpm2.HashAlgorithmSHA1})
+	c.Check(err, ErrorMatches, `cannot read event 1: cannot read template data: .*`)
+	c.Check(xerrors.Is(err, io.ErrUnexpectedEOF), Equals, true)
+	c.Check(events, HasLen, 1)
+}
+
+func (s *imaSuite) TestReadIMALogInvalidTemplateName(c *C) {
+	w := new(bytes.Buffer)
+	s.writeIMAEntry(c, w, 10, make([]byte, 20), "", nil)
+
+	_, err := ReadIMALog(w, AlgorithmIdList{tpm2.HashAlgorithmSHA1})
+	c.Check(err, ErrorMatches, `cannot read event 0: invalid template name length \(0\)`)
+}
+
+func (s *imaSuite) TestReadCompleteLogWithIMALog(c *C) {
+	expected := readTestLog(c, &LogOptions{})
+	main := new(bytes.Buffer)
+	c.Assert(expected.Write(main), IsNil)
+
+	ima := new(bytes.Buffer)
+	var datas [][]byte
+	for _, path := range []string{"/usr/bin/foo", "/usr/bin/bar"} {
+		data := s.imaNgTemplateData(c, ComputeEventDigest(crypto.SHA256, []byte(path)), path)
+		s.writeIMAEntry(c, ima, 10, ComputeEventDigest(crypto.SHA1, data), "ima-ng", data)
+		datas = append(datas, data)
+	}
+
+	log, err := ReadCompleteLog(main, &CompleteLogSources{IMALog: ima}, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(log.Events, HasLen, len(expected.Events)+2)
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+
+	pcr10 := make([]byte, 32)
+	for _, data := range datas {
+		h := crypto.SHA256.New()
+		h.Write(pcr10)
+		h.Write(ComputeEventDigest(crypto.SHA256, data))
+		pcr10 = h.Sum(nil)
+	}
+	c.Check(pcrs[10][tpm2.HashAlgorithmSHA256], DeepEquals, Digest(pcr10))
+}
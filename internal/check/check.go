@@ -0,0 +1,864 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package check implements the log checks performed by tcglog-check and the check
+// subcommand of tcglog-dump.
+package check
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/canonical/go-efilib"
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/linux"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/tcglog-parser"
+	internal_flags "github.com/canonical/tcglog-parser/internal/flags"
+)
+
+// Options controls which checks are performed. The struct tags describe the
+// corresponding command-line flags.
+type Options struct {
+	WithGrub               bool                             `long:"with-grub" description:"Validate log entries measured by GRUB to PCRs 8 and 9"`
+	WithSystemdEFIStub     *tcglog.PCRIndex                 `long:"with-systemd-efi-stub" description:"Validate log entries measured by systemd's EFI stub Linux loader to the specified PCR" optional:"true" optional-value:"8"`
+	WithSystemdBoot        bool                             `long:"with-systemd-boot" description:"Decode EV_EVENT_TAG log entries measured by systemd-boot and systemd's EFI stub Linux loader"`
+	Pcrs                   internal_flags.PCRRange          `short:"p" long:"pcrs" description:"Validate log entries associated with the specified PCRs. Can be specified multiple times" default:"0-7"`
+	TpmPath                string                           `long:"tpm-path" description:"Validate log entries associated with the specified TPM" default:"/dev/tpm0"`
+	IgnoreDataDecodeErrors bool                             `long:"ignore-data-decode-errors" description:"Don't exit with an error if any event data fails to decode correctly"`
+	SkipDigestChecks       bool                             `long:"skip-digest-checks" description:"Don't check that event digests are consistent with the data recorded in the log or with images found in the boot image search paths"`
+	CheckSeparators        bool                             `long:"check-separators" description:"Check that none of PCRs 0-7 contain more than one EV_SEPARATOR event"`
+	CheckEventOrder        bool                             `long:"check-event-order" description:"Check that none of PCRs 0-7 contain events that can only be measured in the pre-OS phase after the EV_SEPARATOR event"`
+	DisabledAlgs           []internal_flags.HashAlgorithmId `long:"disable-alg" description:"Don't compute digests or check PCR values for the specified algorithm. Can be specified multiple times" choice:"sha1" choice:"sha256" choice:"sha384" choice:"sha512"`
+	RequiredAlgs           []internal_flags.HashAlgorithmId `long:"require-alg" description:"Require the specified algorithms to be present in the log. Can be specified multiple times" choice:"sha1" choice:"sha256" choice:"sha384" choice:"sha512"`
+	BootImageSearchPaths   []string                         `long:"boot-image-search-path" description:"Specify a path to search for images executed during boot and measured to PCR 4 with EV_EFI_BOOT_SERVICES_APPLICATION events. Can be specified multiple times" default:"/boot" default:"/cdrom/EFI" default:"/cdrom/casper"`
+	EfiVariableBootQuirk   bool                             `long:"efi-variable-boot-quirk" description:"Accept EV_EFI_VARIABLE_BOOT events that measure the entire UEFI_VARIABLE_DATA structure rather than only the variable data"`
+}
+
+var opts Options
+
+type peImageHashes struct {
+	peHash   []byte
+	fileHash []byte
+}
+
+var peImageDataCache map[tpm2.HashAlgorithmId]map[string]*peImageHashes
+
+// canComputeAlg indicates whether digests can be computed for the specified algorithm.
+func canComputeAlg(alg tpm2.HashAlgorithmId) bool {
+	if !alg.Available() {
+		return false
+	}
+	for _, disabled := range opts.DisabledAlgs {
+		if tpm2.HashAlgorithmId(disabled) == alg {
+			return false
+		}
+	}
+	return true
+}
+
+// checkedAlgs returns the algorithms from the supplied log that will be checked.
+func checkedAlgs(log *tcglog.Log) (out tcglog.AlgorithmIdList) {
+	for _, alg := range log.Algorithms {
+		if canComputeAlg(alg) {
+			out = append(out, alg)
+		}
+	}
+	return out
+}
+
+func populatePeImageDataCache(algorithms tcglog.AlgorithmIdList) {
+	peImageDataCache = make(map[tpm2.HashAlgorithmId]map[string]*peImageHashes)
+	for _, alg := range algorithms {
+		peImageDataCache[alg] = make(map[string]*peImageHashes)
+	}
+
+	dirs := make([]string, len(opts.BootImageSearchPaths))
+	copy(dirs, opts.BootImageSearchPaths)
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+
+		f, err := os.Open(dir)
+		if err != nil {
+			continue
+		}
+		func() {
+			defer f.Close()
+			dirInfo, err := f.Readdir(-1)
+			if err != nil {
+				return
+			}
+
+			for _, fi := range dirInfo {
+				path := filepath.Join(dir, fi.Name())
+				switch {
+				case fi.IsDir():
+					dirs = append(dirs, path)
+				case fi.Mode().IsRegular():
+					f, err := os.Open(path)
+					if err != nil {
+						continue
+					}
+					func() {
+						defer f.Close()
+						fi, err := f.Stat()
+						if err != nil {
+							return
+						}
+						for _, alg := range algorithms {
+							if !canComputeAlg(alg) {
+								continue
+							}
+							peHash, err := efi.ComputePeImageDigest(alg.GetHash(), f, fi.Size())
+							if err != nil {
+								continue
+							}
+							h := alg.GetHash().New()
+							if _, err := io.Copy(h, f); err != nil {
+								continue
+							}
+							fileHash := h.Sum(nil)
+							fmt.Printf("Computed %v for PE image %s - file:%x, authenticode:%x\n", alg, path, fileHash, peHash)
+							peImageDataCache[alg][path] = &peImageHashes{peHash: peHash, fileHash: fileHash}
+						}
+					}()
+				}
+			}
+		}()
+	}
+
+	fmt.Println("")
+}
+
+func pcrIndexListToSelect(l []tcglog.PCRIndex) (out tpm2.PCRSelect) {
+	for _, i := range l {
+		out = append(out, int(i))
+	}
+	return
+}
+
+func readPCRs(algorithms tcglog.AlgorithmIdList) (result map[tcglog.PCRIndex]tcglog.DigestMap, err error) {
+	tcti, err := linux.OpenDevice(opts.TpmPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open TPM device: %v", err)
+	}
+	tpm := tpm2.NewTPMContext(tcti)
+	defer tpm.Close()
+
+	if !tpm.IsTPM2() {
+		return nil, errors.New("not a valid TPM2 device")
+	}
+
+	result = make(map[tcglog.PCRIndex]tcglog.DigestMap)
+
+	var selections tpm2.PCRSelectionList
+	for _, alg := range algorithms {
+		selections = append(selections, tpm2.PCRSelection{Hash: alg, Select: pcrIndexListToSelect(opts.Pcrs)})
+	}
+
+	for _, i := range opts.Pcrs {
+		result[i] = tcglog.DigestMap{}
+	}
+
+	_, digests, err := tpm.PCRRead(selections)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read PCR values: %v", err)
+	}
+
+	for _, s := range selections {
+		for _, i := range s.Select {
+			result[tcglog.PCRIndex(i)][s.Hash] = tcglog.Digest(digests[s.Hash][i])
+		}
+	}
+
+	return result, nil
+}
+
+type incorrectDigestValue struct {
+	algorithm tpm2.HashAlgorithmId
+	expected  tcglog.Digest
+	measured  tcglog.Digest // The digest recorded in the event, or nil if it is absent
+}
+
+type incorrectPeImageDigest struct {
+	algorithm tpm2.HashAlgorithmId
+	imagePath string
+	measured  tcglog.Digest // The digest recorded in the event, or nil if it is absent
+}
+
+// measuredDigest returns a copy of the digest recorded in the supplied event for the
+// specified algorithm, or nil if the event doesn't have a digest for it.
+func measuredDigest(event *tcglog.Event, alg tpm2.HashAlgorithmId) tcglog.Digest {
+	if !event.HasBank(alg) {
+		return nil
+	}
+	return append(tcglog.Digest(nil), event.Digests[alg]...)
+}
+
+// formatMeasuredDigest returns a string representation of a digest returned from
+// measuredDigest, which makes it clear if the digest is absent.
+func formatMeasuredDigest(digest tcglog.Digest) string {
+	if digest == nil {
+		return "<absent>"
+	}
+	return fmt.Sprintf("%x", digest)
+}
+
+// formatAlgorithms returns a comma separated list of the supplied algorithms in
+// ascending order.
+func formatAlgorithms(algs tcglog.AlgorithmIdList) string {
+	algs = append(tcglog.AlgorithmIdList(nil), algs...)
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+
+	var s []string
+	for _, alg := range algs {
+		s = append(s, fmt.Sprintf("%v", alg))
+	}
+	return strings.Join(s, ",")
+}
+
+type checkedEvent struct {
+	*tcglog.Event
+	index                   uint
+	incorrectDigestValues   []incorrectDigestValue
+	verifiedAlgs            tcglog.AlgorithmIdList // The algorithms for which the digest is consistent with the event data
+	peImagePath             string
+	incorrectPeImageDigests []incorrectPeImageDigest
+	duplicateSeparator      bool
+	unexpectedGrubPCR       bool
+	typeNotInSpec           bool
+	unknownType             bool
+	afterSeparator          bool
+	efiVariableBootQuirk    bool // The digest only matched with EfiVariableBootQuirk
+}
+
+// inconsistentBanks indicates whether the digests for some algorithms are consistent with
+// the event data whilst the digests for other algorithms are not.
+func (e *checkedEvent) inconsistentBanks() bool {
+	return len(e.verifiedAlgs) > 0 && len(e.incorrectDigestValues) > 0
+}
+
+func (e *checkedEvent) extendsPCR() bool {
+	if e.EventType == tcglog.EventTypeNoAction {
+		return false
+	}
+	return true
+}
+
+func (e *checkedEvent) dataDecoderErr() error {
+	if err, isErr := e.Data.(error); isErr {
+		return err
+	}
+	return nil
+}
+
+func (e *checkedEvent) expectedDigest(alg tpm2.HashAlgorithmId, spec tcglog.Spec) []byte {
+	if err := e.dataDecoderErr(); err != nil {
+		return nil
+	}
+	if _, ok := e.Data.(*tcglog.SystemdBootEventData); ok {
+		// These events measure the data they describe rather than the event data.
+		return nil
+	}
+
+	switch e.EventType {
+	case tcglog.EventTypeNoAction:
+		return tcglog.ZeroDigest(alg)
+	case tcglog.EventTypeEventTag, tcglog.EventTypeSCRTMVersion, tcglog.EventTypePlatformConfigFlags, tcglog.EventTypeTableOfDevices, tcglog.EventTypeNonhostInfo, tcglog.EventTypeOmitBootDeviceEvents:
+		return tcglog.ComputeEventDigest(alg.GetHash(), e.Data.Bytes())
+	case tcglog.EventTypeSeparator:
+		return tcglog.ComputeSeparatorEventDigest(alg.GetHash(), e.Data.(*tcglog.SeparatorEventData).Value)
+	case tcglog.EventTypeAction, tcglog.EventTypeEFIAction:
+		return tcglog.ComputeStringEventDigest(alg.GetHash(), string(e.Data.(tcglog.StringEventData)))
+	case tcglog.EventTypeEFIVariableDriverConfig, tcglog.EventTypeEFIVariableAuthority, tcglog.EventTypeEFIVariableBoot2:
+		// These events measure the entire UEFI_VARIABLE_DATA structure.
+		data := e.Data.(*tcglog.EFIVariableData)
+		return tcglog.ComputeEFIVariableDataDigest(alg.GetHash(), data.UnicodeName, data.VariableName, data.VariableData)
+	case tcglog.EventTypeEFIVariableBoot:
+		// EV_EFI_VARIABLE_BOOT events only measure the variable data in logs for TPM family
+		// 2.0, although some firmware implementations measure the entire UEFI_VARIABLE_DATA
+		// structure. Logs for TPM family 1.2 always measure the entire structure.
+		data := e.Data.(*tcglog.EFIVariableData)
+		return tcglog.ComputeEFIVariableBootDigest(alg.GetHash(), spec, data.UnicodeName, data.VariableName, data.VariableData)
+	case tcglog.EventTypeEFIGPTEvent:
+		return tcglog.ComputeEventDigest(alg.GetHash(), e.Data.Bytes())
+	case tcglog.EventTypeIPL:
+		switch d := e.Data.(type) {
+		case *tcglog.GrubStringEventData:
+			return tcglog.ComputeGrubStringEventDigest(alg.GetHash(), d)
+		case *tcglog.SystemdEFIStubCommandline:
+			return tcglog.ComputeSystemdEFIStubCommandlineDigest(alg.GetHash(), d.Str)
+		}
+	}
+
+	return nil
+}
+
+// matchesEFIVariableBootQuirk indicates whether this is an EV_EFI_VARIABLE_BOOT event in a
+// log for TPM family 2.0 with a digest for the specified algorithm that measures the entire
+// UEFI_VARIABLE_DATA structure, which is only accepted if EfiVariableBootQuirk is set.
+func (e *checkedEvent) matchesEFIVariableBootQuirk(alg tpm2.HashAlgorithmId, spec tcglog.Spec) bool {
+	if !opts.EfiVariableBootQuirk || e.EventType != tcglog.EventTypeEFIVariableBoot || !spec.IsEFI_2() {
+		return false
+	}
+	data, ok := e.Data.(*tcglog.EFIVariableData)
+	if !ok {
+		return false
+	}
+	return e.Digests[alg].Equal(tcglog.ComputeEFIVariableDataDigest(alg.GetHash(), data.UnicodeName, data.VariableName, data.VariableData))
+}
+
+func checkEvent(event *tcglog.Event, c *logChecker) (out *checkedEvent) {
+	out = &checkedEvent{Event: event}
+
+	if opts.SkipDigestChecks {
+		return
+	}
+
+	for alg, digest := range out.Digests {
+		if !canComputeAlg(alg) {
+			// We can't compute digests for this algorithm.
+			continue
+		}
+		expectedDigest := out.expectedDigest(alg, c.spec)
+		if expectedDigest == nil {
+			break
+		}
+
+		if !digest.Equal(expectedDigest) && out.matchesEFIVariableBootQuirk(alg, c.spec) {
+			out.efiVariableBootQuirk = true
+			out.verifiedAlgs = append(out.verifiedAlgs, alg)
+			continue
+		}
+
+		if !digest.Equal(expectedDigest) {
+			// Invalid digest. Record the expected digest on the event.
+			out.incorrectDigestValues = append(out.incorrectDigestValues, incorrectDigestValue{algorithm: alg, expected: expectedDigest, measured: measuredDigest(out.Event, alg)})
+		} else {
+			out.verifiedAlgs = append(out.verifiedAlgs, alg)
+		}
+	}
+
+	if out.PCRIndex != 4 {
+		return
+	}
+	if out.EventType != tcglog.EventTypeEFIBootServicesApplication {
+		return
+	}
+
+	for alg, digest := range out.Digests {
+		if !canComputeAlg(alg) {
+			continue
+		}
+		ok := false
+		if out.peImagePath == "" {
+			for path, hashes := range peImageDataCache[alg] {
+				switch {
+				case digest.Equal(hashes.peHash):
+					out.peImagePath = path
+					ok = true
+				case digest.Equal(hashes.fileHash):
+					out.peImagePath = path
+				}
+			}
+		} else {
+			hashes := peImageDataCache[alg][out.peImagePath]
+			if digest.Equal(hashes.peHash) {
+				ok = true
+			}
+		}
+
+		if !ok {
+			out.incorrectPeImageDigests = append(out.incorrectPeImageDigests, incorrectPeImageDigest{algorithm: alg, imagePath: out.peImagePath, measured: measuredDigest(out.Event, alg)})
+		}
+	}
+	return
+}
+
+type logChecker struct {
+	spec                        tcglog.Spec
+	indexTracker                map[tcglog.PCRIndex]uint
+	expectedPCRValues           map[tcglog.PCRIndex]tcglog.DigestMap
+	separatorTracker            map[tcglog.PCRIndex]uint
+	events                      []*checkedEvent
+	seenIncorrectDigests        bool
+	seenInconsistentBanks       bool
+	seenIncorrectPeImageDigests bool
+	seenDuplicateSeparators     bool
+	seenUnexpectedGrubPCRs      bool
+	seenEventTypesNotInSpec     bool
+	seenUnknownEventTypes       bool
+	seenMisorderedEvents        bool
+	seenBootDeviceEvents        bool
+	seenEFIVariableBootQuirk    bool
+}
+
+func (c *logChecker) checkGrubPCR(event *checkedEvent) {
+	if !opts.WithGrub || event.EventType != tcglog.EventTypeIPL {
+		return
+	}
+
+	switch d := event.Data.(type) {
+	case *tcglog.GrubStringEventData:
+		event.unexpectedGrubPCR = event.PCRIndex != d.Type.PCRIndex()
+	default:
+		// GRUB only measures commands and kernel commandlines to PCR 8.
+		event.unexpectedGrubPCR = event.PCRIndex == 8
+	}
+	if event.unexpectedGrubPCR {
+		c.seenUnexpectedGrubPCRs = true
+	}
+}
+
+func (c *logChecker) checkEventTypeSpec(event *checkedEvent) {
+	if _, known := event.EventType.MinSpec(); !known {
+		// Vendor defined or unknown event types are reported separately.
+		event.unknownType = true
+		c.seenUnknownEventTypes = true
+		return
+	}
+	if c.spec.DefinesEventType(event.EventType) {
+		return
+	}
+	event.typeNotInSpec = true
+	c.seenEventTypesNotInSpec = true
+}
+
+func (c *logChecker) trackSeparator(event *checkedEvent) {
+	if event.PCRIndex > 7 {
+		return
+	}
+	if c.separatorTracker[event.PCRIndex] > 0 && event.IsPreOSOnly() {
+		event.afterSeparator = true
+		c.seenMisorderedEvents = true
+	}
+	if event.EventType != tcglog.EventTypeSeparator {
+		return
+	}
+
+	c.separatorTracker[event.PCRIndex]++
+	if c.separatorTracker[event.PCRIndex] > 1 {
+		event.duplicateSeparator = true
+		c.seenDuplicateSeparators = true
+	}
+}
+
+func (c *logChecker) trackBootDeviceEvent(event *checkedEvent) {
+	if event.PCRIndex != 4 {
+		return
+	}
+
+	switch event.EventType {
+	case tcglog.EventTypeIPL, tcglog.EventTypeEFIBootServicesApplication:
+		c.seenBootDeviceEvents = true
+	}
+}
+
+func (c *logChecker) simulatePCRExtend(event *checkedEvent) {
+	if !event.extendsPCR() {
+		return
+	}
+
+	for alg, digest := range event.Digests {
+		if !canComputeAlg(alg) {
+			continue
+		}
+		h := alg.GetHash().New()
+		h.Write(c.expectedPCRValues[event.PCRIndex][alg])
+		h.Write(digest)
+		c.expectedPCRValues[event.PCRIndex][alg] = h.Sum(nil)
+	}
+}
+
+func (c *logChecker) processEvent(event *tcglog.Event) {
+	if !opts.Pcrs.Contains(event.PCRIndex) {
+		return
+	}
+
+	ce := checkEvent(event, c)
+	if len(ce.incorrectDigestValues) > 0 {
+		c.seenIncorrectDigests = true
+	}
+	if ce.inconsistentBanks() {
+		c.seenInconsistentBanks = true
+	}
+	if len(ce.incorrectPeImageDigests) > 0 {
+		c.seenIncorrectPeImageDigests = true
+	}
+	if ce.efiVariableBootQuirk {
+		c.seenEFIVariableBootQuirk = true
+	}
+
+	c.trackSeparator(ce)
+	c.checkGrubPCR(ce)
+	c.checkEventTypeSpec(ce)
+	c.trackBootDeviceEvent(ce)
+	c.simulatePCRExtend(ce)
+	ce.index = c.indexTracker[ce.PCRIndex]
+	c.events = append(c.events, ce)
+	c.indexTracker[ce.PCRIndex] = ce.index + 1
+}
+
+func (c *logChecker) run(log *tcglog.Log) {
+	c.spec = log.Spec
+	c.indexTracker = make(map[tcglog.PCRIndex]uint)
+	c.separatorTracker = make(map[tcglog.PCRIndex]uint)
+	c.expectedPCRValues = make(map[tcglog.PCRIndex]tcglog.DigestMap)
+	for _, pcr := range opts.Pcrs {
+		c.expectedPCRValues[pcr] = tcglog.DigestMap{}
+
+		for _, alg := range checkedAlgs(log) {
+			c.expectedPCRValues[pcr][alg] = make(tcglog.Digest, alg.Size())
+		}
+	}
+
+	for _, event := range log.Events {
+		c.processEvent(event)
+	}
+}
+
+// Run checks the log read from logPath and prints a report of what it finds. If
+// logPath is empty, the log is read from the kernel for the TPM specified by
+// Options.TpmPath and the PCR values from the log are checked against those read
+// from that TPM. An error is returned if any of the checks fail.
+func Run(options *Options, logPath string) error {
+	opts = *options
+
+	if logPath == "" {
+		if filepath.Dir(opts.TpmPath) != "/dev" {
+			return errors.New("expected TPM path to be a device node in /dev")
+		}
+		logPath = fmt.Sprintf("/sys/kernel/security/%s/binary_bios_measurements", filepath.Base(opts.TpmPath))
+	} else {
+		opts.TpmPath = ""
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	failed := false
+
+	logOpts := tcglog.LogOptions{EnableGrub: opts.WithGrub, EnableSystemdBoot: opts.WithSystemdBoot}
+	for _, alg := range opts.DisabledAlgs {
+		logOpts.DisabledAlgorithms = append(logOpts.DisabledAlgorithms, tpm2.HashAlgorithmId(alg))
+	}
+	if opts.WithSystemdEFIStub != nil {
+		logOpts.EnableSystemdEFIStub = true
+		logOpts.SystemdEFIStubPCR = *opts.WithSystemdEFIStub
+	}
+	log, err := tcglog.ReadLog(f, &logOpts)
+	if err != nil {
+		return xerrors.Errorf("cannot read log: %w", err)
+	}
+
+	missingAlg := false
+	for _, alg := range opts.RequiredAlgs {
+		if log.Algorithms.Contains(tpm2.HashAlgorithmId(alg)) {
+			continue
+		}
+
+		if !missingAlg {
+			missingAlg = true
+			failed = true
+			fmt.Printf("*** FAIL ***: The log is missing the following required algorithms:\n")
+		}
+
+		fmt.Printf("\t- %s\n", tpm2.HashAlgorithmId(alg))
+	}
+	if missingAlg {
+		fmt.Printf("\n")
+	}
+
+	if !opts.SkipDigestChecks {
+		populatePeImageDataCache(checkedAlgs(log))
+	}
+
+	c := &logChecker{}
+	c.run(log)
+
+	var dataDecoderErrs []string
+	for _, e := range c.events {
+		err := e.dataDecoderErr()
+		if err == nil {
+			continue
+		}
+
+		dataDecoderErrs = append(dataDecoderErrs, fmt.Sprintf("\t- Event %d in PCR %d (type: %s): %v\n", e.index, e.PCRIndex, e.EventType, err))
+	}
+	if len(dataDecoderErrs) > 0 {
+		if !opts.IgnoreDataDecodeErrors {
+			fmt.Printf("*** FAIL ***")
+			failed = true
+		} else {
+			fmt.Printf("- INFO")
+		}
+		fmt.Printf(": The following events contain event data that was not in the expected format and could not be decoded correctly:\n")
+		for _, err := range dataDecoderErrs {
+			fmt.Printf("%s", err)
+		}
+		fmt.Printf("This might be a bug in the firmware or bootloader code responsible for performing these measurements.\n\n")
+	}
+
+	if c.seenIncorrectDigests {
+		failed = true
+		hasBootVar := false
+		fmt.Printf("*** FAIL ***: The following events have digests that aren't consistent with the data recorded with them in the log:\n")
+		for _, e := range c.events {
+			if len(e.incorrectDigestValues) == 0 {
+				continue
+			}
+
+			if e.EventType == tcglog.EventTypeEFIVariableBoot {
+				hasBootVar = true
+			}
+
+			for _, d := range e.incorrectDigestValues {
+				fmt.Printf("\t- Event %d in PCR %d (type: %s, alg: %s) - expected (from data): %x, got: %s\n", e.index, e.PCRIndex, e.EventType, d.algorithm, d.expected, formatMeasuredDigest(d.measured))
+			}
+		}
+		fmt.Printf("This is unexpected for these event types, and might indicate a bug in the firmware of bootloader code responsible " +
+			"for performing these measurements. Knowledge of the format of the data being measured is required in order to pre-compute " +
+			"digests for these events or by a remote verifier for attestation purposes.\n")
+		if hasBootVar {
+			fmt.Printf("Note that some firmware implementations measure a tagged hash of the event data for EV_EFI_VARIABLE_BOOT " +
+				"events, but earlier versions of the TCG PC Client Platform Firmware Profile Specification are a bit ambiguous " +
+				"about whether this is correct or whether only a tagged hash of the variable data should be measured. " +
+				"EDK2 only measures a tagged hash of the variable data, and the 1.05 revision of the TCG PC Client Platform " +
+				"Firmware Profile Specification is more explicit - it says that only a tagged hash of the variable data must " +
+				"be measured. It also deprecates EV_EFI_VARIABLE_BOOT in favour of EV_EFI_VARIABLE_BOOT2 which specifies that " +
+				"a tagged hash of the event data must be measured. Use --efi-variable-boot-quirk to accept these events.\n")
+		}
+		fmt.Printf("\n")
+	}
+
+	if c.seenInconsistentBanks {
+		failed = true
+		fmt.Printf("*** FAIL ***: The following events have digests that are consistent with the data recorded with them in the log " +
+			"for some algorithms but not for others:\n")
+		for _, e := range c.events {
+			if !e.inconsistentBanks() {
+				continue
+			}
+
+			var incorrectAlgs tcglog.AlgorithmIdList
+			for _, d := range e.incorrectDigestValues {
+				incorrectAlgs = append(incorrectAlgs, d.algorithm)
+			}
+			fmt.Printf("\t- Event %d in PCR %d (type: %s) - consistent: %s, inconsistent: %s\n", e.index, e.PCRIndex, e.EventType,
+				formatAlgorithms(e.verifiedAlgs), formatAlgorithms(incorrectAlgs))
+		}
+		fmt.Printf("The digests for each algorithm in an event are expected to be computed from the same data. A digest that is " +
+			"inconsistent in only some banks might indicate a bug in the firmware's implementation of one of the digest " +
+			"algorithms, or that the log has been tampered with.\n\n")
+	}
+
+	if opts.EfiVariableBootQuirk && !opts.SkipDigestChecks {
+		if c.seenEFIVariableBootQuirk {
+			fmt.Printf("- INFO: The following EV_EFI_VARIABLE_BOOT events measure the entire UEFI_VARIABLE_DATA structure and " +
+				"were only accepted because --efi-variable-boot-quirk was specified:\n")
+			for _, e := range c.events {
+				if !e.efiVariableBootQuirk {
+					continue
+				}
+				fmt.Printf("\t- Event %d in PCR %d (variable: %s)\n", e.index, e.PCRIndex, e.Data.(*tcglog.EFIVariableData).UnicodeName)
+			}
+			fmt.Printf("\n")
+		} else {
+			fmt.Printf("- INFO: --efi-variable-boot-quirk was specified, but none of the EV_EFI_VARIABLE_BOOT events required it. " +
+				"This option is not necessary for this log, and specifying it unnecessarily might hide incorrect digests.\n\n")
+		}
+	}
+
+	if c.seenIncorrectPeImageDigests {
+		failed = true
+		fmt.Printf("*** FAIL ***: The following EV_EFI_BOOT_SERVICES_APPLICATION events contain digests that might be invalid:\n")
+		for _, e := range c.events {
+			if len(e.incorrectPeImageDigests) == 0 {
+				continue
+			}
+
+			for _, d := range e.incorrectPeImageDigests {
+				if d.imagePath == "" {
+					fmt.Printf("\t- Event %d in PCR 4 has a digest for alg %s that doesn't correspond to any PE image (got: %s)\n", e.index, d.algorithm, formatMeasuredDigest(d.measured))
+				} else if hashes, ok := peImageDataCache[d.algorithm][d.imagePath]; !ok {
+					fmt.Printf("\t- Event %d in PCR 4 (%s) has a digest for alg %s that doesn't correspond to any PE image (got: %s)\n", e.index, d.imagePath, d.algorithm, formatMeasuredDigest(d.measured))
+				} else {
+					fmt.Printf("\t- Event %d in PCR 4 (%s) has a digest for alg %s that matches the file digest rather than the PE image digest (got: %s, expected: %x)\n", e.index, d.imagePath, d.algorithm, formatMeasuredDigest(d.measured), hashes.peHash)
+				}
+			}
+		}
+		fmt.Printf("Event digests that don't correspond to any PE image might be caused by a bug in the firmware or bootloader "+
+			"code responsible for performing the measurements, or might be because the image was loaded from a location "+
+			"that is not currently mounted at an expected path (%s), in which case it is not possible to determine if "+
+			"the digests are correct. The presence of file digests rather than PE image digests might be because the "+
+			"measuring bootloader is using the 1.2 version of the TCG EFI Protocol Specification rather than the 2.0 "+
+			"version (which could be because it is not provided by the firmware). It could also be because the measuring "+
+			"bootloader does not pass the appropriate flag to the firmware to indicate that a PE image is being measured.\n\n",
+			strings.Join(opts.BootImageSearchPaths, ","))
+	}
+
+	if opts.CheckSeparators && c.seenDuplicateSeparators {
+		failed = true
+		fmt.Printf("*** FAIL ***: The following PCRs contain more than one EV_SEPARATOR event:\n")
+		for _, e := range c.events {
+			if !e.duplicateSeparator {
+				continue
+			}
+			fmt.Printf("\t- Event %d in PCR %d is an additional EV_SEPARATOR event\n", e.index, e.PCRIndex)
+		}
+		fmt.Printf("The EV_SEPARATOR event in each of PCRs 0-7 marks the transition from pre-OS to OS-present. Additional " +
+			"separators make it impossible to reliably determine this boundary, and might indicate a bug in the firmware.\n\n")
+	}
+
+	if opts.CheckEventOrder && c.seenMisorderedEvents {
+		failed = true
+		fmt.Printf("*** FAIL ***: The following events can only be measured in the pre-OS phase, but follow the EV_SEPARATOR event in the same PCR:\n")
+		for _, e := range c.events {
+			if !e.afterSeparator {
+				continue
+			}
+			fmt.Printf("\t- Event %d in PCR %d (type: %s)\n", e.index, e.PCRIndex, e.EventType)
+		}
+		fmt.Printf("The order of events within a PCR must match the order in which they were measured in order to replay the log. " +
+			"These events are in an impossible order, which might indicate a bug in the firmware or that the log has " +
+			"been tampered with.\n\n")
+	}
+
+	if c.seenUnexpectedGrubPCRs {
+		failed = true
+		fmt.Printf("*** FAIL ***: The following EV_IPL events were measured by GRUB to an unexpected PCR:\n")
+		for _, e := range c.events {
+			if !e.unexpectedGrubPCR {
+				continue
+			}
+			if d, ok := e.Data.(*tcglog.GrubStringEventData); ok {
+				fmt.Printf("\t- Event %d in PCR %d is a %s measurement, which is expected in PCR %d\n", e.index, e.PCRIndex, d.Type, d.Type.PCRIndex())
+			} else {
+				fmt.Printf("\t- Event %d in PCR %d is not a command or kernel commandline measurement, and file measurements are expected in PCR 9\n", e.index, e.PCRIndex)
+			}
+		}
+		fmt.Printf("GRUB measures commands and kernel commandlines to PCR 8 and the files that it loads to PCR 9. Measurements in " +
+			"other PCRs might indicate a bug in the bootloader or that the log was not produced by GRUB.\n\n")
+	}
+
+	if c.seenEventTypesNotInSpec {
+		failed = true
+		fmt.Printf("*** FAIL ***: The following events have a type that is not defined by the specification that the log declares "+
+			"conformance to (platform type: %d, version: %d.%d, errata: %d):\n", log.Spec.PlatformType, log.Spec.Major, log.Spec.Minor, log.Spec.Errata)
+		for _, e := range c.events {
+			if !e.typeNotInSpec {
+				continue
+			}
+			minSpec, _ := e.EventType.MinSpec()
+			fmt.Printf("\t- Event %d in PCR %d has type %s, which requires platform type %d, version %d.%d, errata %d or later\n",
+				e.index, e.PCRIndex, e.EventType, minSpec.PlatformType, minSpec.Major, minSpec.Minor, minSpec.Errata)
+		}
+		fmt.Printf("A log that declares conformance to an older specification but contains event types from a newer one " +
+			"has either been produced by firmware that declares the wrong specification version, or has been tampered with.\n\n")
+	}
+
+	if c.seenUnknownEventTypes {
+		fmt.Printf("- INFO: The following events have a type that is not defined by any known specification:\n")
+		for _, e := range c.events {
+			if !e.unknownType {
+				continue
+			}
+			fmt.Printf("\t- Event %d in PCR %d has type 0x%08x\n", e.index, e.PCRIndex, uint32(e.EventType))
+		}
+		fmt.Printf("These might be vendor defined event types or event types defined by a newer specification. The event data " +
+			"for these events is not decoded or checked, and knowledge of their format is required in order to pre-compute " +
+			"digests for them or by a remote verifier for attestation purposes.\n\n")
+	}
+
+	if opts.Pcrs.Contains(4) && !c.seenBootDeviceEvents {
+		if log.BootDeviceEventsOmitted() {
+			fmt.Printf("- INFO: PCR 4 doesn't contain any boot device measurements, but the log contains an " +
+				"EV_OMIT_BOOT_DEVICE_EVENTS event which indicates that these were intentionally omitted.\n\n")
+		} else {
+			failed = true
+			fmt.Printf("*** FAIL ***: PCR 4 doesn't contain any boot device measurements (EV_IPL or EV_EFI_BOOT_SERVICES_APPLICATION events).\n")
+			fmt.Printf("The platform is expected to measure the code that it boots to PCR 4 unless it records an " +
+				"EV_OMIT_BOOT_DEVICE_EVENTS event. The absence of these measurements might indicate a bug in the firmware " +
+				"or that the log is incomplete.\n\n")
+		}
+	}
+
+	if opts.Pcrs.Contains(1) && opts.Pcrs.Contains(4) && (log.Spec.IsEFI_1_2() || log.Spec.IsEFI_2()) {
+		check, err := log.CheckBootOption()
+		switch {
+		case err != nil:
+			fmt.Printf("- INFO: Cannot check that the boot application in PCR 4 corresponds to a boot option: %v\n\n", err)
+		case !check.Ok():
+			failed = true
+			index := 0
+			for _, e := range log.Events[:check.ApplicationEvent] {
+				if e.PCRIndex == 4 {
+					index++
+				}
+			}
+			fmt.Printf("*** FAIL ***: Event %d in PCR 4 loads a boot application that doesn't correspond to the first entry in "+
+				"BootOrder (%s)", index, check.ActiveOption)
+			if check.MatchingOption != "" {
+				fmt.Printf(", but corresponds to %s", check.MatchingOption)
+			}
+			fmt.Printf(".\nThe first application measured to PCR 4 is expected to be the one referenced by the boot option " +
+				"selected by the firmware. This might be because the firmware fell back to a later boot option after failing " +
+				"to boot the earlier ones, or might indicate that the boot order or the boot application has been tampered " +
+				"with.\n\n")
+		}
+	}
+
+	if opts.TpmPath == "" {
+		fmt.Printf("- INFO: Expected PCR values from log:\n")
+		for _, i := range opts.Pcrs {
+			for _, alg := range checkedAlgs(log) {
+				fmt.Printf("\tPCR %d, bank %s: %x\n", i, alg, c.expectedPCRValues[i][alg])
+			}
+		}
+	} else {
+		tpmPCRValues, err := readPCRs(checkedAlgs(log))
+		if err != nil {
+			return xerrors.Errorf("cannot read PCR values from TPM: %w", err)
+		}
+
+		seenLogConsistencyError := false
+		for _, i := range opts.Pcrs {
+			for _, alg := range checkedAlgs(log) {
+				if c.expectedPCRValues[i][alg].Equal(tpmPCRValues[i][alg]) {
+					continue
+				}
+				if !seenLogConsistencyError {
+					seenLogConsistencyError = true
+					fmt.Printf("*** FAIL ***: The log is not consistent with what was measured in to the TPM for some PCRs:\n")
+					failed = true
+				}
+				fmt.Printf("\t- PCR %d, bank %s - actual value from TPM: %x, expected value from log: %x\n",
+					i, alg, tpmPCRValues[i][alg], c.expectedPCRValues[i][alg])
+			}
+		}
+
+		if seenLogConsistencyError {
+			fmt.Printf("This might be caused by a bug in the firmware or bootloader code participating in the measured boot chain, " +
+				"a bug in the kernel's log handling code, or because events have been measured to the TPM by OS code. A " +
+				"remote verifier will require consistency between the log and the TPM's PCR values for attestation.\n")
+		}
+	}
+
+	if failed {
+		return errors.New("One or more failures were detected!")
+	}
+	return nil
+
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package check_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/canonical/go-efilib"
+	"github.com/canonical/go-tpm2"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/internal/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type checkSuite struct{}
+
+var _ = Suite(&checkSuite{})
+
+// efiVariableBootEvent returns an EV_EFI_VARIABLE_BOOT event for BootOrder with a
+// SHA-256 digest of the supplied measured bytes.
+func (s *checkSuite) efiVariableBootEvent(measured func(data *tcglog.EFIVariableData) []byte) *tcglog.Event {
+	data := &tcglog.EFIVariableData{
+		VariableName: efi.GlobalVariable,
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x03, 0x00, 0x00, 0x00, 0x01, 0x00}}
+	return &tcglog.Event{
+		PCRIndex:  1,
+		EventType: tcglog.EventTypeEFIVariableBoot,
+		Digests: tcglog.DigestMap{
+			tpm2.HashAlgorithmSHA256: tcglog.ComputeEventDigest(tpm2.HashAlgorithmSHA256.GetHash(), measured(data))},
+		Data: data}
+}
+
+func (s *checkSuite) variableData(data *tcglog.EFIVariableData) []byte {
+	return data.VariableData
+}
+
+func (s *checkSuite) variableDataStructure(c *C) func(data *tcglog.EFIVariableData) []byte {
+	return func(data *tcglog.EFIVariableData) []byte {
+		w := new(bytes.Buffer)
+		c.Check(data.Write(w), IsNil)
+		return w.Bytes()
+	}
+}
+
+type testCheckEFIVariableBootData struct {
+	measured  func(data *tcglog.EFIVariableData) []byte
+	spec      tcglog.Spec
+	quirk     bool
+	verified  tcglog.AlgorithmIdList
+	incorrect tcglog.AlgorithmIdList
+	usedQuirk bool
+}
+
+func (s *checkSuite) testCheckEFIVariableBoot(c *C, data *testCheckEFIVariableBootData) {
+	restore := check.MockOptions(&check.Options{EfiVariableBootQuirk: data.quirk})
+	defer restore()
+
+	verified, incorrect, usedQuirk := check.CheckEventDigests(s.efiVariableBootEvent(data.measured), data.spec)
+	c.Check(verified, DeepEquals, data.verified)
+	c.Check(incorrect, DeepEquals, data.incorrect)
+	c.Check(usedQuirk, Equals, data.usedQuirk)
+}
+
+func (s *checkSuite) TestCheckEFIVariableBootVariableData(c *C) {
+	s.testCheckEFIVariableBoot(c, &testCheckEFIVariableBootData{
+		measured: s.variableData,
+		spec:     tcglog.Spec{PlatformType: tcglog.PlatformTypeEFI, Major: 2},
+		verified: tcglog.AlgorithmIdList{tpm2.HashAlgorithmSHA256}})
+}
+
+func (s *checkSuite) TestCheckEFIVariableBootVariableDataWithQuirk(c *C) {
+	// The quirk isn't used when the digest is already correct.
+	s.testCheckEFIVariableBoot(c, &testCheckEFIVariableBootData{
+		measured: s.variableData,
+		spec:     tcglog.Spec{PlatformType: tcglog.PlatformTypeEFI, Major: 2},
+		quirk:    true,
+		verified: tcglog.AlgorithmIdList{tpm2.HashAlgorithmSHA256}})
+}
+
+func (s *checkSuite) TestCheckEFIVariableBootStructure(c *C) {
+	s.testCheckEFIVariableBoot(c, &testCheckEFIVariableBootData{
+		measured:  s.variableDataStructure(c),
+		spec:      tcglog.Spec{PlatformType: tcglog.PlatformTypeEFI, Major: 2},
+		incorrect: tcglog.AlgorithmIdList{tpm2.HashAlgorithmSHA256}})
+}
+
+func (s *checkSuite) TestCheckEFIVariableBootStructureWithQuirk(c *C) {
+	s.testCheckEFIVariableBoot(c, &testCheckEFIVariableBootData{
+		measured:  s.variableDataStructure(c),
+		spec:      tcglog.Spec{PlatformType: tcglog.PlatformTypeEFI, Major: 2},
+		quirk:     true,
+		verified:  tcglog.AlgorithmIdList{tpm2.HashAlgorithmSHA256},
+		usedQuirk: true})
+}
+
+func (s *checkSuite) TestCheckEFIVariableBootStructureTPM12(c *C) {
+	// Logs for TPM family 1.2 always measure the entire structure.
+	s.testCheckEFIVariableBoot(c, &testCheckEFIVariableBootData{
+		measured: s.variableDataStructure(c),
+		spec:     tcglog.Spec{PlatformType: tcglog.PlatformTypeEFI, Major: 1, Minor: 2},
+		verified: tcglog.AlgorithmIdList{tpm2.HashAlgorithmSHA256}})
+}
+
+func (s *checkSuite) TestCheckEFIVariableBootFromLog(c *C) {
+	// The EV_EFI_VARIABLE_BOOT events in the test log measure the entire structure,
+	// so they are only accepted with the quirk.
+	f, err := os.Open("../../testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	log, err := tcglog.ReadLog(f, &tcglog.LogOptions{})
+	c.Assert(err, IsNil)
+
+	n := 0
+	for i, event := range log.Events {
+		if event.EventType != tcglog.EventTypeEFIVariableBoot {
+			continue
+		}
+		n++
+
+		func() {
+			restore := check.MockOptions(&check.Options{})
+			defer restore()
+
+			verified, incorrect, usedQuirk := check.CheckEventDigests(event, log.Spec)
+			c.Check(verified, HasLen, 0, Commentf("event %d", i))
+			c.Check(incorrect, HasLen, len(log.Algorithms), Commentf("event %d", i))
+			c.Check(usedQuirk, Equals, false, Commentf("event %d", i))
+		}()
+
+		func() {
+			restore := check.MockOptions(&check.Options{EfiVariableBootQuirk: true})
+			defer restore()
+
+			verified, incorrect, usedQuirk := check.CheckEventDigests(event, log.Spec)
+			c.Check(verified, HasLen, len(log.Algorithms), Commentf("event %d", i))
+			c.Check(incorrect, HasLen, 0, Commentf("event %d", i))
+			c.Check(usedQuirk, Equals, true, Commentf("event %d", i))
+		}()
+	}
+	c.Check(n > 0, Equals, true)
+}
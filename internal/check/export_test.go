@@ -0,0 +1,29 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package check
+
+import (
+	"github.com/canonical/tcglog-parser"
+)
+
+func MockOptions(o *Options) (restore func()) {
+	orig := opts
+	opts = *o
+	return func() {
+		opts = orig
+	}
+}
+
+// CheckEventDigests checks the digests of the supplied event in the same way as Run,
+// and returns the algorithms for which the digest is consistent with the event data,
+// the algorithms for which it isn't, and whether the digest was only accepted because
+// EfiVariableBootQuirk is set.
+func CheckEventDigests(event *tcglog.Event, spec tcglog.Spec) (verified, incorrect tcglog.AlgorithmIdList, quirk bool) {
+	e := checkEvent(event, &logChecker{spec: spec})
+	for _, d := range e.incorrectDigestValues {
+		incorrect = append(incorrect, d.algorithm)
+	}
+	return e.verifiedAlgs, incorrect, e.efiVariableBootQuirk
+}
@@ -5,7 +5,14 @@
 package tcglog
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/canonical/go-efilib"
 	"github.com/canonical/go-tpm2"
+
+	"golang.org/x/xerrors"
 )
 
 type PlatformType int
@@ -42,11 +49,59 @@ func (s Spec) IsEFI_2() bool {
 	return s.PlatformType == PlatformTypeEFI && s.Major == 2
 }
 
+// isAtLeast indicates whether the version of this specification is the same as or
+// newer than the version of other. The platform types are not compared.
+func (s Spec) isAtLeast(other Spec) bool {
+	switch {
+	case s.Major != other.Major:
+		return s.Major > other.Major
+	case s.Minor != other.Minor:
+		return s.Minor > other.Minor
+	default:
+		return s.Errata >= other.Errata
+	}
+}
+
+// DefinesEventType indicates whether the specified event type is defined by this
+// specification, based on the earliest specification that defines it (see
+// EventType.MinSpec). Event types that aren't specific to EFI platforms are
+// considered to be defined by all EFI specifications. This returns false for event
+// types that aren't defined by any known specification.
+func (s Spec) DefinesEventType(t EventType) bool {
+	min, ok := t.MinSpec()
+	if !ok {
+		return false
+	}
+
+	switch {
+	case min.PlatformType == PlatformTypeBIOS && s.PlatformType == PlatformTypeEFI:
+		return true
+	case min.PlatformType != s.PlatformType:
+		return false
+	default:
+		return s.isAtLeast(min)
+	}
+}
+
 // Log corresponds to a parsed event log.
+//
+// The Events field is owned by the caller once the log has been returned. It is not
+// copied by any of the methods on Log, and nothing derived from it is cached, so
+// methods such as ReplayPCRs always operate on its current contents. Callers that
+// need to reorder or filter events without affecting other users of the same log
+// should operate on a copy of the slice.
+//
+// None of the methods on Log modify it, so a log that has been fully read can be
+// used from multiple goroutines concurrently as long as nothing modifies it. Use
+// ReadOnly to share a log with consumers that shouldn't be able to modify it.
 type Log struct {
 	Spec       Spec            // The specification to which this log conforms
 	Algorithms AlgorithmIdList // The digest algorithms that appear in the log
 	Events     []*Event        // The list of events in the log
+
+	// Warnings contains a list of non-fatal problems encountered whilst
+	// reading the log, such as event data that could not be decoded.
+	Warnings []error
 }
 
 func newLog(event0 *Event) (*Log, []EFISpecIdEventAlgorithmSize) {
@@ -101,3 +156,447 @@ func NewLogForTesting(events []*Event) *Log {
 	log.Events = append(log.Events, events[1:]...)
 	return log
 }
+
+// PlatformClass returns the platformClass field of the Spec ID event at the start of
+// this log, which identifies the platform profile that the log was produced for. A
+// value of 0 corresponds to the PC Client profile and a value of 1 corresponds to the
+// Server profile. This returns 0 if the log doesn't start with a Spec ID event.
+func (l *Log) PlatformClass() uint32 {
+	if len(l.Events) == 0 {
+		return 0
+	}
+	return platformClass(l.Events[specIdEventIndex(l.Events)])
+}
+
+func platformClass(event *Event) uint32 {
+	switch d := event.Data.(type) {
+	case *SpecIdEvent00:
+		return d.PlatformClass
+	case *SpecIdEvent02:
+		return d.PlatformClass
+	case *SpecIdEvent03:
+		return d.PlatformClass
+	default:
+		return 0
+	}
+}
+
+// MeasuredEFIVariable describes an EFI variable that was measured to a log.
+type MeasuredEFIVariable struct {
+	PCRIndex  PCRIndex  // PCR index to which the variable was measured
+	EventType EventType // The type of the event associated with the measurement
+	Name      string    // The name of the variable
+	GUID      efi.GUID  // The vendor GUID of the variable
+}
+
+// MeasuredVariables returns a list of every EFI variable measured to this log
+// by EV_EFI_VARIABLE_DRIVER_CONFIG, EV_EFI_VARIABLE_BOOT, EV_EFI_VARIABLE_BOOT2 and
+// EV_EFI_VARIABLE_AUTHORITY events, in the order in which they appear in the log.
+// Events with data that could not be decoded are omitted.
+func (l *Log) MeasuredVariables() (out []MeasuredEFIVariable) {
+	for _, event := range l.Events {
+		switch event.EventType {
+		case EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIVariableBoot2, EventTypeEFIVariableAuthority:
+		default:
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok {
+			continue
+		}
+
+		out = append(out, MeasuredEFIVariable{
+			PCRIndex:  event.PCRIndex,
+			EventType: event.EventType,
+			Name:      data.UnicodeName,
+			GUID:      data.VariableName})
+	}
+	return out
+}
+
+// VerifyAgainstLiveVariables compares the EFI variable data measured by the
+// EV_EFI_VARIABLE_DRIVER_CONFIG events in this log with the supplied current values of
+// those variables, such as those read from efivarfs on the running system. The
+// returned report lists the events that measure data that differs from the current
+// value in MismatchedEvents, which indicates that the variable has changed since it
+// was measured during boot. Events for variables that don't appear in the supplied
+// map are listed in UncheckedEvents.
+func (l *Log) VerifyAgainstLiveVariables(vars map[efi.VariableDescriptor][]byte) *LogCheckReport {
+	report := new(LogCheckReport)
+
+	for i, event := range l.Events {
+		if event.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok {
+			report.UncheckedEvents = append(report.UncheckedEvents, i)
+			continue
+		}
+
+		current, ok := vars[efi.VariableDescriptor{Name: data.UnicodeName, GUID: data.VariableName}]
+		if !ok {
+			report.UncheckedEvents = append(report.UncheckedEvents, i)
+			continue
+		}
+
+		if !bytes.Equal(data.VariableData, current) {
+			report.MismatchedEvents = append(report.MismatchedEvents, i)
+		}
+	}
+
+	return report
+}
+
+// BootDiskGUID returns the DiskGUID of the GPT header measured to PCR 5 by the first
+// EV_EFI_GPT_EVENT event in this log, which identifies the disk that the platform booted
+// from. If the log doesn't contain a decoded EV_EFI_GPT_EVENT event, this returns false.
+func (l *Log) BootDiskGUID() (efi.GUID, bool) {
+	for _, event := range l.Events {
+		if event.PCRIndex != 5 || event.EventType != EventTypeEFIGPTEvent {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIGPTData)
+		if !ok {
+			continue
+		}
+		return data.Hdr.DiskGUID, true
+	}
+	return efi.GUID{}, false
+}
+
+// ReachedOSPresent indicates whether the transition from pre-OS to OS-present was
+// recorded normally in this log. This is the case when each of PCRs 0-7 contains an
+// EV_SEPARATOR event, and none of those events indicate an error condition.
+func (l *Log) ReachedOSPresent() bool {
+	seen := make(map[PCRIndex]bool)
+	for _, event := range l.Events {
+		if event.PCRIndex > 7 || event.EventType != EventTypeSeparator {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*SeparatorEventData)
+		if !ok || data.IsError() {
+			return false
+		}
+		seen[event.PCRIndex] = true
+	}
+	return len(seen) == 8
+}
+
+// SecureBootMode returns the UEFI secure boot mode of the platform, as defined in
+// section 32.3 of the UEFI specification, derived from the values of the SecureBoot,
+// PK, AuditMode and DeployedMode variables measured to this log. The returned mode
+// is one of "SetupMode", "AuditMode", "UserMode" or "DeployedMode".
+//
+// The SecureBoot and PK variables must be measured to PCR 7, else this returns false.
+// The AuditMode and DeployedMode variables are optional, as they are only measured
+// by firmware that implements them. A measured SecureBoot value that is inconsistent
+// with the derived mode also results in false being returned.
+func (l *Log) SecureBootMode() (mode string, ok bool) {
+	vars := make(map[string][]byte)
+	for _, event := range l.Events {
+		if event.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok || data.VariableName != efi.GlobalVariable {
+			continue
+		}
+
+		switch data.UnicodeName {
+		case "SecureBoot", "PK":
+			if event.PCRIndex != 7 {
+				continue
+			}
+		case "AuditMode", "DeployedMode":
+		default:
+			continue
+		}
+		if _, exists := vars[data.UnicodeName]; exists {
+			continue
+		}
+		vars[data.UnicodeName] = data.VariableData
+	}
+
+	isSet := func(name string) bool {
+		value := vars[name]
+		return len(value) == 1 && value[0] == 1
+	}
+
+	secureBoot, exists := vars["SecureBoot"]
+	if !exists || len(secureBoot) != 1 {
+		return "", false
+	}
+	pk, exists := vars["PK"]
+	if !exists {
+		return "", false
+	}
+
+	switch {
+	case isSet("AuditMode"):
+		mode = "AuditMode"
+	case isSet("DeployedMode"):
+		mode = "DeployedMode"
+	case len(pk) == 0:
+		mode = "SetupMode"
+	default:
+		mode = "UserMode"
+	}
+
+	if isSet("SecureBoot") && mode != "UserMode" && mode != "DeployedMode" {
+		// Secure boot can only be enabled when a platform key is enrolled.
+		return "", false
+	}
+	return mode, true
+}
+
+// PostBootEvents returns the events in this log that were measured in the OS-present
+// phase, in the order in which they appear in the log. For PCRs 0-7, these are the
+// events that follow the EV_SEPARATOR event measured to the same PCR. PCRs 8 and
+// above are reserved for the OS and its loaders and don't receive separators, so
+// all events measured to these are returned.
+//
+// Note that some events that follow a separator may still be measured by firmware,
+// such as EV_EFI_VARIABLE_AUTHORITY events in PCR 7 that are measured when the
+// firmware verifies an OS loader, and the EV_EFI_ACTION events in PCR 5 that are
+// measured during ExitBootServices.
+func (l *Log) PostBootEvents() (out []*Event) {
+	separated := make(map[PCRIndex]bool)
+	for _, event := range l.Events {
+		switch {
+		case event.PCRIndex > 7:
+			out = append(out, event)
+		case separated[event.PCRIndex]:
+			out = append(out, event)
+		case event.EventType == EventTypeSeparator:
+			separated[event.PCRIndex] = true
+		}
+	}
+	return out
+}
+
+// KernelCommandlines returns the kernel commandlines measured to this log, in the
+// order in which they were measured. These are obtained from kernel commandline
+// events measured by GRUB and from commandline events measured by systemd's EFI stub
+// linux loader, which are only decoded if the log was read with the EnableGrub and
+// EnableSystemdEFIStub options respectively.
+func (l *Log) KernelCommandlines() (out []string) {
+	for _, event := range l.Events {
+		switch data := resolveEventData(event.Data).(type) {
+		case *GrubStringEventData:
+			if data.Type != KernelCmdline {
+				continue
+			}
+			out = append(out, data.Str)
+		case *SystemdEFIStubCommandline:
+			out = append(out, data.Str)
+		}
+	}
+	return out
+}
+
+// ErrorSeparators returns the PCRs that have an EV_SEPARATOR event that indicates
+// an error condition, in the order in which these events appear in the log. Such a
+// separator means that the firmware encountered an error during measurement, and
+// the values of these PCRs should not be trusted.
+func (l *Log) ErrorSeparators() (out []PCRIndex) {
+	seen := make(map[PCRIndex]bool)
+	for _, event := range l.Events {
+		if event.EventType != EventTypeSeparator {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*SeparatorEventData)
+		if !ok || !data.IsError() || seen[event.PCRIndex] {
+			continue
+		}
+		seen[event.PCRIndex] = true
+		out = append(out, event.PCRIndex)
+	}
+	return out
+}
+
+// LogStats contains summary statistics for a log.
+type LogStats struct {
+	TotalBytes  int64             // The total size of the events that were read from a log, in bytes
+	EventCount  int               // The number of events in the log
+	PCRCounts   map[PCRIndex]int  // The number of events measured to each PCR
+	EventCounts map[EventType]int // The number of events of each type
+}
+
+// Stats returns summary statistics for this log, computed from the events that it
+// contains. The size of events that weren't read from a log is not included in
+// TotalBytes (see Event.RawBytes). EV_NO_ACTION events are included in the counts,
+// even though they aren't measured.
+func (l *Log) Stats() LogStats {
+	stats := LogStats{
+		EventCount:  len(l.Events),
+		PCRCounts:   make(map[PCRIndex]int),
+		EventCounts: make(map[EventType]int)}
+	for _, event := range l.Events {
+		stats.TotalBytes += int64(len(event.raw))
+		stats.PCRCounts[event.PCRIndex]++
+		stats.EventCounts[event.EventType]++
+	}
+	return stats
+}
+
+// FindFirst returns the first event in this log for which pred returns true, along
+// with its index. If no event matches, this returns nil and -1.
+func (l *Log) FindFirst(pred func(*Event) bool) (*Event, int) {
+	for i, event := range l.Events {
+		if pred(event) {
+			return event, i
+		}
+	}
+	return nil, -1
+}
+
+// FindLast returns the last event in this log for which pred returns true, along
+// with its index. If no event matches, this returns nil and -1.
+func (l *Log) FindLast(pred func(*Event) bool) (*Event, int) {
+	for i := len(l.Events) - 1; i >= 0; i-- {
+		if pred(l.Events[i]) {
+			return l.Events[i], i
+		}
+	}
+	return nil, -1
+}
+
+// signatureDatabase decodes the signature database with the specified name and
+// vendor GUID from the first EV_EFI_VARIABLE_DRIVER_CONFIG event that measures it
+// to PCR 7.
+func (l *Log) signatureDatabase(name string, guid efi.GUID) (efi.SignatureDatabase, error) {
+	for _, event := range l.Events {
+		if event.PCRIndex != 7 || event.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok || data.VariableName != guid || data.UnicodeName != name {
+			continue
+		}
+
+		db, err := efi.ReadSignatureDatabase(bytes.NewReader(data.VariableData))
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode %s: %w", name, err)
+		}
+		return db, nil
+	}
+	return nil, xerrors.Errorf("%s is not measured to PCR 7", name)
+}
+
+// signatureDatabaseContains determines whether the signature database with the
+// specified name and vendor GUID contains an entry that matches the supplied
+// SHA-256 digest.
+func (l *Log) signatureDatabaseContains(name string, guid efi.GUID, hash Digest) (bool, error) {
+	if len(hash) != sha256.Size {
+		return false, xerrors.Errorf("invalid digest length %d: %w", len(hash), ErrDigestSizeMismatch)
+	}
+
+	db, err := l.signatureDatabase(name, guid)
+	if err != nil {
+		return false, err
+	}
+
+	for _, list := range db {
+		for _, sig := range list.Signatures {
+			switch list.Type {
+			case efi.CertSHA256Guid:
+				if bytes.Equal(sig.Data, hash) {
+					return true, nil
+				}
+			case efi.CertX509Guid:
+				if h := sha256.Sum256(sig.Data); bytes.Equal(h[:], hash) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// DBXContains determines whether the forbidden signature database (dbx) measured to
+// PCR 7 in this log contains an entry that matches the supplied SHA-256 digest. The
+// digest matches an EFI_CERT_SHA256_GUID entry with the same value, or an
+// EFI_CERT_X509_GUID entry for a certificate with the same SHA-256 digest. An error
+// is returned if dbx isn't measured to PCR 7 or it cannot be decoded.
+func (l *Log) DBXContains(hash Digest) (bool, error) {
+	return l.signatureDatabaseContains("dbx", efi.ImageSecurityDatabaseGuid, hash)
+}
+
+// DBContains determines whether the authorized signature database (db) measured to
+// PCR 7 in this log contains an entry that matches the supplied SHA-256 digest. See
+// DBXContains for details of how entries are matched.
+func (l *Log) DBContains(hash Digest) (bool, error) {
+	return l.signatureDatabaseContains("db", efi.ImageSecurityDatabaseGuid, hash)
+}
+
+// KEKContains determines whether the key exchange key database (KEK) measured to
+// PCR 7 in this log contains an entry that matches the supplied SHA-256 digest. See
+// DBXContains for details of how entries are matched.
+func (l *Log) KEKContains(hash Digest) (bool, error) {
+	return l.signatureDatabaseContains("KEK", efi.GlobalVariable, hash)
+}
+
+// DBTContains determines whether the authorized timestamp signature database (dbt)
+// measured to PCR 7 in this log contains an entry that matches the supplied SHA-256
+// digest. See DBXContains for details of how entries are matched.
+func (l *Log) DBTContains(hash Digest) (bool, error) {
+	return l.signatureDatabaseContains("dbt", efi.ImageSecurityDatabaseGuid, hash)
+}
+
+// DBRContains determines whether the authorized recovery signature database (dbr)
+// measured to PCR 7 in this log contains an entry that matches the supplied SHA-256
+// digest. See DBXContains for details of how entries are matched.
+func (l *Log) DBRContains(hash Digest) (bool, error) {
+	return l.signatureDatabaseContains("dbr", efi.ImageSecurityDatabaseGuid, hash)
+}
+
+// BootDeviceEventsOmitted indicates whether this log contains an
+// EV_OMIT_BOOT_DEVICE_EVENTS event in PCR 4. This event indicates that the platform
+// intentionally doesn't measure the boot devices that it attempts to boot from, so
+// the absence of these measurements from PCR 4 is not an error.
+func (l *Log) BootDeviceEventsOmitted() bool {
+	event, _ := l.FindFirst(func(event *Event) bool {
+		return event.PCRIndex == 4 && event.EventType == EventTypeOmitBootDeviceEvents
+	})
+	return event != nil
+}
+
+// ActivePCRs returns the PCRs that are extended by at least one event in this log,
+// in ascending order. EV_NO_ACTION events are not extended to a PCR and so are
+// ignored. The remaining PCRs retain their initial value, and can be omitted from
+// the PCR selection of a policy.
+func (l *Log) ActivePCRs() (out []PCRIndex) {
+	seen := make(map[PCRIndex]bool)
+	for _, event := range l.Events {
+		if event.EventType == EventTypeNoAction || seen[event.PCRIndex] {
+			continue
+		}
+		seen[event.PCRIndex] = true
+		out = append(out, event.PCRIndex)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// HasDRTM indicates whether this log records a dynamic launch, such as one performed
+// with Intel TXT or AMD SKINIT. This is the case if the log contains any events for the
+// dynamic PCRs 17-22, which can only be extended after a dynamic launch has reset them.
+// Before a dynamic launch, these PCRs contain all ones rather than zero.
+//
+// Note that EV_EFI_HCRTM_EVENT events and StartupLocality events with a locality of 3
+// or 4 are associated with the static root of trust for measurement rather than a
+// dynamic launch, so they don't affect the result of this.
+func (l *Log) HasDRTM() bool {
+	event, _ := l.FindFirst(func(event *Event) bool {
+		return event.PCRIndex >= 17 && event.PCRIndex <= 22
+	})
+	return event != nil
+}
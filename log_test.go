@@ -0,0 +1,589 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/canonical/go-efilib"
+
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type logSuite struct{}
+
+var _ = Suite(&logSuite{})
+
+var shimLockGuid = efi.MakeGUID(0x605dab50, 0xe046, 0x4300, 0xabb6, [...]uint8{0x3d, 0xd8, 0x10, 0xdd, 0x8b, 0x23})
+
+func (s *logSuite) TestMeasuredVariables(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.MeasuredVariables(), DeepEquals, []MeasuredEFIVariable{
+		{PCRIndex: 7, EventType: EventTypeEFIVariableDriverConfig, Name: "SecureBoot", GUID: efi.GlobalVariable},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableDriverConfig, Name: "PK", GUID: efi.GlobalVariable},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableDriverConfig, Name: "KEK", GUID: efi.GlobalVariable},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableDriverConfig, Name: "db", GUID: efi.ImageSecurityDatabaseGuid},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableDriverConfig, Name: "dbx", GUID: efi.ImageSecurityDatabaseGuid},
+		{PCRIndex: 1, EventType: EventTypeEFIVariableBoot, Name: "BootOrder", GUID: efi.GlobalVariable},
+		{PCRIndex: 1, EventType: EventTypeEFIVariableBoot, Name: "Boot0003", GUID: efi.GlobalVariable},
+		{PCRIndex: 1, EventType: EventTypeEFIVariableBoot, Name: "Boot0000", GUID: efi.GlobalVariable},
+		{PCRIndex: 1, EventType: EventTypeEFIVariableBoot, Name: "Boot0001", GUID: efi.GlobalVariable},
+		{PCRIndex: 1, EventType: EventTypeEFIVariableDriverConfig, Name: "DeployedMode", GUID: efi.GlobalVariable},
+		{PCRIndex: 1, EventType: EventTypeEFIVariableDriverConfig, Name: "AuditMode", GUID: efi.GlobalVariable},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableAuthority, Name: "db", GUID: efi.ImageSecurityDatabaseGuid},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableAuthority, Name: "SbatLevel", GUID: shimLockGuid},
+		{PCRIndex: 7, EventType: EventTypeEFIVariableAuthority, Name: "Shim", GUID: shimLockGuid}})
+}
+
+func (s *logSuite) TestMeasuredVariablesEmpty(c *C) {
+	log := NewLogForTesting(nil)
+	c.Check(log.MeasuredVariables(), HasLen, 0)
+}
+
+func (s *logSuite) TestBootDiskGUID(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	guid, ok := log.BootDiskGUID()
+	c.Check(ok, Equals, true)
+	c.Check(guid, Equals, efi.MakeGUID(0xa4ae73c2, 0x0e2f, 0x4513, 0xbd3c, [...]uint8{0x45, 0x6d, 0xa7, 0xf7, 0xf0, 0xfd}))
+}
+
+func (s *logSuite) TestBootDiskGUIDMissing(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = log.Events[:10]
+	_, ok := log.BootDiskGUID()
+	c.Check(ok, Equals, false)
+}
+
+func (s *logSuite) TestReachedOSPresent(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.ReachedOSPresent(), Equals, true)
+}
+
+func (s *logSuite) TestReachedOSPresentMissingSeparator(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for i, event := range log.Events {
+		if event.PCRIndex == 7 && event.EventType == EventTypeSeparator {
+			log.Events = append(log.Events[:i:i], log.Events[i+1:]...)
+			break
+		}
+	}
+	c.Check(log.ReachedOSPresent(), Equals, false)
+}
+
+func (s *logSuite) TestReachedOSPresentErrorSeparator(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for _, event := range log.Events {
+		if event.PCRIndex == 4 && event.EventType == EventTypeSeparator {
+			event.Data = &SeparatorEventData{Value: SeparatorEventErrorValue}
+		}
+	}
+	c.Check(log.ReachedOSPresent(), Equals, false)
+}
+
+func (s *logSuite) TestAccessorsLazyData(c *C) {
+	// The accessors must return the same results for lazily decoded event data.
+	eager := readTestLog(c, &LogOptions{})
+	lazy := readTestLog(c, &LogOptions{LazyData: true})
+
+	c.Check(lazy.MeasuredVariables(), DeepEquals, eager.MeasuredVariables())
+	c.Check(lazy.MeasuredVariables(), HasLen, 14)
+
+	guid, ok := lazy.BootDiskGUID()
+	c.Check(ok, Equals, true)
+	c.Check(guid, Equals, efi.MakeGUID(0xa4ae73c2, 0x0e2f, 0x4513, 0xbd3c, [...]uint8{0x45, 0x6d, 0xa7, 0xf7, 0xf0, 0xfd}))
+
+	c.Check(lazy.ReachedOSPresent(), Equals, true)
+
+	mode, ok := lazy.SecureBootMode()
+	c.Check(ok, Equals, true)
+	c.Check(mode, Equals, "DeployedMode")
+}
+
+func (s *logSuite) TestSecureBootMode(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	mode, ok := log.SecureBootMode()
+	c.Check(ok, Equals, true)
+	c.Check(mode, Equals, "DeployedMode")
+}
+
+func (s *logSuite) setEFIVariable(c *C, log *Log, name string, value []byte) {
+	for _, event := range log.Events {
+		if event.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+		data, ok := event.Data.(*EFIVariableData)
+		if !ok || data.VariableName != efi.GlobalVariable || data.UnicodeName != name {
+			continue
+		}
+		data.VariableData = value
+		return
+	}
+	c.Fatalf("cannot find variable %s", name)
+}
+
+func (s *logSuite) TestSecureBootModeUser(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.setEFIVariable(c, log, "DeployedMode", []byte{0})
+	mode, ok := log.SecureBootMode()
+	c.Check(ok, Equals, true)
+	c.Check(mode, Equals, "UserMode")
+}
+
+func (s *logSuite) TestSecureBootModeUserDisabled(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.setEFIVariable(c, log, "SecureBoot", []byte{0})
+	s.setEFIVariable(c, log, "DeployedMode", []byte{0})
+	mode, ok := log.SecureBootMode()
+	c.Check(ok, Equals, true)
+	c.Check(mode, Equals, "UserMode")
+}
+
+func (s *logSuite) TestSecureBootModeSetup(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.setEFIVariable(c, log, "SecureBoot", []byte{0})
+	s.setEFIVariable(c, log, "PK", nil)
+	s.setEFIVariable(c, log, "DeployedMode", []byte{0})
+	mode, ok := log.SecureBootMode()
+	c.Check(ok, Equals, true)
+	c.Check(mode, Equals, "SetupMode")
+}
+
+func (s *logSuite) TestSecureBootModeAudit(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.setEFIVariable(c, log, "SecureBoot", []byte{0})
+	s.setEFIVariable(c, log, "PK", nil)
+	s.setEFIVariable(c, log, "DeployedMode", []byte{0})
+	s.setEFIVariable(c, log, "AuditMode", []byte{1})
+	mode, ok := log.SecureBootMode()
+	c.Check(ok, Equals, true)
+	c.Check(mode, Equals, "AuditMode")
+}
+
+func (s *logSuite) TestSecureBootModeInconsistent(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.setEFIVariable(c, log, "PK", nil)
+	s.setEFIVariable(c, log, "DeployedMode", []byte{0})
+	_, ok := log.SecureBootMode()
+	c.Check(ok, Equals, false)
+}
+
+func (s *logSuite) TestSecureBootModeMissing(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = log.Events[:2]
+	_, ok := log.SecureBootMode()
+	c.Check(ok, Equals, false)
+}
+
+func (s *logSuite) TestPostBootEvents(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	events := log.PostBootEvents()
+	c.Assert(events, Not(HasLen), 0)
+
+	for _, event := range events {
+		c.Check(event.EventType, Not(Equals), EventTypeSeparator)
+	}
+
+	var pcr14 int
+	for _, event := range events {
+		if event.PCRIndex == 14 {
+			pcr14++
+		}
+	}
+	c.Check(pcr14, Equals, 2)
+
+	var pcr4 []EventType
+	for _, event := range events {
+		if event.PCRIndex == 4 {
+			pcr4 = append(pcr4, event.EventType)
+		}
+	}
+	c.Check(pcr4, DeepEquals, []EventType{EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesApplication})
+}
+
+func (s *logSuite) TestPostBootEventsNoSeparators(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = log.Events[:10]
+	c.Check(log.PostBootEvents(), HasLen, 0)
+}
+
+func (s *logSuite) TestKernelCommandlines(c *C) {
+	log := readTestLog(c, &LogOptions{EnableGrub: true})
+	c.Check(log.KernelCommandlines(), DeepEquals, []string{
+		"/vmlinuz-5.11.0-22-generic root=/dev/mapper/vgubuntu-root ro quiet splash mem_sleep_default=deep i915.enable_dpcd_backlight=1 vt.handoff=7"})
+}
+
+func (s *logSuite) TestKernelCommandlinesGrubDisabled(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.KernelCommandlines(), HasLen, 0)
+}
+
+func (s *logSuite) TestKernelCommandlinesLazy(c *C) {
+	log := readTestLog(c, &LogOptions{EnableGrub: true, LazyData: true})
+	c.Check(log.KernelCommandlines(), HasLen, 1)
+}
+
+func (s *logSuite) TestKernelCommandlinesSystemdEFIStub(c *C) {
+	log := NewLogForTesting([]*Event{
+		{PCRIndex: 8, EventType: EventTypeIPL, Data: &GrubStringEventData{Type: KernelCmdline, Str: "foo"}},
+		{PCRIndex: 8, EventType: EventTypeIPL, Data: &GrubStringEventData{Type: GrubCmd, Str: "linux /vmlinuz"}},
+		{PCRIndex: 12, EventType: EventTypeIPL, Data: &SystemdEFIStubCommandline{Str: "bar"}}})
+	c.Check(log.KernelCommandlines(), DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *logSuite) TestSpecDefinesEventType(c *C) {
+	bios := Spec{PlatformType: PlatformTypeBIOS, Major: 1, Minor: 2, Errata: 1}
+	biosOld := Spec{PlatformType: PlatformTypeBIOS, Major: 1, Minor: 2}
+	efi12 := Spec{PlatformType: PlatformTypeEFI, Major: 1, Minor: 2}
+	efi2 := Spec{PlatformType: PlatformTypeEFI, Major: 2}
+	efi2rev105 := Spec{PlatformType: PlatformTypeEFI, Major: 2, Errata: 2}
+
+	for _, t := range []struct {
+		spec      Spec
+		eventType EventType
+		expected  bool
+	}{
+		{spec: bios, eventType: EventTypeSeparator, expected: true},
+		{spec: biosOld, eventType: EventTypeSeparator, expected: false},
+		{spec: bios, eventType: EventTypeEFIAction, expected: false},
+		{spec: efi12, eventType: EventTypeSeparator, expected: true},
+		{spec: efi12, eventType: EventTypeEFIAction, expected: true},
+		{spec: efi12, eventType: EventTypeEFIVariableAuthority, expected: false},
+		{spec: efi2, eventType: EventTypeEFIVariableAuthority, expected: true},
+		{spec: efi2, eventType: EventTypeEFISPDMFirmwareBlob, expected: false},
+		{spec: efi2rev105, eventType: EventTypeEFISPDMFirmwareBlob, expected: true},
+		{spec: efi2rev105, eventType: EventType(0x8000ffff), expected: false},
+	} {
+		c.Check(t.spec.DefinesEventType(t.eventType), Equals, t.expected, Commentf("%v %v", t.spec, t.eventType))
+	}
+}
+
+func (s *logSuite) TestSpecDefinesEventTypeLog(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for _, event := range log.Events {
+		c.Check(log.Spec.DefinesEventType(event.EventType), Equals, true, Commentf("%v", event.EventType))
+	}
+}
+
+func (s *logSuite) TestErrorSeparatorsNone(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.ErrorSeparators(), HasLen, 0)
+}
+
+func (s *logSuite) TestErrorSeparators(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for _, event := range log.Events {
+		if event.EventType == EventTypeSeparator && (event.PCRIndex == 4 || event.PCRIndex == 2) {
+			event.Data = NewErrorSeparatorEventData([]byte{0x01, 0x00, 0x00, 0x00})
+		}
+	}
+	c.Check(log.ErrorSeparators(), DeepEquals, []PCRIndex{2, 4})
+}
+
+func (s *logSuite) TestFindFirst(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	event, i := log.FindFirst(func(e *Event) bool {
+		return e.EventType == EventTypeEFIBootServicesApplication
+	})
+	c.Assert(event, NotNil)
+	c.Check(event, Equals, log.Events[i])
+	for _, e := range log.Events[:i] {
+		c.Check(e.EventType, Not(Equals), EventTypeEFIBootServicesApplication)
+	}
+}
+
+func (s *logSuite) TestFindLast(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	event, i := log.FindLast(func(e *Event) bool {
+		return e.PCRIndex == 7 && e.EventType == EventTypeSeparator
+	})
+	c.Assert(event, NotNil)
+	c.Check(i, Equals, 9)
+	c.Check(event, Equals, log.Events[i])
+
+	event, i = log.FindLast(func(e *Event) bool {
+		return e.PCRIndex == 4
+	})
+	c.Check(i, Equals, 111)
+	c.Check(event, Equals, log.Events[i])
+}
+
+func (s *logSuite) TestFindNoMatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	pred := func(e *Event) bool { return e.PCRIndex == 23 }
+
+	event, i := log.FindFirst(pred)
+	c.Check(event, IsNil)
+	c.Check(i, Equals, -1)
+
+	event, i = log.FindLast(pred)
+	c.Check(event, IsNil)
+	c.Check(i, Equals, -1)
+}
+
+func (s *logSuite) TestDBXContainsSHA256(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	contains, err := log.DBXContains(decodeHexString(c, "80b4d96931bf0d02fd91a61e19d14f1da452e66db2408ca8604d411f92659f0a"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, true)
+}
+
+func (s *logSuite) TestDBXContainsCert(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	contains, err := log.DBXContains(decodeHexString(c, "f01614a7a81ba477f0746cf2de71b20dddec709e756c9ea57cb67f93f25ba9fd"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, true)
+}
+
+func (s *logSuite) TestDBXContainsNoMatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	contains, err := log.DBXContains(decodeHexString(c, "e8e95f0733a55e8bad7be0a1413ee23c51fcea64b3c8fa6a786935fddcc71961"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, false)
+}
+
+func (s *logSuite) TestDBContains(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	contains, err := log.DBContains(decodeHexString(c, "e8e95f0733a55e8bad7be0a1413ee23c51fcea64b3c8fa6a786935fddcc71961"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, true)
+}
+
+func (s *logSuite) TestKEKContains(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	contains, err := log.KEKContains(decodeHexString(c, "a1117f516a32cefcba3f2d1ace10a87972fd6bbe8fe0d0b996e09e65d802a503"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, true)
+}
+
+func (s *logSuite) TestDBXContainsInvalidDigest(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.DBXContains(make(Digest, 20))
+	c.Check(err, ErrorMatches, `invalid digest length 20: digest sizes don't match`)
+	c.Check(xerrors.Is(err, ErrDigestSizeMismatch), Equals, true)
+}
+
+func (s *logSuite) TestDBXContainsNotMeasured(c *C) {
+	log := NewLogForTesting(nil)
+	_, err := log.DBXContains(make(Digest, 32))
+	c.Check(err, ErrorMatches, `dbx is not measured to PCR 7`)
+}
+
+// renameDBX renames the dbx variable measured to the test log, so that it can be
+// used to test the dbt and dbr variables which aren't present.
+func (s *logSuite) renameDBX(c *C, log *Log, name string) {
+	for _, event := range log.Events {
+		data, ok := event.Data.(*EFIVariableData)
+		if !ok || event.EventType != EventTypeEFIVariableDriverConfig || data.UnicodeName != "dbx" {
+			continue
+		}
+		data.UnicodeName = name
+		return
+	}
+	c.Fatal("no dbx measurement")
+}
+
+func (s *logSuite) TestDBTContains(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.renameDBX(c, log, "dbt")
+	contains, err := log.DBTContains(decodeHexString(c, "80b4d96931bf0d02fd91a61e19d14f1da452e66db2408ca8604d411f92659f0a"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, true)
+}
+
+func (s *logSuite) TestDBRContains(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	s.renameDBX(c, log, "dbr")
+	contains, err := log.DBRContains(decodeHexString(c, "f01614a7a81ba477f0746cf2de71b20dddec709e756c9ea57cb67f93f25ba9fd"))
+	c.Check(err, IsNil)
+	c.Check(contains, Equals, true)
+}
+
+func (s *logSuite) TestDBTContainsNotMeasured(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.DBTContains(make(Digest, 32))
+	c.Check(err, ErrorMatches, `dbt is not measured to PCR 7`)
+}
+
+func (s *logSuite) TestBootDeviceEventsOmittedFalse(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.BootDeviceEventsOmitted(), Equals, false)
+}
+
+func (s *logSuite) TestBootDeviceEventsOmitted(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = append(log.Events, &Event{
+		PCRIndex:  4,
+		EventType: EventTypeOmitBootDeviceEvents,
+		Data:      OpaqueEventData("BOOT ATTEMPTS OMITTED")})
+	c.Check(log.BootDeviceEventsOmitted(), Equals, true)
+}
+
+func (s *logSuite) TestBootDeviceEventsOmittedWrongPCR(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = append(log.Events, &Event{
+		PCRIndex:  5,
+		EventType: EventTypeOmitBootDeviceEvents,
+		Data:      OpaqueEventData("BOOT ATTEMPTS OMITTED")})
+	c.Check(log.BootDeviceEventsOmitted(), Equals, false)
+}
+
+func (s *logSuite) TestPlatformClass(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.PlatformClass(), Equals, uint32(0))
+}
+
+func (s *logSuite) TestPlatformClassServer(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events[0].Data.(*SpecIdEvent03).PlatformClass = 1
+	c.Check(log.PlatformClass(), Equals, uint32(1))
+}
+
+func (s *logSuite) TestPlatformClassEmpty(c *C) {
+	c.Check(new(Log).PlatformClass(), Equals, uint32(0))
+}
+
+func (s *logSuite) TestActivePCRs(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.ActivePCRs(), DeepEquals, []PCRIndex{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 14})
+}
+
+func (s *logSuite) TestActivePCRsIgnoresNoAction(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = append(log.Events, &Event{
+		PCRIndex:  10,
+		EventType: EventTypeNoAction,
+		Data:      OpaqueEventData(nil)})
+	c.Check(log.ActivePCRs(), DeepEquals, []PCRIndex{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 14})
+}
+
+func (s *logSuite) TestActivePCRsEmpty(c *C) {
+	c.Check(new(Log).ActivePCRs(), HasLen, 0)
+}
+
+// liveVariables returns the variables measured by the EV_EFI_VARIABLE_DRIVER_CONFIG
+// events in the supplied log, along with the index of the event for each.
+func (s *logSuite) liveVariables(log *Log) (vars map[efi.VariableDescriptor][]byte, indices map[string]int) {
+	vars = make(map[efi.VariableDescriptor][]byte)
+	indices = make(map[string]int)
+	for i, event := range log.Events {
+		if event.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+		data := event.DecodedData().(*EFIVariableData)
+		vars[efi.VariableDescriptor{Name: data.UnicodeName, GUID: data.VariableName}] = append([]byte(nil), data.VariableData...)
+		indices[data.UnicodeName] = i
+	}
+	return vars, indices
+}
+
+func (s *logSuite) TestVerifyAgainstLiveVariables(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	vars, _ := s.liveVariables(log)
+	c.Assert(vars, HasLen, 7)
+
+	report := log.VerifyAgainstLiveVariables(vars)
+	c.Check(report.Ok(), Equals, true)
+	c.Check(report.MismatchedEvents, HasLen, 0)
+	c.Check(report.UncheckedEvents, HasLen, 0)
+}
+
+func (s *logSuite) TestVerifyAgainstLiveVariablesLazy(c *C) {
+	log := readTestLog(c, &LogOptions{LazyData: true})
+	vars, indices := s.liveVariables(log)
+	c.Assert(vars, HasLen, 7)
+	vars[efi.VariableDescriptor{Name: "db", GUID: efi.ImageSecurityDatabaseGuid}] = []byte{0}
+
+	report := log.VerifyAgainstLiveVariables(vars)
+	c.Check(report.Ok(), Equals, false)
+	c.Check(report.MismatchedEvents, DeepEquals, []int{indices["db"]})
+	c.Check(report.UncheckedEvents, HasLen, 0)
+}
+
+func (s *logSuite) TestVerifyAgainstLiveVariablesMismatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	vars, indices := s.liveVariables(log)
+	vars[efi.VariableDescriptor{Name: "SecureBoot", GUID: efi.GlobalVariable}] = []byte{0}
+
+	report := log.VerifyAgainstLiveVariables(vars)
+	c.Check(report.Ok(), Equals, false)
+	c.Check(report.MismatchedEvents, DeepEquals, []int{indices["SecureBoot"]})
+	c.Check(report.UncheckedEvents, HasLen, 0)
+}
+
+func (s *logSuite) TestVerifyAgainstLiveVariablesMissing(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	vars, indices := s.liveVariables(log)
+	delete(vars, efi.VariableDescriptor{Name: "dbx", GUID: efi.ImageSecurityDatabaseGuid})
+
+	report := log.VerifyAgainstLiveVariables(vars)
+	c.Check(report.Ok(), Equals, true)
+	c.Check(report.MismatchedEvents, HasLen, 0)
+	c.Check(report.UncheckedEvents, DeepEquals, []int{indices["dbx"]})
+}
+
+func (s *logSuite) TestStats(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	stats := log.Stats()
+	c.Check(stats.TotalBytes, Equals, int64(len(data)))
+	c.Check(stats.EventCount, Equals, 115)
+	c.Check(stats.PCRCounts, DeepEquals, map[PCRIndex]int{0: 5, 1: 11, 2: 1, 3: 1, 4: 4, 5: 2, 6: 4, 7: 9, 8: 65, 9: 11, 14: 2})
+	c.Check(stats.EventCounts[EventTypeSeparator], Equals, 8)
+	c.Check(stats.EventCounts[EventTypeIPL], Equals, 78)
+	c.Check(stats.EventCounts[EventTypeEFIVariableDriverConfig], Equals, 7)
+	c.Check(stats.EventCounts[EventTypeNoAction], Equals, 1)
+
+	total := 0
+	for _, n := range stats.EventCounts {
+		total += n
+	}
+	c.Check(total, Equals, stats.EventCount)
+}
+
+func (s *logSuite) TestStatsConstructedEvents(c *C) {
+	log := &Log{Events: []*Event{
+		{PCRIndex: 7, EventType: EventTypeSeparator, Data: &SeparatorEventData{}},
+		{PCRIndex: 7, EventType: EventTypeEFIAction, Data: StringEventData("foo")}}}
+
+	stats := log.Stats()
+	c.Check(stats.TotalBytes, Equals, int64(0))
+	c.Check(stats.EventCount, Equals, 2)
+	c.Check(stats.PCRCounts, DeepEquals, map[PCRIndex]int{7: 2})
+	c.Check(stats.EventCounts, DeepEquals, map[EventType]int{EventTypeSeparator: 1, EventTypeEFIAction: 1})
+}
+
+func (s *logSuite) TestHasDRTMFalse(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Check(log.HasDRTM(), Equals, false)
+}
+
+func (s *logSuite) TestHasDRTM(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	log.Events = append(log.Events, &Event{
+		PCRIndex:  17,
+		EventType: EventTypeEventTag,
+		Digests:   make(DigestMap),
+		Data:      OpaqueEventData(nil)})
+	c.Check(log.HasDRTM(), Equals, true)
+}
+
+func (s *logSuite) TestHasDRTMHCRTM(c *C) {
+	// A H-CRTM event is associated with the static root of trust.
+	log := readTestLog(c, &LogOptions{})
+	log.Events = append(log.Events, &Event{
+		PCRIndex:  0,
+		EventType: EventTypeEFIHCRTMEvent,
+		Digests:   make(DigestMap),
+		Data:      StringEventData("HCRTM")})
+	c.Check(log.HasDRTM(), Equals, false)
+}
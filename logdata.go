@@ -0,0 +1,272 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"sort"
+)
+
+// EventDataKind describes which field of an EventDataRecord contains the decoded
+// event data.
+type EventDataKind uint32
+
+const (
+	// EventDataKindOpaque indicates that the event data was not decoded.
+	EventDataKindOpaque EventDataKind = iota
+
+	// EventDataKindInvalid indicates that the event data could not be decoded. The
+	// Error field contains the reason.
+	EventDataKindInvalid
+
+	// EventDataKindOther indicates that the event data was decoded to a type that
+	// has no structured representation in EventDataRecord. The Description field
+	// contains the decoded form.
+	EventDataKindOther
+
+	// EventDataKindString indicates that the event data is a string, which is
+	// contained in the Str field.
+	EventDataKindString
+
+	// EventDataKindSpecId indicates that the event data is a Spec ID event, which
+	// is contained in the SpecId field.
+	EventDataKindSpecId
+
+	// EventDataKindSeparator indicates that the event data is a separator, which
+	// is contained in the Separator field.
+	EventDataKindSeparator
+
+	// EventDataKindStartupLocality indicates that the event data is a StartupLocality
+	// event, which is contained in the StartupLocality field.
+	EventDataKindStartupLocality
+
+	// EventDataKindEFIVariable indicates that the event data is a UEFI_VARIABLE_DATA
+	// structure, which is contained in the EFIVariable field.
+	EventDataKindEFIVariable
+
+	// EventDataKindEFIImageLoad indicates that the event data is a
+	// UEFI_IMAGE_LOAD_EVENT structure, which is contained in the EFIImageLoad field.
+	EventDataKindEFIImageLoad
+
+	// EventDataKindTagged indicates that the event data is a TCG_PCClientTaggedEvent
+	// structure, which is contained in the Tagged field.
+	EventDataKindTagged
+)
+
+// LogData is a representation of a log that only contains concrete types, with
+// enumerations represented as integers and digests as byte slices. It is intended
+// to be mechanically converted to other serialization formats, such as protocol
+// buffers.
+type LogData struct {
+	PlatformType uint32 // The PlatformType of the specification that the log conforms to
+	SpecMajor    uint32
+	SpecMinor    uint32
+	SpecErrata   uint32
+	Algorithms   []uint32 // The digest algorithms that appear in the log
+	Events       []*EventRecord
+	Warnings     []string // The warnings recorded whilst reading the log
+}
+
+// EventRecord is the representation of an event in LogData.
+type EventRecord struct {
+	PCRIndex  uint32
+	EventType uint32
+	Digests   []*DigestRecord // The digests for this event, in ascending order of algorithm
+	Data      *EventDataRecord
+}
+
+// DigestRecord is the representation of a digest in EventRecord.
+type DigestRecord struct {
+	Algorithm uint32
+	Digest    []byte
+}
+
+// EventDataRecord is the representation of event data in EventRecord. The decoded
+// event data is represented as a tagged union, where Kind indicates which of the
+// other fields is set.
+type EventDataRecord struct {
+	Kind        EventDataKind
+	Raw         []byte // The raw event data bytes as they appear in the log
+	Description string // The decoded event data as it would be displayed
+	Error       string // The reason that the event data could not be decoded, for EventDataKindInvalid
+
+	Str             string
+	SpecId          *SpecIdRecord
+	Separator       *SeparatorRecord
+	StartupLocality *StartupLocalityRecord
+	EFIVariable     *EFIVariableRecord
+	EFIImageLoad    *EFIImageLoadRecord
+	Tagged          *TaggedEventRecord
+}
+
+// SpecIdRecord is the representation of a Spec ID event in EventDataRecord.
+type SpecIdRecord struct {
+	PlatformClass    uint32
+	SpecVersionMinor uint32
+	SpecVersionMajor uint32
+	SpecErrata       uint32
+	UintnSize        uint32
+	DigestSizes      []*DigestSizeRecord // The digest sizes for a TCG_EfiSpecIDEventStruct
+	VendorInfo       []byte
+}
+
+// DigestSizeRecord is the representation of a TCG_EfiSpecIdEventAlgorithmSize
+// structure in SpecIdRecord.
+type DigestSizeRecord struct {
+	Algorithm  uint32
+	DigestSize uint32
+}
+
+// SeparatorRecord is the representation of separator event data in EventDataRecord.
+type SeparatorRecord struct {
+	Value   uint32
+	IsError bool
+}
+
+// StartupLocalityRecord is the representation of a StartupLocality event in
+// EventDataRecord.
+type StartupLocalityRecord struct {
+	StartupLocality uint32
+}
+
+// EFIVariableRecord is the representation of a UEFI_VARIABLE_DATA structure in
+// EventDataRecord.
+type EFIVariableRecord struct {
+	VariableName []byte // The variable GUID in its binary representation
+	UnicodeName  string
+	VariableData []byte
+}
+
+// EFIImageLoadRecord is the representation of a UEFI_IMAGE_LOAD_EVENT structure in
+// EventDataRecord.
+type EFIImageLoadRecord struct {
+	LocationInMemory uint64
+	LengthInMemory   uint64
+	LinkTimeAddress  uint64
+	DevicePath       string // The textual representation of the device path
+}
+
+// TaggedEventRecord is the representation of a TCG_PCClientTaggedEvent structure in
+// EventDataRecord.
+type TaggedEventRecord struct {
+	EventID uint32
+	Data    []byte
+}
+
+func newEventDataRecord(data EventData) *EventDataRecord {
+	data = resolveEventData(data)
+
+	out := &EventDataRecord{
+		Kind:        EventDataKindOther,
+		Raw:         data.Bytes(),
+		Description: data.String()}
+
+	switch d := data.(type) {
+	case error:
+		out.Kind = EventDataKindInvalid
+		out.Error = d.Error()
+	case OpaqueEventData, *TypedOpaqueEventData:
+		out.Kind = EventDataKindOpaque
+	case StringEventData:
+		out.Kind = EventDataKindString
+		out.Str = string(d)
+	case *ActionEventData:
+		out.Kind = EventDataKindString
+		out.Str = d.Str
+	case *GrubStringEventData:
+		out.Kind = EventDataKindString
+		out.Str = d.Str
+	case *SystemdEFIStubCommandline:
+		out.Kind = EventDataKindString
+		out.Str = d.Str
+	case *SpecIdEvent00:
+		out.Kind = EventDataKindSpecId
+		out.SpecId = &SpecIdRecord{
+			PlatformClass:    d.PlatformClass,
+			SpecVersionMinor: uint32(d.SpecVersionMinor),
+			SpecVersionMajor: uint32(d.SpecVersionMajor),
+			SpecErrata:       uint32(d.SpecErrata),
+			VendorInfo:       d.VendorInfo}
+	case *SpecIdEvent02:
+		out.Kind = EventDataKindSpecId
+		out.SpecId = &SpecIdRecord{
+			PlatformClass:    d.PlatformClass,
+			SpecVersionMinor: uint32(d.SpecVersionMinor),
+			SpecVersionMajor: uint32(d.SpecVersionMajor),
+			SpecErrata:       uint32(d.SpecErrata),
+			UintnSize:        uint32(d.UintnSize),
+			VendorInfo:       d.VendorInfo}
+	case *SpecIdEvent03:
+		out.Kind = EventDataKindSpecId
+		out.SpecId = &SpecIdRecord{
+			PlatformClass:    d.PlatformClass,
+			SpecVersionMinor: uint32(d.SpecVersionMinor),
+			SpecVersionMajor: uint32(d.SpecVersionMajor),
+			SpecErrata:       uint32(d.SpecErrata),
+			UintnSize:        uint32(d.UintnSize),
+			VendorInfo:       d.VendorInfo}
+		for _, size := range d.DigestSizes {
+			out.SpecId.DigestSizes = append(out.SpecId.DigestSizes, &DigestSizeRecord{
+				Algorithm:  uint32(size.AlgorithmId),
+				DigestSize: uint32(size.DigestSize)})
+		}
+	case *SeparatorEventData:
+		out.Kind = EventDataKindSeparator
+		out.Separator = &SeparatorRecord{Value: d.Value, IsError: d.IsError()}
+	case *StartupLocalityEventData:
+		out.Kind = EventDataKindStartupLocality
+		out.StartupLocality = &StartupLocalityRecord{StartupLocality: uint32(d.StartupLocality)}
+	case *EFIVariableData:
+		out.Kind = EventDataKindEFIVariable
+		out.EFIVariable = &EFIVariableRecord{
+			VariableName: append([]byte(nil), d.VariableName[:]...),
+			UnicodeName:  d.UnicodeName,
+			VariableData: d.VariableData}
+	case *EFIImageLoadEvent:
+		out.Kind = EventDataKindEFIImageLoad
+		out.EFIImageLoad = &EFIImageLoadRecord{
+			LocationInMemory: uint64(d.LocationInMemory),
+			LengthInMemory:   d.LengthInMemory,
+			LinkTimeAddress:  d.LinkTimeAddress,
+			DevicePath:       d.DevicePath.String()}
+	case *TaggedEventData:
+		out.Kind = EventDataKindTagged
+		out.Tagged = &TaggedEventRecord{EventID: d.EventID, Data: d.Data}
+	}
+
+	return out
+}
+
+// ToStruct returns a representation of this log that only contains concrete types,
+// which is suitable for mechanically converting to other serialization formats. The
+// returned structure shares byte slices with this log, so it must not be modified.
+func (l *Log) ToStruct() *LogData {
+	out := &LogData{
+		PlatformType: uint32(l.Spec.PlatformType),
+		SpecMajor:    uint32(l.Spec.Major),
+		SpecMinor:    uint32(l.Spec.Minor),
+		SpecErrata:   uint32(l.Spec.Errata)}
+
+	for _, alg := range l.Algorithms {
+		out.Algorithms = append(out.Algorithms, uint32(alg))
+	}
+
+	for _, event := range l.Events {
+		record := &EventRecord{
+			PCRIndex:  uint32(event.PCRIndex),
+			EventType: uint32(event.EventType),
+			Data:      newEventDataRecord(event.Data)}
+		for alg, digest := range event.Digests {
+			record.Digests = append(record.Digests, &DigestRecord{Algorithm: uint32(alg), Digest: digest})
+		}
+		sort.Slice(record.Digests, func(i, j int) bool { return record.Digests[i].Algorithm < record.Digests[j].Algorithm })
+		out.Events = append(out.Events, record)
+	}
+
+	for _, warning := range l.Warnings {
+		out.Warnings = append(out.Warnings, warning.Error())
+	}
+
+	return out
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"github.com/canonical/go-efilib"
+	"github.com/canonical/go-tpm2"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type logdataSuite struct{}
+
+var _ = Suite(&logdataSuite{})
+
+func (s *logdataSuite) TestToStruct(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	data := log.ToStruct()
+
+	c.Check(data.PlatformType, Equals, uint32(PlatformTypeEFI))
+	c.Check(data.SpecMajor, Equals, uint32(2))
+	c.Check(data.SpecMinor, Equals, uint32(0))
+	c.Check(data.Algorithms, DeepEquals, []uint32{uint32(tpm2.HashAlgorithmSHA1), uint32(tpm2.HashAlgorithmSHA256)})
+	c.Check(data.Warnings, HasLen, len(log.Warnings))
+	c.Assert(data.Events, HasLen, len(log.Events))
+
+	for i, event := range log.Events {
+		record := data.Events[i]
+		c.Check(record.PCRIndex, Equals, uint32(event.PCRIndex), Commentf("event %d", i))
+		c.Check(record.EventType, Equals, uint32(event.EventType), Commentf("event %d", i))
+		c.Check(record.Data.Raw, DeepEquals, event.Data.Bytes(), Commentf("event %d", i))
+		c.Check(record.Data.Description, Equals, event.Data.String(), Commentf("event %d", i))
+		c.Assert(record.Digests, HasLen, len(event.Digests), Commentf("event %d", i))
+		for j, digest := range record.Digests {
+			if j > 0 {
+				c.Check(digest.Algorithm > record.Digests[j-1].Algorithm, Equals, true, Commentf("event %d", i))
+			}
+			c.Check(digest.Digest, DeepEquals, []byte(event.Digests[tpm2.HashAlgorithmId(digest.Algorithm)]), Commentf("event %d", i))
+		}
+	}
+	c.Check(data.Events[1].Digests, HasLen, 2)
+}
+
+func (s *logdataSuite) TestToStructSpecId(c *C) {
+	data := readTestLog(c, &LogOptions{}).ToStruct()
+
+	record := data.Events[0].Data
+	c.Check(record.Kind, Equals, EventDataKindSpecId)
+	c.Assert(record.SpecId, NotNil)
+	c.Check(record.SpecId.SpecVersionMajor, Equals, uint32(2))
+	c.Check(record.SpecId.UintnSize, Equals, uint32(2))
+	c.Check(record.SpecId.DigestSizes, DeepEquals, []*DigestSizeRecord{
+		{Algorithm: uint32(tpm2.HashAlgorithmSHA1), DigestSize: 20},
+		{Algorithm: uint32(tpm2.HashAlgorithmSHA256), DigestSize: 32}})
+}
+
+func (s *logdataSuite) TestToStructEFIVariable(c *C) {
+	data := readTestLog(c, &LogOptions{}).ToStruct()
+
+	record := data.Events[4].Data
+	c.Check(record.Kind, Equals, EventDataKindEFIVariable)
+	c.Assert(record.EFIVariable, NotNil)
+	c.Check(record.EFIVariable.UnicodeName, Equals, "SecureBoot")
+	c.Check(record.EFIVariable.VariableName, DeepEquals, efi.GlobalVariable[:])
+	c.Check(record.EFIVariable.VariableData, DeepEquals, []byte{0x01})
+}
+
+func (s *logdataSuite) TestToStructSeparator(c *C) {
+	data := readTestLog(c, &LogOptions{}).ToStruct()
+
+	record := data.Events[9].Data
+	c.Check(record.Kind, Equals, EventDataKindSeparator)
+	c.Check(record.Separator, DeepEquals, &SeparatorRecord{Value: 0, IsError: false})
+}
+
+func (s *logdataSuite) TestToStructInvalid(c *C) {
+	data := readTestLog(c, &LogOptions{}).ToStruct()
+
+	record := data.Events[111].Data
+	c.Check(record.Kind, Equals, EventDataKindInvalid)
+	c.Check(record.Error, Matches, `.*cannot read DevicePath \(0 bytes\): unexpected EOF`)
+	c.Check(record.EFIImageLoad, IsNil)
+}
+
+func (s *logdataSuite) TestToStructOpaque(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	data := log.ToStruct()
+
+	for i, event := range log.Events {
+		if event.EventType != EventTypeIPL {
+			continue
+		}
+		c.Check(data.Events[i].Data.Kind, Equals, EventDataKindOpaque, Commentf("event %d", i))
+	}
+}
+
+func (s *logdataSuite) TestToStructGrub(c *C) {
+	log := readTestLog(c, &LogOptions{EnableGrub: true})
+	data := log.ToStruct()
+
+	n := 0
+	for i, event := range log.Events {
+		if event.EventType != EventTypeIPL {
+			continue
+		}
+		grub, ok := event.Data.(*GrubStringEventData)
+		if !ok {
+			continue
+		}
+		n++
+		c.Check(data.Events[i].Data.Kind, Equals, EventDataKindString, Commentf("event %d", i))
+		c.Check(data.Events[i].Data.Str, Equals, grub.Str, Commentf("event %d", i))
+	}
+	c.Check(n > 0, Equals, true)
+}
+
+func (s *logdataSuite) TestToStructLazy(c *C) {
+	log := readTestLog(c, &LogOptions{LazyData: true})
+	data := log.ToStruct()
+
+	c.Check(data.Events[4].Data.Kind, Equals, EventDataKindEFIVariable)
+	c.Check(data.Events[4].Data.EFIVariable.UnicodeName, Equals, "SecureBoot")
+}
@@ -5,7 +5,15 @@
 package tcglog
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math/bits"
+
+	"golang.org/x/xerrors"
 )
 
 // LogOptions allows the behaviour of Log to be controlled.
@@ -13,39 +21,601 @@ type LogOptions struct {
 	EnableGrub           bool     // Enable support for interpreting events recorded by GRUB
 	EnableSystemdEFIStub bool     // Enable support for interpreting events recorded by systemd's EFI linux loader stub
 	SystemdEFIStubPCR    PCRIndex // Specify the PCR that systemd's EFI linux loader stub measures to
+	EnableSystemdBoot    bool     // Enable support for interpreting EV_EVENT_TAG events recorded by systemd-boot and systemd's EFI linux loader stub
+	EnableWindowsSIPA    bool     // Enable support for interpreting EV_EVENT_TAG events recorded by Windows
+
+	// LazyData defers decoding of event data until it is used. When set, the data
+	// for each event after the leading events that precede and include the Spec ID
+	// event is a *LazyEventData, and errors that occur when decoding it are not
+	// recorded in Log.Warnings. Use Event.DecodedData to obtain the decoded form of
+	// the data for any event, which decodes it on first use.
+	LazyData bool
+
+	// DisabledAlgorithms specifies digest algorithms that must not be computed whilst
+	// decoding event data, eg, because they are disallowed on a FIPS restricted system.
+	// Digests for these algorithms are still read from the log.
+	DisabledAlgorithms AlgorithmIdList
+
+	// OnEvent is an optional callback which is invoked by ReadLog as each event is
+	// parsed, in the order in which they appear in the log.
+	OnEvent func(*Event)
+
+	// Progress is an optional callback which is invoked by ReadLog after each event
+	// is parsed, with the number of bytes read from the log so far. The total size
+	// of the log is determined if the supplied reader implements io.Seeker, else it
+	// is -1.
+	Progress func(bytesRead, totalBytes int64)
+
+	spec     Spec                                         // The specification of the log being decoded, if known
+	decoders map[eventDataDecoderKey]EventDataDecoderFunc // Custom event data decoders
+}
+
+// EventDataDecoderFunc is a custom decoder for event data, which can be registered with
+// LogOptions.RegisterEventDataDecoder. It is supplied with the complete event data and the
+// byte order of the log, which is always binary.LittleEndian for the logs that this
+// package reads. If it doesn't recognize the data, it should return nil without an error
+// so that the data is decoded as it would be without the custom decoder.
+type EventDataDecoderFunc func(data []byte, order binary.ByteOrder) (EventData, error)
+
+type eventDataDecoderKey struct {
+	eventType EventType
+	tag       uint32
+}
+
+// RegisterEventDataDecoder registers a custom decoder for the data of events with the
+// specified type, which is used in preference to the decoders in this package. This makes
+// it possible to decode vendor specific event data into custom types.
+//
+// For EV_EVENT_TAG events, the decoder is only used for events with the specified
+// taggedEventID. For other event types, tag is ignored and should be zero.
+//
+// If the decoder returns an error, the error is recorded in the same way as errors that
+// occur when decoding event data with the decoders in this package.
+//
+// Decoders are registered on a LogOptions rather than in a package-wide registry, so that
+// the decoders registered by one user of this package don't affect the logs read by
+// another.
+func (o *LogOptions) RegisterEventDataDecoder(eventType EventType, tag uint32, fn EventDataDecoderFunc) {
+	if eventType != EventTypeEventTag {
+		tag = 0
+	}
+	if o.decoders == nil {
+		o.decoders = make(map[eventDataDecoderKey]EventDataDecoderFunc)
+	}
+	o.decoders[eventDataDecoderKey{eventType: eventType, tag: tag}] = fn
+}
+
+// customEventDataDecoder returns the custom decoder registered for the supplied event
+// data, if there is one.
+func (o *LogOptions) customEventDataDecoder(data []byte, eventType EventType) EventDataDecoderFunc {
+	if len(o.decoders) == 0 {
+		return nil
+	}
+
+	var tag uint32
+	if eventType == EventTypeEventTag {
+		if len(data) < 4 {
+			return nil
+		}
+		tag = binary.LittleEndian.Uint32(data)
+	}
+	return o.decoders[eventDataDecoderKey{eventType: eventType, tag: tag}]
+}
+
+// forSpec returns a copy of these options for decoding the events in a log that
+// conforms to the specified specification.
+func (o *LogOptions) forSpec(spec Spec) *LogOptions {
+	out := *o
+	out.spec = spec
+	return &out
+}
+
+// computableDigests returns the subset of digests for algorithms that can be computed
+// whilst decoding event data.
+func (o *LogOptions) computableDigests(digests DigestMap) DigestMap {
+	if len(o.DisabledAlgorithms) == 0 {
+		return digests
+	}
+
+	out := make(DigestMap)
+	for alg, digest := range digests {
+		if o.DisabledAlgorithms.Contains(alg) {
+			continue
+		}
+		out[alg] = digest
+	}
+	return out
+}
+
+// progressReader tracks the number of bytes read from a log in order to report
+// progress.
+type progressReader struct {
+	r         io.Reader
+	bytesRead int64
+	total     int64
+	progress  func(bytesRead, totalBytes int64)
+}
+
+func newProgressReader(r io.Reader, progress func(bytesRead, totalBytes int64)) *progressReader {
+	total := int64(-1)
+	if s, ok := r.(io.Seeker); ok {
+		if current, err := s.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := s.Seek(0, io.SeekEnd); err == nil {
+				total = end - current
+			}
+			if _, err := s.Seek(current, io.SeekStart); err != nil {
+				// We can't restore the original offset, so make sure
+				// that reading fails.
+				r = errorReader{xerrors.Errorf("cannot restore offset: %w", err)}
+				total = -1
+			}
+		}
+	}
+	return &progressReader{r: r, total: total, progress: progress}
+}
+
+func (r *progressReader) Read(data []byte) (int, error) {
+	n, err := r.r.Read(data)
+	r.bytesRead += int64(n)
+	return n, err
+}
+
+func (r *progressReader) report() {
+	r.progress(r.bytesRead, r.total)
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+func (o *LogOptions) onEvent(event *Event) {
+	if o.OnEvent == nil {
+		return
+	}
+	o.OnEvent(event)
+}
+
+// ErrInconsistentByteOrder is wrapped by warnings in Log.Warnings for events that only
+// make sense when interpreted in a different byte order to the rest of the log, which
+// might indicate that the log is corrupt or was produced by a broken tool.
+var ErrInconsistentByteOrder = errors.New("inconsistent byte order")
+
+// ErrEmptyLog is returned when reading a log that doesn't contain any events, such as
+// an empty file or a buffer that only contains padding or an end of log marker. This
+// is distinct from a valid log that contains a Spec ID event but no measurements.
+var ErrEmptyLog = errors.New("the log does not contain any events")
+
+func isValidSeparatorValue(value uint32) bool {
+	switch value {
+	case SeparatorEventNormalValue, SeparatorEventErrorValue, SeparatorEventAltNormalValue:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkByteOrder records a warning in this log if the supplied event contains a
+// structure that is only valid when interpreted in big-endian byte order. Logs are
+// always little-endian, and this can only detect structures that aren't symmetric
+// under byte swapping, such as unknown event types that correspond to a known type
+// when swapped, or an EV_SEPARATOR event that contains a byte swapped error value.
+func (l *Log) checkByteOrder(index int, event *Event) {
+	if _, known := event.EventType.MinSpec(); !known {
+		swapped := EventType(bits.ReverseBytes32(uint32(event.EventType)))
+		if _, known := swapped.MinSpec(); known {
+			l.Warnings = append(l.Warnings, xerrors.Errorf("event %d (PCR %d) has type 0x%08x, which corresponds to %v in big-endian byte order: %w",
+				index, event.PCRIndex, uint32(event.EventType), swapped, ErrInconsistentByteOrder))
+			return
+		}
+	}
+
+	if event.EventType != EventTypeSeparator {
+		return
+	}
+	data := event.Data.Bytes()
+	if len(data) != binary.Size(uint32(0)) {
+		return
+	}
+	if !isValidSeparatorValue(binary.LittleEndian.Uint32(data)) && isValidSeparatorValue(binary.BigEndian.Uint32(data)) {
+		l.Warnings = append(l.Warnings, xerrors.Errorf("event %d (PCR %d) is a separator with value 0x%x, which is only valid in big-endian byte order: %w",
+			index, event.PCRIndex, data, ErrInconsistentByteOrder))
+	}
+}
+
+// EventDataError is recorded in Log.Warnings when the data for an event could not
+// be decoded.
+type EventDataError struct {
+	Index     int       // The index of the event in the log
+	Offset    int64     // The byte offset of the event from the start of the log (or the blob for ReadLogFromSection), or -1 if it isn't known
+	PCRIndex  PCRIndex  // The PCR index of the event
+	EventType EventType // The type of the event
+	Err       error     // The error that occurred whilst decoding the event data
+}
+
+func (e *EventDataError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("cannot decode data for event %d (PCR %d, type %v): %v", e.Index, e.PCRIndex, e.EventType, e.Err)
+	}
+	return fmt.Sprintf("cannot decode data for event %d (PCR %d, type %v, offset 0x%x): %v", e.Index, e.PCRIndex, e.EventType, e.Offset, e.Err)
+}
+
+func (e *EventDataError) Unwrap() error {
+	return e.Err
+}
+
+// checkEventData records a warning in this log if the data for the supplied event
+// could not be decoded. The offset is the byte offset of the event in the log, or
+// -1 if it isn't known.
+func (l *Log) checkEventData(index int, offset int64, event *Event) {
+	err, isErr := event.Data.(error)
+	if !isErr {
+		return
+	}
+	l.Warnings = append(l.Warnings, &EventDataError{
+		Index:     index,
+		Offset:    offset,
+		PCRIndex:  event.PCRIndex,
+		EventType: event.EventType,
+		Err:       err})
 }
 
 // ReadLog reads an event log read from r using the supplied options. The log must
 // be in the format defined in one of the PC Client Platform Firmware Profile
 // specifications. If an error occurs during parsing, this may return an incomplete
 // list of events with the error.
+//
+// Parsing stops cleanly at an event header with a PCR index of 0xffffffff, which
+// conventionally marks the end of the log, or at an all-zero header, which indicates
+// that the remainder of the log buffer is padding. If the log doesn't contain any
+// events before this, ErrEmptyLog is returned.
+//
+// The Spec ID event that determines the format of the log is expected to be the first
+// event, but it is also found if it is preceded by a small number of other EV_NO_ACTION
+// events. In this case, a warning is recorded in the returned log.
+//
+// Use a Parser to read many logs with reduced allocation overhead.
 func ReadLog(r io.Reader, options *LogOptions) (*Log, error) {
-	event, err := ReadEvent(r, options)
+	return new(Parser).Parse(r, options)
+}
+
+// Parser reads event logs, reusing internal state across calls. This reduces
+// allocation overhead when reading many logs, such as in a batch processing
+// pipeline. The zero value is ready to use. A Parser must not be used from more
+// than one goroutine at a time.
+type Parser struct {
+	er         eventReader
+	eventsHint int
+}
+
+// NewParser returns a new Parser.
+func NewParser() *Parser {
+	return new(Parser)
+}
+
+// Parse reads an event log from r using the supplied options. The returned log
+// does not share any memory with the parser or with logs returned from previous
+// calls. See ReadLog for further details.
+func (p *Parser) Parse(r io.Reader, options *LogOptions) (*Log, error) {
+	return p.parse(r, options, 0, nil)
+}
+
+// parse reads an event log from r using the supplied options. The base offset is
+// the offset of the start of the log within the underlying data, and is added to
+// the event offsets that are reported in warnings. If atNextLog is not nil, it is
+// called before reading each event after the leading events, and parsing stops
+// cleanly if it indicates that the next event is the start of another log.
+func (p *Parser) parse(r io.Reader, options *LogOptions, base int64, atNextLog func() bool) (*Log, error) {
+	var progress *progressReader
+	if options.Progress != nil {
+		progress = newProgressReader(r, options.Progress)
+		r = progress
+	}
+	report := func() {
+		if progress != nil {
+			progress.report()
+		}
+	}
+
+	// The leading events are always decoded because they determine the format of the log.
+	events, err := p.readLeadingEvents(r, options, report)
+	if len(events) == 0 {
+		if err == io.EOF {
+			return nil, ErrEmptyLog
+		}
+		return nil, err
+	}
+
+	header := specIdEventIndex(events)
+	log, digestSizes := newLog(events[header])
+	log.Events = events
+	offset := base
+	for i, event := range events {
+		log.checkEventData(i, offset, event)
+		log.checkByteOrder(i, event)
+		offset += int64(len(event.raw))
+	}
+	if header > 0 {
+		log.Warnings = append(log.Warnings, fmt.Errorf("the Spec ID event is event %d rather than the first event", header))
+	}
 	switch {
 	case err == io.EOF:
-		return new(Log), nil
+		return log, nil
 	case err != nil:
-		return nil, err
+		return log, err
+	}
+
+	if p.eventsHint > len(log.Events) {
+		events := make([]*Event, len(log.Events), p.eventsHint)
+		copy(events, log.Events)
+		log.Events = events
 	}
 
-	log, digestSizes := newLog(event)
+	decode := options.forSpec(log.Spec).eventDataDecoder()
 
 	for {
+		if atNextLog != nil && atNextLog() {
+			p.eventsHint = len(log.Events)
+			return log, nil
+		}
+
 		var event *Event
 		var err error
 		if log.Spec.IsEFI_2() {
-			event, err = ReadEventCryptoAgile(r, digestSizes, options)
+			event, err = p.er.readEventCryptoAgile(r, digestSizes, decode)
 		} else {
-			event, err = ReadEvent(r, options)
+			event, err = p.er.readEvent(r, decode)
 		}
 
 		switch {
 		case err == io.EOF:
+			p.eventsHint = len(log.Events)
 			return log, nil
 		case err != nil:
 			return log, err
 		default:
+			options.onEvent(event)
+			report()
+			log.checkEventData(len(log.Events), offset, event)
+			log.checkByteOrder(len(log.Events), event)
 			log.Events = append(log.Events, event)
+			offset += int64(len(event.raw))
+		}
+	}
+}
+
+// maxLeadingEvents is the maximum number of EV_NO_ACTION events that are searched for
+// a Spec ID event at the start of a log.
+const maxLeadingEvents = 4
+
+func isSpecIdEvent(event *Event) bool {
+	switch event.Data.(type) {
+	case *SpecIdEvent00, *SpecIdEvent02, *SpecIdEvent03:
+		return true
+	default:
+		return false
+	}
+}
+
+// specIdEventIndex returns the index of the Spec ID event in the supplied events,
+// which are the events at the start of a log, using the same rules as
+// readLeadingEvents. This returns 0 if there is no Spec ID event.
+func specIdEventIndex(events []*Event) int {
+	for i, event := range events {
+		if i >= maxLeadingEvents {
+			break
+		}
+		if isSpecIdEvent(event) {
+			return i
 		}
+		if event.EventType != EventTypeNoAction {
+			break
+		}
+	}
+	return 0
+}
+
+// readLeadingEvents reads the events at the start of a log that precede and include
+// the Spec ID event, which determines the format of the rest of the log. The Spec ID
+// event should be the first event, but some logs contain other EV_NO_ACTION events,
+// such as a StartupLocality event, before it. These are read in the format defined
+// for the first event. Reading stops after the Spec ID event, after an event that isn't
+// a EV_NO_ACTION event or after maxLeadingEvents events. If no Spec ID event is found,
+// the events read so far are returned and the format of the log is determined by the
+// first event. This returns io.EOF with the events read so far if the end of the log
+// is reached.
+func (p *Parser) readLeadingEvents(r io.Reader, options *LogOptions, report func()) (events []*Event, err error) {
+	for len(events) < maxLeadingEvents {
+		event, err := p.er.readEvent(r, options.eagerEventDataDecoder())
+		if err != nil {
+			return events, err
+		}
+
+		options.onEvent(event)
+		report()
+		events = append(events, event)
+
+		if isSpecIdEvent(event) || event.EventType != EventTypeNoAction {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// These are offsets of fields in a Spec ID event, which is always in the format defined
+// for logs for TPM family 1.2: PCR index 0, EV_NO_ACTION, a zero SHA-1 digest, the event
+// size and then the event data, which starts with a signature beginning with "Spec ID Event".
+const (
+	specIdEventSizeOffset      = 28
+	specIdEventSignatureOffset = 32
+)
+
+// isSpecIdEventAt indicates whether the supplied data starts with a Spec ID event.
+func isSpecIdEventAt(data []byte) bool {
+	if len(data) < specIdEventSignatureOffset+len("Spec ID Event") {
+		return false
+	}
+	if binary.LittleEndian.Uint32(data[0:]) != 0 || EventType(binary.LittleEndian.Uint32(data[4:])) != EventTypeNoAction {
+		return false
+	}
+	for _, b := range data[8:specIdEventSizeOffset] {
+		if b != 0 {
+			return false
+		}
+	}
+	return bytes.HasPrefix(data[specIdEventSignatureOffset:], []byte("Spec ID Event"))
+}
+
+// isPaddingData indicates whether the supplied data only contains 0x00 or 0xff bytes,
+// which some firmware uses to fill the log buffer after the last event.
+func isPaddingData(data []byte) bool {
+	for _, b := range data {
+		if b != 0x00 && b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLogs reads a stream containing one or more concatenated event logs from r using
+// the supplied options, such as an archive of the logs from several boots. Each log is
+// read as described in ReadLog, and the next log begins at the first Spec ID event that
+// follows the events of the previous log, or after any end of log marker and padding
+// that terminates the previous log. Each log after the first must begin with its Spec
+// ID event.
+//
+// If the stream doesn't contain any events, ErrEmptyLog is returned. If an error occurs
+// whilst reading one of the logs, this returns the logs read so far, including the
+// incomplete log, with the error.
+func ParseLogs(r io.Reader, options *LogOptions) ([]*Log, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []*Log
+	parser := NewParser()
+	for i, start := 0, 0; start < len(data); i++ {
+		br := bytes.NewReader(data[start:])
+		atNextLog := func() bool {
+			return isSpecIdEventAt(data[len(data)-br.Len():])
+		}
+
+		log, err := parser.parse(br, options, 0, atNextLog)
+		if log != nil {
+			logs = append(logs, log)
+		}
+		switch {
+		case err == ErrEmptyLog && len(logs) > 0:
+			// The remainder of the stream is an end of log marker or padding.
+			return logs, nil
+		case err == ErrEmptyLog:
+			return nil, err
+		case err != nil:
+			return logs, xerrors.Errorf("cannot read log %d at offset 0x%x: %w", i, start, err)
+		}
+
+		start = len(data) - br.Len()
+
+		// Skip any padding that follows an end of log marker, stopping at
+		// the next Spec ID event.
+		next := start
+		for next < len(data) && !isSpecIdEventAt(data[next:]) {
+			next++
+		}
+		if isPaddingData(data[start:next]) {
+			start = next
+		}
+	}
+
+	if len(logs) == 0 {
+		return nil, ErrEmptyLog
+	}
+	return logs, nil
+}
+
+// LogInfo contains the metadata for an event log, which is determined from its Spec
+// ID event.
+type LogInfo struct {
+	Spec          Spec            // The specification to which the log conforms
+	Algorithms    AlgorithmIdList // The digest algorithms that appear in the log
+	PlatformClass uint32          // The platformClass field of the Spec ID event
+}
+
+// ReadLogHeader reads the Spec ID event from the start of the event log read from r
+// and returns the metadata for the log, without reading the rest of the events. This
+// is much cheaper than ReadLog when only the metadata is required, such as when
+// classifying a large number of logs. The log must be in the format defined in one
+// of the PC Client Platform Firmware Profile specifications.
+//
+// If the log doesn't contain any events, ErrEmptyLog is returned.
+func ReadLogHeader(r io.Reader) (*LogInfo, error) {
+	events, err := new(Parser).readLeadingEvents(r, &LogOptions{}, func() {})
+	switch {
+	case len(events) == 0 && err == io.EOF:
+		return nil, ErrEmptyLog
+	case err != nil && err != io.EOF:
+		return nil, err
+	}
+
+	header := events[specIdEventIndex(events)]
+	log, _ := newLog(header)
+	return &LogInfo{
+		Spec:          log.Spec,
+		Algorithms:    log.Algorithms,
+		PlatformClass: platformClass(header)}, nil
+}
+
+// ReadLogFromSection reads an event log that is embedded in a larger blob, such
+// as a firmware dump or ACPI table. The log is read from r starting at offset off,
+// and is at most n bytes long. Parsing stops at the end of the section, so any
+// data in r after the section is ignored. Event offsets that are reported in
+// warnings, such as in EventDataError, are relative to the start of r rather than
+// the start of the section. See ReadLog for further details.
+func ReadLogFromSection(r io.ReaderAt, off, n int64, options *LogOptions) (*Log, error) {
+	return new(Parser).parse(io.NewSectionReader(r, off, n), options, off, nil)
+}
+
+// RawEventReader reads events from a log without decoding the event data, which is
+// cheaper than ReadLog when only the PCR index, event type and digests of each event
+// are required.
+type RawEventReader struct {
+	r           io.Reader
+	er          eventReader
+	started     bool
+	cryptoAgile bool
+	digestSizes []EFISpecIdEventAlgorithmSize
+}
+
+// NewRawEventReader returns a new RawEventReader that reads events from r. The log
+// must be in the format defined in one of the PC Client Platform Firmware Profile
+// specifications.
+func NewRawEventReader(r io.Reader) *RawEventReader {
+	return &RawEventReader{r: r}
+}
+
+// ReadEvent returns the next event from the log, or io.EOF if there are no more
+// events. The first event is always decoded as it determines the format of the log.
+// The data for subsequent events is returned as OpaqueEventData.
+func (r *RawEventReader) ReadEvent() (*Event, error) {
+	if !r.started {
+		event, err := r.er.readEvent(r.r, (&LogOptions{}).eagerEventDataDecoder())
+		if err != nil {
+			return nil, err
+		}
+		log, digestSizes := newLog(event)
+		r.started = true
+		r.cryptoAgile = log.Spec.IsEFI_2()
+		r.digestSizes = digestSizes
+		return event, nil
+	}
+
+	if r.cryptoAgile {
+		return r.er.readEventCryptoAgile(r.r, r.digestSizes, decodeEventDataRaw)
 	}
+	return r.er.readEvent(r.r, decodeEventDataRaw)
 }
@@ -0,0 +1,861 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/canonical/go-tpm2"
+
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type logreaderSuite struct{}
+
+var _ = Suite(&logreaderSuite{})
+
+func (s *logreaderSuite) TestReadLogFromSection(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	blob := new(bytes.Buffer)
+	blob.Write(bytes.Repeat([]byte{0xa5}, 100))
+	blob.Write(data)
+	blob.Write(bytes.Repeat([]byte{0x5a}, 50))
+
+	log, err := ReadLogFromSection(bytes.NewReader(blob.Bytes()), 100, int64(len(data)), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec, DeepEquals, expected.Spec)
+	c.Check(log.Algorithms, DeepEquals, expected.Algorithms)
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events {
+		c.Check(event.PCRIndex, Equals, expected.Events[i].PCRIndex)
+		c.Check(event.EventType, Equals, expected.Events[i].EventType)
+		c.Check(event.Digests, DeepEquals, expected.Events[i].Digests)
+		c.Check(event.Data.Bytes(), DeepEquals, expected.Events[i].Data.Bytes())
+	}
+}
+
+func (s *logreaderSuite) TestReadLogFromSectionOffsets(c *C) {
+	data := s.readTestLogBytes(c)
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(expected.Warnings, HasLen, 1)
+
+	blob := new(bytes.Buffer)
+	blob.Write(bytes.Repeat([]byte{0xa5}, 100))
+	blob.Write(data)
+
+	log, err := ReadLogFromSection(bytes.NewReader(blob.Bytes()), 100, int64(len(data)), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(log.Warnings, HasLen, 1)
+	c.Check(log.Warnings[0], ErrorMatches, `cannot decode data for event 111 \(PCR 4, type EV_EFI_BOOT_SERVICES_APPLICATION, offset 0x86d3\): .*`)
+
+	var e *EventDataError
+	c.Assert(xerrors.As(log.Warnings[0], &e), Equals, true)
+	c.Check(e.Index, Equals, 111)
+	c.Check(e.Offset, Equals, expected.Warnings[0].(*EventDataError).Offset+100)
+	c.Check(blob.Bytes()[e.Offset:e.Offset+int64(len(log.Events[111].RawBytes()))], DeepEquals, log.Events[111].RawBytes())
+}
+
+func (s *logreaderSuite) TestReadLogOnEvent(c *C) {
+	f, err := os.Open("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	var events []*Event
+	log, err := ReadLog(f, &LogOptions{OnEvent: func(event *Event) {
+		events = append(events, event)
+	}})
+	c.Assert(err, IsNil)
+	c.Check(events, DeepEquals, log.Events)
+}
+
+func (s *logreaderSuite) TestRawEventReader(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	r := NewRawEventReader(bytes.NewReader(data))
+
+	var events []*Event
+	for {
+		event, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		events = append(events, event)
+	}
+
+	c.Assert(events, HasLen, len(expected.Events))
+	c.Check(events[0], DeepEquals, expected.Events[0])
+	for i, event := range events[1:] {
+		c.Check(event.PCRIndex, Equals, expected.Events[i+1].PCRIndex)
+		c.Check(event.EventType, Equals, expected.Events[i+1].EventType)
+		c.Check(event.Digests, DeepEquals, expected.Events[i+1].Digests)
+		c.Check(event.Data, DeepEquals, OpaqueEventData(expected.Events[i+1].Data.Bytes()))
+	}
+}
+
+func (s *logreaderSuite) TestRawEventReaderEmpty(c *C) {
+	_, err := NewRawEventReader(bytes.NewReader(nil)).ReadEvent()
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *logreaderSuite) BenchmarkReadLog(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	c.ResetTimer()
+
+	for i := 0; i < c.N; i++ {
+		_, err := ReadLog(bytes.NewReader(data), &LogOptions{EnableGrub: true})
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *logreaderSuite) BenchmarkRawEventReader(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	c.ResetTimer()
+
+	for i := 0; i < c.N; i++ {
+		r := NewRawEventReader(bytes.NewReader(data))
+		for {
+			_, err := r.ReadEvent()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *logreaderSuite) TestReadLogWarnings(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Assert(log.Warnings, HasLen, 1)
+	c.Check(log.Warnings[0], ErrorMatches, `cannot decode data for event 111 \(PCR 4, type EV_EFI_BOOT_SERVICES_APPLICATION, offset 0x866f\): `+
+		`cannot read DevicePath \(0 bytes\): unexpected EOF`)
+	c.Check(xerrors.Is(log.Warnings[0], io.ErrUnexpectedEOF), Equals, true)
+
+	var e *EventDataError
+	c.Assert(xerrors.As(log.Warnings[0], &e), Equals, true)
+	c.Check(e.Index, Equals, 111)
+	c.Check(e.PCRIndex, Equals, PCRIndex(4))
+	c.Check(e.EventType, Equals, EventTypeEFIBootServicesApplication)
+
+	var offset int64
+	for _, event := range log.Events[:111] {
+		offset += int64(len(event.RawBytes()))
+	}
+	c.Check(e.Offset, Equals, offset)
+	c.Check(log.Events[111].RawBytes(), DeepEquals, s.readTestLogBytes(c)[offset:offset+int64(len(log.Events[111].RawBytes()))])
+}
+
+func (s *logreaderSuite) readTestLogBytes(c *C) []byte {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	return data
+}
+
+func (s *logreaderSuite) TestReadLogLazyData(c *C) {
+	expected := readTestLog(c, &LogOptions{})
+	log := readTestLog(c, &LogOptions{LazyData: true})
+
+	c.Check(log.Spec, Equals, expected.Spec)
+	c.Check(log.Events[0], DeepEquals, expected.Events[0])
+	c.Check(log.Warnings, HasLen, 0)
+
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events[1:] {
+		data, ok := event.Data.(*LazyEventData)
+		c.Assert(ok, Equals, true)
+		c.Check(data.Bytes(), DeepEquals, expected.Events[i+1].Data.Bytes())
+		c.Check(data.Decode(), DeepEquals, expected.Events[i+1].Data)
+		c.Check(data.String(), Equals, expected.Events[i+1].Data.String())
+		c.Check(event.Equal(expected.Events[i+1]), Equals, true)
+	}
+}
+
+func (s *logreaderSuite) TestParserReuse(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	p := NewParser()
+	var logs []*Log
+	for i := 0; i < 3; i++ {
+		log, err := p.Parse(bytes.NewReader(data), &LogOptions{})
+		c.Assert(err, IsNil)
+		logs = append(logs, log)
+	}
+
+	for _, log := range logs {
+		c.Check(log.Spec, Equals, expected.Spec)
+		c.Check(log.Algorithms, DeepEquals, expected.Algorithms)
+		c.Check(log.Warnings, HasLen, len(expected.Warnings))
+		c.Assert(log.Events, HasLen, len(expected.Events))
+		for i, event := range log.Events {
+			c.Check(event.Equal(expected.Events[i]), Equals, true)
+		}
+	}
+
+	// Check that the logs don't share events.
+	c.Check(logs[0].Events[1] == logs[1].Events[1], Equals, false)
+	logs[0].Events = append(logs[0].Events[:1], logs[0].Events[2:]...)
+	c.Check(logs[1].Events[1].Equal(expected.Events[1]), Equals, true)
+}
+
+func (s *logreaderSuite) TestParserEmpty(c *C) {
+	log, err := new(Parser).Parse(bytes.NewReader(nil), &LogOptions{})
+	c.Check(err, Equals, ErrEmptyLog)
+	c.Check(log, IsNil)
+}
+
+func (s *logreaderSuite) TestReadLogOnlyPadding(c *C) {
+	log, err := ReadLog(bytes.NewReader(make([]byte, 4096)), &LogOptions{})
+	c.Check(err, Equals, ErrEmptyLog)
+	c.Check(log, IsNil)
+}
+
+func (s *logreaderSuite) TestReadLogOnlyEndMarker(c *C) {
+	data := []byte{0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Check(err, Equals, ErrEmptyLog)
+	c.Check(log, IsNil)
+}
+
+func (s *logreaderSuite) TestReadLogOnlySpecIdEvent(c *C) {
+	data := s.readTestLogBytes(c)
+	log, err := ReadLog(bytes.NewReader(data[:69]), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Events, HasLen, 1)
+	c.Check(log.Spec.IsEFI_2(), Equals, true)
+}
+
+func (s *logreaderSuite) BenchmarkParser(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	p := NewParser()
+	c.ResetTimer()
+
+	for i := 0; i < c.N; i++ {
+		_, err := p.Parse(bytes.NewReader(data), &LogOptions{EnableGrub: true})
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *logreaderSuite) testReadLogWithPadding(c *C, padding byte) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	data = append(data, bytes.Repeat([]byte{padding}, 4096)...)
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events {
+		c.Check(event.Equal(expected.Events[i]), Equals, true)
+	}
+}
+
+func (s *logreaderSuite) TestReadLogWithFFPadding(c *C) {
+	s.testReadLogWithPadding(c, 0xff)
+}
+
+func (s *logreaderSuite) TestReadLogWithZeroPadding(c *C) {
+	s.testReadLogWithPadding(c, 0x00)
+}
+
+func (s *logreaderSuite) TestReadEventEndOfLog(c *C) {
+	_, err := ReadEvent(bytes.NewReader(bytes.Repeat([]byte{0xff}, 32)), &LogOptions{})
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *logreaderSuite) TestReadEventZeroHeaderNotPadding(c *C) {
+	// An all-zero header in the TPM 1.2 format is only padding if the digest and
+	// event size are also zero.
+	event := &Event{
+		PCRIndex:  0,
+		EventType: EventTypePrebootCert,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: ComputeEventDigest(crypto.SHA1, []byte("foo"))},
+		Data:      OpaqueEventData("foo")}
+	w := new(bytes.Buffer)
+	c.Assert(event.Write(w), IsNil)
+
+	read, err := ReadEvent(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(read.PCRIndex, Equals, PCRIndex(0))
+	c.Check(read.EventType, Equals, EventTypePrebootCert)
+	c.Check(read.Digests, DeepEquals, event.Digests)
+	c.Check(read.Data.Bytes(), DeepEquals, []byte("foo"))
+}
+
+func (s *logreaderSuite) TestReadEventZeroPadding(c *C) {
+	_, err := ReadEvent(bytes.NewReader(make([]byte, 32)), &LogOptions{})
+	c.Check(err, Equals, io.EOF)
+}
+
+type progressReport struct {
+	bytesRead  int64
+	totalBytes int64
+}
+
+func (s *logreaderSuite) TestReadLogProgress(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	var reports []progressReport
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{
+		Progress: func(bytesRead, totalBytes int64) {
+			reports = append(reports, progressReport{bytesRead, totalBytes})
+		}})
+	c.Assert(err, IsNil)
+	c.Assert(reports, HasLen, len(log.Events))
+
+	var last int64
+	for _, r := range reports {
+		c.Check(r.totalBytes, Equals, int64(len(data)))
+		c.Check(r.bytesRead > last, Equals, true)
+		last = r.bytesRead
+	}
+	c.Check(last, Equals, int64(len(data)))
+}
+
+func (s *logreaderSuite) TestReadLogProgressUnknownSize(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	var reports []progressReport
+	_, err = ReadLog(struct{ io.Reader }{bytes.NewReader(data)}, &LogOptions{
+		Progress: func(bytesRead, totalBytes int64) {
+			reports = append(reports, progressReport{bytesRead, totalBytes})
+		}})
+	c.Assert(err, IsNil)
+	c.Assert(reports, Not(HasLen), 0)
+	for _, r := range reports {
+		c.Check(r.totalBytes, Equals, int64(-1))
+	}
+	c.Check(reports[len(reports)-1].bytesRead, Equals, int64(len(data)))
+}
+
+func (s *logreaderSuite) TestReadLogProgressFromOffset(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	r := bytes.NewReader(append(bytes.Repeat([]byte{0xa5}, 100), data...))
+	_, err = r.Seek(100, io.SeekStart)
+	c.Assert(err, IsNil)
+
+	var total int64
+	_, err = ReadLog(r, &LogOptions{
+		Progress: func(_, totalBytes int64) {
+			total = totalBytes
+		}})
+	c.Assert(err, IsNil)
+	c.Check(total, Equals, int64(len(data)))
+}
+
+func (s *logreaderSuite) readLogWithAppendedEvent(c *C, event *Event) *Log {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	w := bytes.NewBuffer(data)
+	c.Assert(event.WriteCryptoAgile(w, log.Events[0].Data.(*SpecIdEvent03).DigestSizes), IsNil)
+
+	log, err = ReadLog(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	return log
+}
+
+func (s *logreaderSuite) TestReadLogByteSwappedSeparator(c *C) {
+	log := s.readLogWithAppendedEvent(c, &Event{
+		PCRIndex:  8,
+		EventType: EventTypeSeparator,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+		Data: OpaqueEventData{0x00, 0x00, 0x00, 0x01}})
+	c.Assert(log.Warnings, HasLen, 3)
+	c.Check(log.Warnings[2], ErrorMatches, `event 115 \(PCR 8\) is a separator with value 0x00000001, which is only valid in big-endian byte order: inconsistent byte order`)
+	c.Check(xerrors.Is(log.Warnings[2], ErrInconsistentByteOrder), Equals, true)
+}
+
+func (s *logreaderSuite) TestReadLogByteSwappedEventType(c *C) {
+	log := s.readLogWithAppendedEvent(c, &Event{
+		PCRIndex:  4,
+		EventType: EventType(0x0d000000),
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+		Data: OpaqueEventData("foo")})
+	c.Assert(log.Warnings, HasLen, 2)
+	c.Check(log.Warnings[1], ErrorMatches, `event 115 \(PCR 4\) has type 0x0d000000, which corresponds to EV_IPL in big-endian byte order: inconsistent byte order`)
+	c.Check(xerrors.Is(log.Warnings[1], ErrInconsistentByteOrder), Equals, true)
+}
+
+func (s *logreaderSuite) TestReadLogNoByteOrderWarnings(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for _, w := range log.Warnings {
+		c.Check(xerrors.Is(w, ErrInconsistentByteOrder), Equals, false)
+	}
+}
+
+func (s *logreaderSuite) TestReadLogSingleBank(c *C) {
+	// testdata/binary_bios_measurements_sha256 is the same log as
+	// testdata/binary_bios_measurements with the SHA-1 bank removed.
+	f, err := os.Open("testdata/binary_bios_measurements_sha256")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	log, err := ReadLog(f, &LogOptions{})
+	c.Assert(err, IsNil)
+
+	expected := readTestLog(c, &LogOptions{})
+
+	c.Check(log.Spec, Equals, expected.Spec)
+	c.Check(log.Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Check(log.Events[0].Data.(*SpecIdEvent03).DigestSizes, DeepEquals, []EFISpecIdEventAlgorithmSize{
+		{AlgorithmId: tpm2.HashAlgorithmSHA256, DigestSize: uint16(tpm2.HashAlgorithmSHA256.Size())}})
+	c.Check(log.Warnings, HasLen, len(expected.Warnings))
+
+	c.Assert(log.Events, HasLen, len(expected.Events))
+	for i, event := range log.Events[1:] {
+		c.Check(event.Digests, HasLen, 1)
+		c.Check(event.EquivalentTo(expected.Events[i+1]), Equals, true)
+		c.Check(event.Data, DeepEquals, expected.Events[i+1].Data)
+	}
+
+	pcrs, err := log.ReplayPCRs(log.Algorithms)
+	c.Assert(err, IsNil)
+	expectedPCRs, err := expected.ReplayPCRs(log.Algorithms)
+	c.Assert(err, IsNil)
+	c.Check(pcrs, DeepEquals, expectedPCRs)
+}
+
+func (s *logreaderSuite) TestReadLogZeroSizeEvents(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+	digestSizes := expected.Events[0].Data.(*SpecIdEvent03).DigestSizes
+
+	w := bytes.NewBuffer(data)
+	var events []*Event
+	for i := 0; i < 3; i++ {
+		event := &Event{
+			PCRIndex:  5,
+			EventType: EventTypeCompactHash,
+			Digests: DigestMap{
+				tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+				tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+			Data: OpaqueEventData(nil)}
+		c.Assert(event.WriteCryptoAgile(w, digestSizes), IsNil)
+		events = append(events, event)
+	}
+	final := &Event{
+		PCRIndex:  5,
+		EventType: EventTypeCompactHash,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+		Data: OpaqueEventData("foo")}
+	c.Assert(final.WriteCryptoAgile(w, digestSizes), IsNil)
+	events = append(events, final)
+
+	log, err := ReadLog(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(log.Events, HasLen, len(expected.Events)+len(events))
+	for i, event := range events {
+		c.Check(log.Events[len(expected.Events)+i].Equal(event), Equals, true)
+	}
+	c.Check(log.Events[len(log.Events)-1].Data.Bytes(), DeepEquals, []byte("foo"))
+}
+
+func (s *logreaderSuite) TestReadEventZeroSize(c *C) {
+	w := new(bytes.Buffer)
+	for _, data := range []string{"", "bar"} {
+		event := &Event{
+			PCRIndex:  4,
+			EventType: EventTypeCompactHash,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+			Data:      OpaqueEventData(data)}
+		c.Assert(event.Write(w), IsNil)
+	}
+
+	event, err := ReadEvent(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(event.Data.Bytes(), HasLen, 0)
+
+	event, err = ReadEvent(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(event.Data.Bytes(), DeepEquals, []byte("bar"))
+
+	_, err = ReadEvent(w, &LogOptions{})
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *logreaderSuite) TestReadLogSpecIdEventNotFirst(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	expected, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	leading := &Event{
+		PCRIndex:  0,
+		EventType: EventTypeNoAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+		Data:      &StartupLocalityEventData{StartupLocality: 3}}
+	w := new(bytes.Buffer)
+	c.Assert(leading.Write(w), IsNil)
+	w.Write(data)
+
+	log, err := ReadLog(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec, Equals, expected.Spec)
+	c.Check(log.Algorithms, DeepEquals, expected.Algorithms)
+	c.Assert(log.Events, HasLen, len(expected.Events)+1)
+
+	data0, ok := log.Events[0].Data.(*StartupLocalityEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data0.StartupLocality, Equals, uint8(3))
+	_, ok = log.Events[1].Data.(*SpecIdEvent03)
+	c.Check(ok, Equals, true)
+
+	for i, event := range expected.Events {
+		c.Check(log.Events[i+1].PCRIndex, Equals, event.PCRIndex)
+		c.Check(log.Events[i+1].EventType, Equals, event.EventType)
+		c.Check(log.Events[i+1].Digests, DeepEquals, event.Digests)
+	}
+
+	c.Assert(log.Warnings, HasLen, len(expected.Warnings)+1)
+	c.Check(log.Warnings[0], ErrorMatches, `the Spec ID event is event 1 rather than the first event`)
+}
+
+func (s *logreaderSuite) TestReadLogNoSpecIdEvent(c *C) {
+	log := NewLogForTesting([]*Event{
+		{
+			PCRIndex:  0,
+			EventType: EventTypeNoAction,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+			Data:      &StartupLocalityEventData{StartupLocality: 3}},
+		{
+			PCRIndex:  0,
+			EventType: EventTypeSeparator,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+			Data:      &SeparatorEventData{Value: SeparatorEventNormalValue}}})
+
+	w := new(bytes.Buffer)
+	c.Assert(log.Write(w), IsNil)
+
+	log, err := ReadLog(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec, Equals, Spec{})
+	c.Check(log.Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA1})
+	c.Check(log.Events, HasLen, 2)
+	c.Check(log.Warnings, HasLen, 0)
+}
+
+func (s *logreaderSuite) TestWriteLogSpecIdEventNotFirst(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	leading := &Event{
+		PCRIndex:  0,
+		EventType: EventTypeNoAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+		Data:      &StartupLocalityEventData{StartupLocality: 3}}
+	w := new(bytes.Buffer)
+	c.Assert(leading.Write(w), IsNil)
+	w.Write(data)
+	expected := w.Bytes()
+
+	log, err := ReadLog(bytes.NewReader(expected), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	w = new(bytes.Buffer)
+	c.Check(log.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, expected)
+}
+
+func (s *logreaderSuite) TestReadLogHeader(c *C) {
+	f, err := os.Open("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	info, err := ReadLogHeader(f)
+	c.Assert(err, IsNil)
+	c.Check(info.Spec, Equals, Spec{PlatformType: PlatformTypeEFI, Major: 2})
+	c.Check(info.Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256})
+	c.Check(info.PlatformClass, Equals, uint32(0))
+
+	// Only the Spec ID event should have been read.
+	offset, err := f.Seek(0, io.SeekCurrent)
+	c.Check(err, IsNil)
+	c.Check(offset, Equals, int64(69))
+}
+
+func (s *logreaderSuite) TestReadLogHeaderSingleBank(c *C) {
+	f, err := os.Open("testdata/binary_bios_measurements_sha256")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	info, err := ReadLogHeader(f)
+	c.Assert(err, IsNil)
+	c.Check(info.Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+}
+
+func (s *logreaderSuite) TestReadLogHeaderEmpty(c *C) {
+	info, err := ReadLogHeader(new(bytes.Buffer))
+	c.Check(err, Equals, ErrEmptyLog)
+	c.Check(info, IsNil)
+}
+
+func (s *logreaderSuite) TestParseLogs(c *C) {
+	data := s.readTestLogBytes(c)
+	sha256Data, err := ioutil.ReadFile("testdata/binary_bios_measurements_sha256")
+	c.Assert(err, IsNil)
+
+	w := new(bytes.Buffer)
+	w.Write(data)
+	w.Write(sha256Data)
+	w.Write(data)
+
+	logs, err := ParseLogs(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 3)
+
+	expected := readTestLog(c, &LogOptions{})
+	for i, log := range logs {
+		c.Check(log.Events, HasLen, len(expected.Events), Commentf("log %d", i))
+		c.Check(log.Spec, Equals, expected.Spec)
+	}
+	c.Check(logs[0].Algorithms, DeepEquals, expected.Algorithms)
+	c.Check(logs[1].Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Check(logs[2].Algorithms, DeepEquals, expected.Algorithms)
+}
+
+func (s *logreaderSuite) TestParseLogsEndMarker(c *C) {
+	data := s.readTestLogBytes(c)
+
+	w := new(bytes.Buffer)
+	w.Write(data)
+	w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	w.Write(data)
+
+	logs, err := ParseLogs(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 2)
+	c.Check(logs[0].Events, HasLen, 115)
+	c.Check(logs[1].Events, HasLen, 115)
+}
+
+func (s *logreaderSuite) TestParseLogsSingle(c *C) {
+	logs, err := ParseLogs(bytes.NewReader(s.readTestLogBytes(c)), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 1)
+	c.Check(logs[0].Events, HasLen, 115)
+}
+
+func (s *logreaderSuite) TestParseLogsEmpty(c *C) {
+	logs, err := ParseLogs(new(bytes.Buffer), &LogOptions{})
+	c.Check(err, Equals, ErrEmptyLog)
+	c.Check(logs, IsNil)
+}
+
+func (s *logreaderSuite) TestParseLogsOnlyPadding(c *C) {
+	logs, err := ParseLogs(bytes.NewReader(make([]byte, 4096)), &LogOptions{})
+	c.Check(err, Equals, ErrEmptyLog)
+	c.Check(logs, IsNil)
+}
+
+func (s *logreaderSuite) TestParseLogsPadding(c *C) {
+	data := s.readTestLogBytes(c)
+
+	w := new(bytes.Buffer)
+	w.Write(data)
+	w.Write(make([]byte, 100))
+	w.Write(data)
+	w.Write(bytes.Repeat([]byte{0xff}, 100))
+
+	logs, err := ParseLogs(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 2)
+	c.Check(logs[0].Events, HasLen, 115)
+	c.Check(logs[1].Events, HasLen, 115)
+}
+
+func (s *logreaderSuite) TestParseLogsSpecIdEventInEventData(c *C) {
+	// An event with data that looks like a Spec ID event doesn't start a new log.
+	data := s.readTestLogBytes(c)
+	log := s.readLogWithTaggedEvent(c, &LogOptions{}, data[:len(readTestLog(c, &LogOptions{}).Events[0].RawBytes())])
+
+	w := new(bytes.Buffer)
+	for _, event := range log.Events {
+		w.Write(event.RawBytes())
+	}
+	w.Write(data)
+
+	logs, err := ParseLogs(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 2)
+	c.Check(logs[0].Events, HasLen, 116)
+	c.Check(logs[1].Events, HasLen, 115)
+}
+
+func (s *logreaderSuite) TestParseLogsError(c *C) {
+	data := s.readTestLogBytes(c)
+
+	w := new(bytes.Buffer)
+	w.Write(data)
+	w.Write(data[:100])
+
+	logs, err := ParseLogs(w, &LogOptions{})
+	c.Check(err, ErrorMatches, `cannot read log 1 at offset 0x[0-9a-f]+: .*`)
+	c.Assert(logs, HasLen, 2)
+	c.Check(logs[0].Events, HasLen, 115)
+}
+
+type testVendorEventData struct {
+	data  []byte
+	value uint32
+}
+
+func (d *testVendorEventData) String() string {
+	return fmt.Sprintf("vendor event { value=%d }", d.value)
+}
+
+func (d *testVendorEventData) Bytes() []byte {
+	return d.data
+}
+
+func (d *testVendorEventData) Write(w io.Writer) error {
+	_, err := w.Write(d.data)
+	return err
+}
+
+func (d *testVendorEventData) Equal(other EventData) bool {
+	o, ok := other.(*testVendorEventData)
+	return ok && o.value == d.value
+}
+
+func (s *logreaderSuite) readLogWithTaggedEvent(c *C, options *LogOptions, data []byte) *Log {
+	w := bytes.NewBuffer(s.readTestLogBytes(c))
+	event := &Event{
+		PCRIndex:  4,
+		EventType: EventTypeEventTag,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+		Data: OpaqueEventData(data)}
+	c.Assert(event.WriteCryptoAgile(w, readTestLog(c, &LogOptions{}).Events[0].Data.(*SpecIdEvent03).DigestSizes), IsNil)
+
+	log, err := ReadLog(w, options)
+	c.Assert(err, IsNil)
+	c.Assert(log.Events, HasLen, 116)
+	return log
+}
+
+func (s *logreaderSuite) TestRegisterEventDataDecoder(c *C) {
+	options := new(LogOptions)
+	options.RegisterEventDataDecoder(EventTypeEventTag, 0x1234, func(data []byte, order binary.ByteOrder) (EventData, error) {
+		return &testVendorEventData{data: data, value: order.Uint32(data[8:])}, nil
+	})
+
+	data := decodeHexString(c, "341200000400000005000000")
+	log := s.readLogWithTaggedEvent(c, options, data)
+	c.Check(log.Warnings, HasLen, 1)
+	c.Check(log.Events[115].Data, DeepEquals, &testVendorEventData{data: data, value: 5})
+	c.Check(log.Events[115].Data.String(), Equals, "vendor event { value=5 }")
+}
+
+func (s *logreaderSuite) TestRegisterEventDataDecoderDifferentTag(c *C) {
+	options := new(LogOptions)
+	options.RegisterEventDataDecoder(EventTypeEventTag, 0x1234, func(data []byte, order binary.ByteOrder) (EventData, error) {
+		c.Error("unexpected call to decoder")
+		return nil, nil
+	})
+
+	data := decodeHexString(c, "351200000400000005000000")
+	log := s.readLogWithTaggedEvent(c, options, data)
+	c.Check(log.Events[115].Data, DeepEquals, OpaqueEventData(data))
+}
+
+func (s *logreaderSuite) TestRegisterEventDataDecoderNotRecognized(c *C) {
+	options := new(LogOptions)
+	options.RegisterEventDataDecoder(EventTypeEventTag, 0x1234, func(data []byte, order binary.ByteOrder) (EventData, error) {
+		return nil, nil
+	})
+
+	data := decodeHexString(c, "341200000400000005000000")
+	log := s.readLogWithTaggedEvent(c, options, data)
+	c.Check(log.Events[115].Data, DeepEquals, OpaqueEventData(data))
+}
+
+func (s *logreaderSuite) TestRegisterEventDataDecoderError(c *C) {
+	options := new(LogOptions)
+	options.RegisterEventDataDecoder(EventTypeEventTag, 0x1234, func(data []byte, order binary.ByteOrder) (EventData, error) {
+		return nil, errors.New("some error")
+	})
+
+	data := decodeHexString(c, "341200000400000005000000")
+	log := s.readLogWithTaggedEvent(c, options, data)
+	c.Check(log.Events[115].Data.Bytes(), DeepEquals, data)
+	c.Assert(log.Warnings, HasLen, 2)
+	c.Check(log.Warnings[1], ErrorMatches, `cannot decode data for event 115 \(PCR 4, type EV_EVENT_TAG, offset 0x[0-9a-f]+\): some error`)
+}
+
+func (s *logreaderSuite) TestRegisterEventDataDecoderUntagged(c *C) {
+	options := new(LogOptions)
+	options.RegisterEventDataDecoder(EventTypeEFIVariableDriverConfig, 0, func(data []byte, order binary.ByteOrder) (EventData, error) {
+		return &testVendorEventData{data: data}, nil
+	})
+
+	log := readTestLog(c, options)
+	n := 0
+	for i, event := range log.Events {
+		if event.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+		c.Check(event.Data, FitsTypeOf, &testVendorEventData{}, Commentf("event %d", i))
+		n++
+	}
+	c.Check(n, Equals, 7)
+}
+
+func (s *logreaderSuite) TestReadLogEnableSystemdBoot(c *C) {
+	data := decodeHexString(c, "2a58bcf5360000006c006f0061006400650072002f0065006e00740072006900650073002f007500620075006e00740075002e0063006f006e0066000000")
+
+	log := s.readLogWithTaggedEvent(c, &LogOptions{EnableSystemdBoot: true}, data)
+	c.Check(log.Events[115].Data, DeepEquals, DecodeEventDataSystemdBoot(data, EventTypeEventTag))
+
+	log = s.readLogWithTaggedEvent(c, &LogOptions{}, data)
+	c.Check(log.Events[115].Data, DeepEquals, OpaqueEventData(data))
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+// LogView is a read-only view of a Log, which is intended to be shared between
+// consumers that must not be able to modify the log or affect each other, such as
+// goroutines serving different requests. It never returns references to the
+// Events slice, the Digests maps or the digests of the underlying log, so these
+// can't be modified accidentally through the view.
+//
+// The EventData associated with each event is shared with the underlying log. None
+// of the EventData implementations in this package are modified after decoding, and
+// a *LazyEventData is safe to decode from multiple goroutines, but callers must not
+// modify the fields of decoded event data obtained from a view.
+//
+// A LogView is safe for concurrent use by multiple goroutines as long as the
+// underlying log isn't modified.
+type LogView struct {
+	log *Log
+}
+
+// ReadOnly returns a read-only view of this log. The view refers to this log rather
+// than containing a copy of it, so this log must not be modified whilst the view is
+// in use.
+func (l *Log) ReadOnly() *LogView {
+	return &LogView{log: l}
+}
+
+func copyEvent(event *Event) *Event {
+	out := &Event{
+		PCRIndex:  event.PCRIndex,
+		EventType: event.EventType,
+		Digests:   make(DigestMap),
+		Data:      event.Data,
+		raw:       event.raw}
+	for alg, digest := range event.Digests {
+		out.Digests[alg] = append(Digest(nil), digest...)
+	}
+	return out
+}
+
+// Spec returns the specification to which the log conforms.
+func (v *LogView) Spec() Spec {
+	return v.log.Spec
+}
+
+// Algorithms returns a copy of the digest algorithms that appear in the log.
+func (v *LogView) Algorithms() AlgorithmIdList {
+	return append(AlgorithmIdList(nil), v.log.Algorithms...)
+}
+
+// Len returns the number of events in the log.
+func (v *LogView) Len() int {
+	return len(v.log.Events)
+}
+
+// Event returns a copy of the event at the specified index, which must be less
+// than Len. The copy shares its event data with the log.
+func (v *LogView) Event(i int) *Event {
+	return copyEvent(v.log.Events[i])
+}
+
+// Events returns a copy of every event in the log. The copies share their event
+// data with the log.
+func (v *LogView) Events() (out []*Event) {
+	for _, event := range v.log.Events {
+		out = append(out, copyEvent(event))
+	}
+	return out
+}
+
+// Warnings returns a copy of the warnings recorded whilst reading the log.
+func (v *LogView) Warnings() []error {
+	return append([]error(nil), v.log.Warnings...)
+}
+
+// Copy returns a new Log that contains copies of the events in the log, which
+// can be used to call any of the methods on Log or modified without affecting
+// the log or other users of this view. The events in the returned log share
+// their event data with the log.
+func (v *LogView) Copy() *Log {
+	return &Log{
+		Spec:       v.log.Spec,
+		Algorithms: v.Algorithms(),
+		Events:     v.Events(),
+		Warnings:   v.Warnings()}
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"sync"
+
+	"github.com/canonical/go-tpm2"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type logviewSuite struct{}
+
+var _ = Suite(&logviewSuite{})
+
+func (s *logviewSuite) TestReadOnly(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	view := log.ReadOnly()
+
+	c.Check(view.Spec(), Equals, log.Spec)
+	c.Check(view.Algorithms(), DeepEquals, log.Algorithms)
+	c.Check(view.Len(), Equals, len(log.Events))
+	c.Check(view.Warnings(), DeepEquals, log.Warnings)
+
+	events := view.Events()
+	c.Assert(events, HasLen, len(log.Events))
+	for i, event := range events {
+		c.Check(event, Not(Equals), log.Events[i])
+		c.Check(event, DeepEquals, log.Events[i])
+		c.Check(view.Event(i), DeepEquals, log.Events[i])
+	}
+}
+
+func (s *logviewSuite) TestReadOnlyPreventsMutation(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	view := log.ReadOnly()
+
+	pcr := log.Events[3].PCRIndex
+	expected := append(Digest(nil), log.Events[3].Digests[tpm2.HashAlgorithmSHA256]...)
+
+	event := view.Event(3)
+	event.PCRIndex = 10
+	event.Digests[tpm2.HashAlgorithmSHA256][0] ^= 0xff
+	delete(event.Digests, tpm2.HashAlgorithmSHA1)
+
+	events := view.Events()
+	events[3] = nil
+
+	algs := view.Algorithms()
+	algs[0] = tpm2.HashAlgorithmSHA512
+
+	c.Check(log.Events[3].PCRIndex, Equals, pcr)
+	c.Check(log.Events[3].Digests, HasLen, 2)
+	c.Check(log.Events[3].Digests[tpm2.HashAlgorithmSHA256], DeepEquals, expected)
+	c.Check(view.Events()[3], NotNil)
+	c.Check(log.Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA1, tpm2.HashAlgorithmSHA256})
+}
+
+func (s *logviewSuite) TestCopy(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	view := log.ReadOnly()
+
+	copied := view.Copy()
+	c.Check(copied, DeepEquals, log)
+
+	copied.Events = copied.Events[:10]
+	copied.Events[0].Digests[tpm2.HashAlgorithmSHA1][0] ^= 0xff
+	c.Check(log.Events, HasLen, 115)
+	c.Check(view.Event(0), DeepEquals, readTestLog(c, &LogOptions{}).Events[0])
+
+	expected, err := log.ReplayPCRs(log.Algorithms)
+	c.Assert(err, IsNil)
+	pcrs, err := view.Copy().ReplayPCRs(log.Algorithms)
+	c.Check(err, IsNil)
+	c.Check(pcrs, DeepEquals, expected)
+}
+
+func (s *logviewSuite) TestReadOnlyConcurrent(c *C) {
+	log := readTestLog(c, &LogOptions{LazyData: true})
+	view := log.ReadOnly()
+
+	expected, err := readTestLog(c, &LogOptions{}).ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < view.Len(); j++ {
+				_ = view.Event(j).Data.String()
+			}
+			pcrs, err := view.Copy().ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+			c.Check(err, IsNil)
+			c.Check(pcrs, DeepEquals, expected)
+		}()
+	}
+	wg.Wait()
+}
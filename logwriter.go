@@ -5,8 +5,11 @@
 package tcglog
 
 import (
+	"fmt"
 	"io"
 
+	"github.com/canonical/go-tpm2"
+
 	"golang.org/x/xerrors"
 )
 
@@ -19,34 +22,80 @@ func (l *Log) Write(w io.Writer) error {
 		return nil
 	}
 
+	// The events up to and including the Spec ID event are written in the format
+	// defined for the first event. See readLeadingEvents.
+	header := specIdEventIndex(l.Events)
+
 	var cryptoAgile bool
 	var digestSizes []EFISpecIdEventAlgorithmSize
 
-	switch d := l.Events[0].Data.(type) {
-	case *SpecIdEvent00:
-		_ = d
-	case *SpecIdEvent02:
-		_ = d
-	case *SpecIdEvent03:
+	if d, ok := l.Events[header].Data.(*SpecIdEvent03); ok {
 		cryptoAgile = true
 		digestSizes = d.DigestSizes
 	}
 
-	if err := l.Events[0].Write(w); err != nil {
-		return xerrors.Errorf("cannot write event 0: %w", err)
-	}
-
-	for i, event := range l.Events[1:] {
-		if cryptoAgile {
+	for i, event := range l.Events {
+		if cryptoAgile && i > header {
 			if err := event.WriteCryptoAgile(w, digestSizes); err != nil {
-				return xerrors.Errorf("cannot write event %d: %w", i+1, err)
+				return xerrors.Errorf("cannot write event %d: %w", i, err)
 			}
 		} else {
 			if err := event.Write(w); err != nil {
-				return xerrors.Errorf("cannot write event %d: %w", i+1, err)
+				return xerrors.Errorf("cannot write event %d: %w", i, err)
 			}
 		}
 	}
 
 	return nil
 }
+
+// WriteLegacy writes the event log to w in the legacy format used for TPM family 1.2,
+// where each event is a TCG_PCR_EVENT structure containing only a SHA-1 digest. This
+// can be used to convert a crypto-agile log for consumption by tools that don't
+// understand the crypto-agile format. The Spec ID event of a crypto-agile log is
+// omitted because it declares the crypto-agile format, so the resulting log has no
+// header and is read by ReadLog as a log for TPM family 1.2. Other digests are
+// discarded, and an error is returned if any event doesn't contain a SHA-1 digest.
+func (l *Log) WriteLegacy(w io.Writer) error {
+	for i, event := range l.Events {
+		if _, ok := event.Data.(*SpecIdEvent03); ok {
+			continue
+		}
+
+		digest, ok := event.Digests[tpm2.HashAlgorithmSHA1]
+		if !ok {
+			return fmt.Errorf("cannot write event %d: missing SHA-1 digest", i)
+		}
+
+		legacy := &Event{
+			PCRIndex:  event.PCRIndex,
+			EventType: event.EventType,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: digest},
+			Data:      event.Data}
+		if err := legacy.Write(w); err != nil {
+			return xerrors.Errorf("cannot write event %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// countingWriter counts the number of bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	n, err := w.w.Write(data)
+	w.n += int64(n)
+	return n, err
+}
+
+// WriteTo implements io.WriterTo. It writes the event log to w in the same way as
+// Write, and returns the number of bytes written.
+func (l *Log) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := l.Write(cw)
+	return cw.n, err
+}
@@ -7,9 +7,12 @@ package tcglog_test
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
+	"github.com/canonical/go-tpm2"
+
 	. "gopkg.in/check.v1"
 
 	. "github.com/canonical/tcglog-parser"
@@ -34,3 +37,82 @@ func (s *logwriterSuite) TestWriteLogCryptoAgile(c *C) {
 	c.Check(log.Write(w), IsNil)
 	c.Check(w.Bytes(), DeepEquals, expected.Bytes())
 }
+
+func (s *logwriterSuite) TestWriteTo(c *C) {
+	data, err := ioutil.ReadFile("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+
+	log, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+
+	var wt io.WriterTo = log
+	w := new(bytes.Buffer)
+	n, err := wt.WriteTo(w)
+	c.Check(err, IsNil)
+	c.Check(n, Equals, int64(len(data)))
+	c.Check(w.Bytes(), DeepEquals, data)
+}
+
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *limitedWriter) Write(data []byte) (int, error) {
+	if len(data) > w.n {
+		n, _ := w.w.Write(data[:w.n])
+		w.n = 0
+		return n, io.ErrShortWrite
+	}
+	w.n -= len(data)
+	return w.w.Write(data)
+}
+
+func (s *logwriterSuite) TestWriteToError(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	w := new(bytes.Buffer)
+	n, err := log.WriteTo(&limitedWriter{w: w, n: 100})
+	c.Check(err, ErrorMatches, `cannot write event 1: .*short write`)
+	c.Check(n, Equals, int64(100))
+	c.Check(w.Len(), Equals, 100)
+}
+
+func (s *logwriterSuite) TestWriteLegacy(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	w := new(bytes.Buffer)
+	c.Check(log.WriteLegacy(w), IsNil)
+	data := w.Bytes()
+
+	legacy, err := ReadLog(bytes.NewReader(data), &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(legacy.Algorithms, DeepEquals, AlgorithmIdList{tpm2.HashAlgorithmSHA1})
+	c.Check(legacy.Spec.IsEFI_2(), Equals, false)
+
+	// The Spec ID event is omitted.
+	c.Assert(legacy.Events, HasLen, len(log.Events)-1)
+	for i, event := range legacy.Events {
+		orig := log.Events[i+1]
+		c.Check(event.PCRIndex, Equals, orig.PCRIndex, Commentf("event %d", i))
+		c.Check(event.EventType, Equals, orig.EventType, Commentf("event %d", i))
+		c.Check(event.Digests, DeepEquals, DigestMap{tpm2.HashAlgorithmSHA1: orig.Digests[tpm2.HashAlgorithmSHA1]}, Commentf("event %d", i))
+		c.Check(event.Data.Bytes(), DeepEquals, orig.Data.Bytes(), Commentf("event %d", i))
+	}
+
+	// Writing a legacy log produces the same output in both formats.
+	w = new(bytes.Buffer)
+	c.Check(legacy.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, data)
+
+	w = new(bytes.Buffer)
+	c.Check(legacy.WriteLegacy(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, data)
+}
+
+func (s *logwriterSuite) TestWriteLegacyMissingSHA1(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	delete(log.Events[3].Digests, tpm2.HashAlgorithmSHA1)
+
+	c.Check(log.WriteLegacy(ioutil.Discard), ErrorMatches, `cannot write event 3: missing SHA-1 digest`)
+}
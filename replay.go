@@ -0,0 +1,235 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// ReplayPCRs replays the events in this log for each of the specified digest algorithms
+// and returns the resulting PCR values, which should correspond to the values in the TPM
+// that the log was measured to. The returned map contains an entry for each PCR that is
+// extended by at least one event in the log.
+//
+// EV_NO_ACTION events are not replayed, with the exception of a StartupLocality event in
+// PCR 0 which sets the initial value of PCR 0 according to the recorded locality. A
+// StartupLocality event that follows an event that extends PCR 0 is ignored.
+//
+// An error is returned if any of the specified algorithms are not available, or if any
+// of the events in the log do not contain a digest for one of the specified algorithms.
+func (l *Log) ReplayPCRs(algs AlgorithmIdList) (map[PCRIndex]DigestMap, error) {
+	return l.replay(algs, nil)
+}
+
+// replay replays the events in this log for each of the specified digest algorithms
+// and returns the resulting PCR values. If fn is supplied, it is called after each
+// event has been processed with the current PCR values, and the replay stops early if
+// it returns false.
+func (l *Log) replay(algs AlgorithmIdList, fn func(pcrs map[PCRIndex]DigestMap) bool) (map[PCRIndex]DigestMap, error) {
+	for _, alg := range algs {
+		if !alg.Available() {
+			return nil, fmt.Errorf("digest algorithm %v is not available", alg)
+		}
+	}
+
+	pcrs := make(map[PCRIndex]DigestMap)
+	pcrValues := func(index PCRIndex) DigestMap {
+		values, exists := pcrs[index]
+		if !exists {
+			values = make(DigestMap)
+			for _, alg := range algs {
+				values[alg] = make(Digest, alg.Size())
+			}
+			pcrs[index] = values
+		}
+		return values
+	}
+
+	pcr0Extended := false
+	for i, event := range l.Events {
+		if event.EventType == EventTypeNoAction {
+			// The locality only determines the initial value of PCR 0, so a
+			// StartupLocality event after PCR 0 has been extended is ignored.
+			if d, ok := resolveEventData(event.Data).(*StartupLocalityEventData); ok && event.PCRIndex == 0 && !pcr0Extended {
+				for _, digest := range pcrValues(0) {
+					digest[len(digest)-1] = d.StartupLocality
+				}
+			}
+		} else {
+			if event.PCRIndex == 0 {
+				pcr0Extended = true
+			}
+			values := pcrValues(event.PCRIndex)
+			for _, alg := range algs {
+				digest, ok := event.Digests[alg]
+				if !ok {
+					return nil, fmt.Errorf("event %d has no digest for algorithm %v", i, alg)
+				}
+
+				h := alg.NewHash()
+				h.Write(values[alg])
+				h.Write(digest)
+				values[alg] = h.Sum(nil)
+			}
+		}
+
+		if fn != nil && !fn(pcrs) {
+			break
+		}
+	}
+
+	return pcrs, nil
+}
+
+// PCRStatesByEvent replays the events in this log for the specified digest algorithm
+// and returns the PCR values immediately after each event has been processed. The
+// returned slice contains an entry for each event in the log, at the same index as
+// the corresponding event. Each entry contains a value for every PCR that has been
+// extended by this event or any of the preceding events, so the value of the PCR that
+// an event is measured to after that event has been extended is
+// states[i][l.Events[i].PCRIndex]. The values are computed in the same way as
+// ReplayPCRs, and the last entry corresponds to the values returned from it.
+//
+// An error is returned if the algorithm is not available or the log doesn't contain
+// digests for it.
+func (l *Log) PCRStatesByEvent(alg tpm2.HashAlgorithmId) ([]map[PCRIndex]Digest, error) {
+	if !l.Algorithms.Contains(alg) {
+		return nil, fmt.Errorf("the log does not contain digests for algorithm %v", alg)
+	}
+
+	var states []map[PCRIndex]Digest
+	if _, err := l.replay(AlgorithmIdList{alg}, func(pcrs map[PCRIndex]DigestMap) bool {
+		state := make(map[PCRIndex]Digest)
+		for pcr, values := range pcrs {
+			state[pcr] = append(Digest(nil), values[alg]...)
+		}
+		states = append(states, state)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// FindFirstDivergence replays the events in this log for the specified digest
+// algorithm and compares the PCR values after each event with the supplied expected
+// values, stopping at the first event where they differ. The expected values are in
+// the same form as those returned from PCRStatesByEvent, with each entry corresponding
+// to the event at the same index. Only the PCRs that are present in each entry are
+// compared, and PCRs that haven't been extended yet are compared against their initial
+// value. If expected contains fewer entries than there are events, only the events
+// that have a corresponding entry are compared.
+//
+// On divergence, the event and its index are returned. If there is no divergence,
+// this returns a nil event and -1.
+//
+// An error is returned if the algorithm is not available or the log doesn't contain
+// digests for it, or if expected contains more entries than there are events in the
+// log.
+func (l *Log) FindFirstDivergence(expected []map[PCRIndex]Digest, alg tpm2.HashAlgorithmId) (*Event, int, error) {
+	if !l.Algorithms.Contains(alg) {
+		return nil, -1, fmt.Errorf("the log does not contain digests for algorithm %v", alg)
+	}
+	if len(expected) > len(l.Events) {
+		return nil, -1, fmt.Errorf("expected values supplied for %d events, but the log only contains %d events", len(expected), len(l.Events))
+	}
+
+	i := 0
+	divergence := -1
+	if _, err := l.replay(AlgorithmIdList{alg}, func(pcrs map[PCRIndex]DigestMap) bool {
+		if i >= len(expected) {
+			return false
+		}
+		for pcr, digest := range expected[i] {
+			value, ok := pcrs[pcr][alg]
+			if !ok {
+				value = make(Digest, alg.Size())
+			}
+			if !bytes.Equal(value, digest) {
+				divergence = i
+				return false
+			}
+		}
+		i++
+		return true
+	}); err != nil {
+		return nil, -1, err
+	}
+
+	if divergence < 0 {
+		return nil, -1, nil
+	}
+	return l.Events[divergence], divergence, nil
+}
+
+// BootAggregate computes a boot aggregate for the specified algorithm, which is the
+// digest of the concatenation of the values of the specified PCRs after replaying
+// this log, in the order in which they are supplied. This is the same as the
+// boot_aggregate value recorded as the first entry in the IMA measurement list, which
+// is computed from PCRs 0-7 (and also PCRs 8 and 9 for newer kernels). PCRs that
+// aren't extended by any events in the log are included with their initial value.
+//
+// An error is returned if the algorithm is not available or the log doesn't contain
+// digests for it.
+func (l *Log) BootAggregate(alg tpm2.HashAlgorithmId, pcrs []PCRIndex) (Digest, error) {
+	if !l.Algorithms.Contains(alg) {
+		return nil, fmt.Errorf("the log does not contain digests for algorithm %v", alg)
+	}
+
+	values, err := l.ReplayPCRs(AlgorithmIdList{alg})
+	if err != nil {
+		return nil, err
+	}
+
+	h := alg.NewHash()
+	for _, pcr := range pcrs {
+		value, ok := values[pcr][alg]
+		if !ok {
+			value = make(Digest, alg.Size())
+		}
+		h.Write(value)
+	}
+	return h.Sum(nil), nil
+}
+
+// VerifyQuoteDigest indicates whether the supplied PCR digest from a TPM2 quote
+// matches the values of the specified PCRs obtained by replaying this log. The
+// quote is assumed to select the specified PCRs from the bank associated with the
+// specified algorithm, and to have a PCR digest computed with the same algorithm.
+// As with the TPM, the digest is computed from the values of the selected PCRs in
+// ascending order of PCR index, regardless of the order in which they are
+// supplied. PCRs that aren't extended by any events in the log are included with
+// their initial value.
+//
+// An error is returned if any of the PCR indices are out of range, or if the
+// algorithm is not available or the log doesn't contain digests for it. An error
+// that wraps ErrDigestSizeMismatch is returned if the size of the supplied digest
+// doesn't match the size of the algorithm.
+func (l *Log) VerifyQuoteDigest(pcrs []PCRIndex, alg tpm2.HashAlgorithmId, quoteDigest Digest) (bool, error) {
+	selection := make([]PCRIndex, 0, len(pcrs))
+	seen := make(map[PCRIndex]bool)
+	for _, pcr := range pcrs {
+		if !isPCRIndexInRange(pcr) {
+			return false, fmt.Errorf("invalid PCR index %d", pcr)
+		}
+		if seen[pcr] {
+			continue
+		}
+		seen[pcr] = true
+		selection = append(selection, pcr)
+	}
+	sort.Slice(selection, func(i, j int) bool { return selection[i] < selection[j] })
+
+	digest, err := l.BootAggregate(alg, selection)
+	if err != nil {
+		return false, err
+	}
+	return digest.EqualChecked(quoteDigest)
+}
@@ -0,0 +1,357 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"os"
+
+	"github.com/canonical/go-tpm2"
+
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type replaySuite struct{}
+
+var _ = Suite(&replaySuite{})
+
+func (s *replaySuite) TestReplayPCRs(c *C) {
+	f, err := os.Open("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	log, err := ReadLog(f, &LogOptions{})
+	c.Assert(err, IsNil)
+
+	pcrs, err := log.ReplayPCRs(log.Algorithms)
+	c.Assert(err, IsNil)
+	c.Check(pcrs, HasLen, 11)
+	c.Check(pcrs[0], DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   decodeHexString(c, "af23a848ed28986716e9b2d7d74a78e4f3b04aeb"),
+		tpm2.HashAlgorithmSHA256: decodeHexString(c, "65f5dd3770c3c3447fc3b6f48f84e0648b42be3ce04499fb75d63c5159b9c5f3")})
+	c.Check(pcrs[4], DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   decodeHexString(c, "8b1fa7d3cdffbc2747cc7a39dcc87e8d49fccda3"),
+		tpm2.HashAlgorithmSHA256: decodeHexString(c, "e2e35cacd92e74e7fc77bd8164e0aed5e22fd0ddea905e33b1880e5273199a49")})
+	c.Check(pcrs[7], DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   decodeHexString(c, "b4656dfec18ab53976cb06cee03582f69a99a74b"),
+		tpm2.HashAlgorithmSHA256: decodeHexString(c, "41977a9f2eac0dd9d8aec1c3c677ff9a717d69d147bcc923da779f7417c65e69")})
+	c.Check(pcrs[14], DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA1:   decodeHexString(c, "70c2638e9d2aca1958c63f416fee7c43569aa467"),
+		tpm2.HashAlgorithmSHA256: decodeHexString(c, "ef37874426a7ea14e54c23100b9ab51c036093bb24dd6ec4c331b856b96dda8e")})
+
+	_, exists := pcrs[10]
+	c.Check(exists, Equals, false)
+}
+
+func (s *replaySuite) TestReplayPCRsSingleBank(c *C) {
+	f, err := os.Open("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	log, err := ReadLog(f, &LogOptions{})
+	c.Assert(err, IsNil)
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+	c.Check(pcrs[7], DeepEquals, DigestMap{
+		tpm2.HashAlgorithmSHA256: decodeHexString(c, "41977a9f2eac0dd9d8aec1c3c677ff9a717d69d147bcc923da779f7417c65e69")})
+}
+
+func (s *replaySuite) TestReplayPCRsStartupLocality(c *C) {
+	log := NewLogForTesting([]*Event{
+		{
+			PCRIndex:  0,
+			EventType: EventTypeNoAction,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA256: make(Digest, 32)},
+			Data:      &StartupLocalityEventData{StartupLocality: 3}},
+		{
+			PCRIndex:  0,
+			EventType: EventTypeSeparator,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA256: ComputeSeparatorEventDigest(crypto.SHA256, SeparatorEventNormalValue)},
+			Data:      &SeparatorEventData{Value: SeparatorEventNormalValue}}})
+
+	initial := make(Digest, 32)
+	initial[31] = 3
+	h := crypto.SHA256.New()
+	h.Write(initial)
+	h.Write(ComputeSeparatorEventDigest(crypto.SHA256, SeparatorEventNormalValue))
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+	c.Check(pcrs, DeepEquals, map[PCRIndex]DigestMap{0: {tpm2.HashAlgorithmSHA256: h.Sum(nil)}})
+}
+
+func (s *replaySuite) TestReplayPCRsStartupLocalityAfterExtend(c *C) {
+	log := NewLogForTesting([]*Event{
+		{
+			PCRIndex:  0,
+			EventType: EventTypeSeparator,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA256: ComputeSeparatorEventDigest(crypto.SHA256, SeparatorEventNormalValue)},
+			Data:      &SeparatorEventData{Value: SeparatorEventNormalValue}},
+		{
+			PCRIndex:  0,
+			EventType: EventTypeNoAction,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA256: make(Digest, 32)},
+			Data:      &StartupLocalityEventData{StartupLocality: 3}}})
+
+	h := crypto.SHA256.New()
+	h.Write(make(Digest, 32))
+	h.Write(ComputeSeparatorEventDigest(crypto.SHA256, SeparatorEventNormalValue))
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+	c.Check(pcrs, DeepEquals, map[PCRIndex]DigestMap{0: {tpm2.HashAlgorithmSHA256: h.Sum(nil)}})
+}
+
+func (s *replaySuite) TestReplayPCRsMissingDigest(c *C) {
+	log := NewLogForTesting([]*Event{
+		{
+			PCRIndex:  7,
+			EventType: EventTypeSeparator,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: ComputeSeparatorEventDigest(crypto.SHA1, SeparatorEventNormalValue)},
+			Data:      &SeparatorEventData{Value: SeparatorEventNormalValue}}})
+
+	_, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Check(err, ErrorMatches, "event 0 has no digest for algorithm TPM_ALG_SHA256")
+}
+
+func (s *replaySuite) TestBootAggregate(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+
+	h := crypto.SHA256.New()
+	for i := PCRIndex(0); i < 8; i++ {
+		h.Write(pcrs[i][tpm2.HashAlgorithmSHA256])
+	}
+
+	aggregate, err := log.BootAggregate(tpm2.HashAlgorithmSHA256, []PCRIndex{0, 1, 2, 3, 4, 5, 6, 7})
+	c.Check(err, IsNil)
+	c.Check(aggregate, DeepEquals, Digest(h.Sum(nil)))
+}
+
+func (s *replaySuite) TestBootAggregateUnextendedPCR(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA1})
+	c.Assert(err, IsNil)
+
+	h := crypto.SHA1.New()
+	h.Write(pcrs[9][tpm2.HashAlgorithmSHA1])
+	h.Write(make([]byte, crypto.SHA1.Size()))
+
+	aggregate, err := log.BootAggregate(tpm2.HashAlgorithmSHA1, []PCRIndex{9, 10})
+	c.Check(err, IsNil)
+	c.Check(aggregate, DeepEquals, Digest(h.Sum(nil)))
+}
+
+func (s *replaySuite) TestBootAggregateMissingAlgorithm(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.BootAggregate(tpm2.HashAlgorithmSHA384, []PCRIndex{0})
+	c.Check(err, ErrorMatches, `the log does not contain digests for algorithm TPM_ALG_SHA384`)
+}
+
+func (s *replaySuite) TestPCRStatesByEvent(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	states, err := log.PCRStatesByEvent(tpm2.HashAlgorithmSHA256)
+	c.Assert(err, IsNil)
+	c.Assert(states, HasLen, len(log.Events))
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+
+	last := states[len(states)-1]
+	c.Check(last, HasLen, len(pcrs))
+	for pcr, values := range pcrs {
+		c.Check(last[pcr], DeepEquals, values[tpm2.HashAlgorithmSHA256], Commentf("PCR %d", pcr))
+	}
+
+	for i, event := range log.Events {
+		if event.EventType == EventTypeNoAction {
+			if i > 0 {
+				c.Check(states[i], DeepEquals, states[i-1], Commentf("event %d", i))
+			}
+			continue
+		}
+
+		prev := make(Digest, crypto.SHA256.Size())
+		if i > 0 {
+			if value, ok := states[i-1][event.PCRIndex]; ok {
+				prev = value
+			}
+		}
+		h := crypto.SHA256.New()
+		h.Write(prev)
+		h.Write(event.Digests[tpm2.HashAlgorithmSHA256])
+		c.Check(states[i][event.PCRIndex], DeepEquals, Digest(h.Sum(nil)), Commentf("event %d", i))
+	}
+}
+
+func (s *replaySuite) TestPCRStatesByEventMissingAlgorithm(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.PCRStatesByEvent(tpm2.HashAlgorithmSHA384)
+	c.Check(err, ErrorMatches, `the log does not contain digests for algorithm TPM_ALG_SHA384`)
+}
+
+func (s *replaySuite) TestVerifyQuoteDigest(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	pcrs, err := log.ReplayPCRs(AlgorithmIdList{tpm2.HashAlgorithmSHA256})
+	c.Assert(err, IsNil)
+
+	h := crypto.SHA256.New()
+	for _, pcr := range []PCRIndex{0, 4, 7} {
+		h.Write(pcrs[pcr][tpm2.HashAlgorithmSHA256])
+	}
+	quoteDigest := Digest(h.Sum(nil))
+
+	ok, err := log.VerifyQuoteDigest([]PCRIndex{0, 4, 7}, tpm2.HashAlgorithmSHA256, quoteDigest)
+	c.Check(err, IsNil)
+	c.Check(ok, Equals, true)
+
+	ok, err = log.VerifyQuoteDigest([]PCRIndex{7, 0, 4, 7}, tpm2.HashAlgorithmSHA256, quoteDigest)
+	c.Check(err, IsNil)
+	c.Check(ok, Equals, true)
+
+	ok, err = log.VerifyQuoteDigest([]PCRIndex{0, 4}, tpm2.HashAlgorithmSHA256, quoteDigest)
+	c.Check(err, IsNil)
+	c.Check(ok, Equals, false)
+}
+
+func (s *replaySuite) TestVerifyQuoteDigestInvalidPCR(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.VerifyQuoteDigest([]PCRIndex{0, 32}, tpm2.HashAlgorithmSHA256, nil)
+	c.Check(err, ErrorMatches, `invalid PCR index 32`)
+}
+
+func (s *replaySuite) TestVerifyQuoteDigestMissingAlgorithm(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.VerifyQuoteDigest([]PCRIndex{0}, tpm2.HashAlgorithmSHA384, nil)
+	c.Check(err, ErrorMatches, `the log does not contain digests for algorithm TPM_ALG_SHA384`)
+}
+
+func (s *replaySuite) TestVerifyQuoteDigestSizeMismatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, err := log.VerifyQuoteDigest([]PCRIndex{0}, tpm2.HashAlgorithmSHA256, make(Digest, 20))
+	c.Check(err, ErrorMatches, `cannot compare 32 byte digest with 20 byte digest: digest sizes don't match`)
+	c.Check(xerrors.Is(err, ErrDigestSizeMismatch), Equals, true)
+}
+
+func (s *replaySuite) TestFindFirstDivergenceNone(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	expected, err := log.PCRStatesByEvent(tpm2.HashAlgorithmSHA256)
+	c.Assert(err, IsNil)
+
+	event, index, err := log.FindFirstDivergence(expected, tpm2.HashAlgorithmSHA256)
+	c.Check(err, IsNil)
+	c.Check(event, IsNil)
+	c.Check(index, Equals, -1)
+}
+
+func (s *replaySuite) TestFindFirstDivergence(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	expected, err := log.PCRStatesByEvent(tpm2.HashAlgorithmSHA256)
+	c.Assert(err, IsNil)
+
+	// Modify the measurement of the PCR 7 separator, which affects the expected value
+	// of PCR 7 for every subsequent event.
+	c.Assert(log.Events[9].PCRIndex, Equals, PCRIndex(7))
+	log.Events[9].Digests[tpm2.HashAlgorithmSHA256] = make(Digest, tpm2.HashAlgorithmSHA256.Size())
+
+	event, index, err := log.FindFirstDivergence(expected, tpm2.HashAlgorithmSHA256)
+	c.Check(err, IsNil)
+	c.Check(event, Equals, log.Events[9])
+	c.Check(index, Equals, 9)
+}
+
+func (s *replaySuite) TestFindFirstDivergencePartial(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	states, err := log.PCRStatesByEvent(tpm2.HashAlgorithmSHA256)
+	c.Assert(err, IsNil)
+
+	// Only supply golden values for PCR 4 for the first 20 events.
+	var expected []map[PCRIndex]Digest
+	for _, state := range states[:20] {
+		e := make(map[PCRIndex]Digest)
+		if value, ok := state[4]; ok {
+			e[4] = value
+		}
+		expected = append(expected, e)
+	}
+
+	// A modified event in PCR 7 is not detected.
+	log.Events[9].Digests[tpm2.HashAlgorithmSHA256] = make(Digest, tpm2.HashAlgorithmSHA256.Size())
+	event, index, err := log.FindFirstDivergence(expected, tpm2.HashAlgorithmSHA256)
+	c.Check(err, IsNil)
+	c.Check(event, IsNil)
+	c.Check(index, Equals, -1)
+
+	// A PCR that hasn't been extended yet is compared against its initial value.
+	expected[0][4] = decodeHexString(c, "0000000000000000000000000000000000000000000000000000000000000001")
+	event, index, err = log.FindFirstDivergence(expected, tpm2.HashAlgorithmSHA256)
+	c.Check(err, IsNil)
+	c.Check(event, Equals, log.Events[0])
+	c.Check(index, Equals, 0)
+}
+
+func (s *replaySuite) TestFindFirstDivergenceTooManyEntries(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, _, err := log.FindFirstDivergence(make([]map[PCRIndex]Digest, len(log.Events)+1), tpm2.HashAlgorithmSHA256)
+	c.Check(err, ErrorMatches, `expected values supplied for 116 events, but the log only contains 115 events`)
+}
+
+func (s *replaySuite) TestFindFirstDivergenceMissingAlgorithm(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	_, _, err := log.FindFirstDivergence(nil, tpm2.HashAlgorithmSHA384)
+	c.Check(err, ErrorMatches, `the log does not contain digests for algorithm TPM_ALG_SHA384`)
+}
+
+func (s *replaySuite) TestReplayPCRsStartupLocalityLazy(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	locality := &Event{
+		PCRIndex:  0,
+		EventType: EventTypeNoAction,
+		Digests: DigestMap{
+			tpm2.HashAlgorithmSHA1:   make(Digest, tpm2.HashAlgorithmSHA1.Size()),
+			tpm2.HashAlgorithmSHA256: make(Digest, tpm2.HashAlgorithmSHA256.Size())},
+		Data: &StartupLocalityEventData{StartupLocality: 3}}
+	log.Events = append([]*Event{log.Events[0], locality}, log.Events[1:]...)
+
+	w := new(bytes.Buffer)
+	c.Assert(log.Write(w), IsNil)
+
+	eager, err := ReadLog(bytes.NewReader(w.Bytes()), &LogOptions{})
+	c.Assert(err, IsNil)
+	lazy, err := ReadLog(bytes.NewReader(w.Bytes()), &LogOptions{LazyData: true})
+	c.Assert(err, IsNil)
+	c.Assert(lazy.Events[1].Data, FitsTypeOf, (*LazyEventData)(nil))
+
+	expected, err := eager.ReplayPCRs(eager.Algorithms)
+	c.Assert(err, IsNil)
+	pcrs, err := lazy.ReplayPCRs(lazy.Algorithms)
+	c.Check(err, IsNil)
+	c.Check(pcrs, DeepEquals, expected)
+
+	initial := make(Digest, 32)
+	initial[31] = 3
+	h := crypto.SHA256.New()
+	h.Write(initial)
+	h.Write(lazy.Events[2].Digests[tpm2.HashAlgorithmSHA256])
+	states, err := lazy.PCRStatesByEvent(tpm2.HashAlgorithmSHA256)
+	c.Assert(err, IsNil)
+	c.Check(states[2][0], DeepEquals, Digest(h.Sum(nil)))
+}
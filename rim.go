@@ -0,0 +1,177 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/canonical/go-tpm2"
+)
+
+// ReferenceMeasurement describes a measurement that is expected to appear in a log.
+type ReferenceMeasurement struct {
+	PCRIndex  PCRIndex  // The PCR that the measurement is expected in
+	EventType EventType // The expected event type
+	Digests   DigestMap // The expected digests, which may contain a subset of the banks in the log
+}
+
+// ReferenceManifest is a simple reference integrity manifest, which consists of an
+// ordered list of the measurements that are expected to appear in a log. For each PCR
+// that appears in the manifest, the measurements must appear in the log in the same order.
+// PCRs that don't appear in the manifest are not verified.
+type ReferenceManifest struct {
+	Measurements []ReferenceMeasurement
+}
+
+// ManifestDeviationType describes the type of a ManifestDeviation.
+type ManifestDeviationType int
+
+const (
+	// ManifestDeviationDigestMismatch indicates that an event has a digest that
+	// doesn't match the reference measurement.
+	ManifestDeviationDigestMismatch ManifestDeviationType = iota
+
+	// ManifestDeviationEventTypeMismatch indicates that an event has a type that
+	// doesn't match the reference measurement.
+	ManifestDeviationEventTypeMismatch
+
+	// ManifestDeviationUnexpectedEvent indicates that an event in the log has no
+	// corresponding reference measurement.
+	ManifestDeviationUnexpectedEvent
+
+	// ManifestDeviationMissingEvent indicates that a reference measurement has no
+	// corresponding event in the log.
+	ManifestDeviationMissingEvent
+)
+
+// ManifestDeviation describes a difference between a log and a reference manifest.
+type ManifestDeviation struct {
+	Type             ManifestDeviationType
+	PCRIndex         PCRIndex
+	EventIndex       int                  // The index of the event in the log, or -1 for ManifestDeviationMissingEvent
+	MeasurementIndex int                  // The index of the reference measurement, or -1 for ManifestDeviationUnexpectedEvent
+	Algorithm        tpm2.HashAlgorithmId // The digest algorithm for ManifestDeviationDigestMismatch
+}
+
+func (d ManifestDeviation) String() string {
+	switch d.Type {
+	case ManifestDeviationDigestMismatch:
+		return fmt.Sprintf("event %d in PCR %d has a %v digest that doesn't match reference measurement %d", d.EventIndex, d.PCRIndex, d.Algorithm, d.MeasurementIndex)
+	case ManifestDeviationEventTypeMismatch:
+		return fmt.Sprintf("event %d in PCR %d has a type that doesn't match reference measurement %d", d.EventIndex, d.PCRIndex, d.MeasurementIndex)
+	case ManifestDeviationUnexpectedEvent:
+		return fmt.Sprintf("event %d in PCR %d has no reference measurement", d.EventIndex, d.PCRIndex)
+	case ManifestDeviationMissingEvent:
+		return fmt.Sprintf("reference measurement %d in PCR %d has no corresponding event", d.MeasurementIndex, d.PCRIndex)
+	default:
+		return fmt.Sprintf("unknown deviation type %d", d.Type)
+	}
+}
+
+// VerifyAgainstRIM compares the events in this log with the supplied reference manifest,
+// and returns a list of deviations ordered by PCR. EV_NO_ACTION events are ignored because
+// they aren't measured. An error is returned if the manifest is invalid, or if it contains
+// digests for algorithms that don't appear in this log. An error that wraps
+// ErrDigestSizeMismatch is returned if the manifest contains a digest with a size that
+// doesn't match its algorithm.
+func (l *Log) VerifyAgainstRIM(rim *ReferenceManifest) ([]ManifestDeviation, error) {
+	if rim == nil {
+		return nil, errors.New("no manifest")
+	}
+
+	expected := make(map[PCRIndex][]int)
+	for i, m := range rim.Measurements {
+		if len(m.Digests) == 0 {
+			return nil, fmt.Errorf("reference measurement %d has no digests", i)
+		}
+		for alg := range m.Digests {
+			if !l.Algorithms.Contains(alg) {
+				return nil, fmt.Errorf("reference measurement %d has a digest for algorithm %v which is not present in the log", i, alg)
+			}
+			if alg.IsValid() && len(m.Digests[alg]) != alg.Size() {
+				return nil, fmt.Errorf("reference measurement %d has a digest for algorithm %v with the wrong size (%d bytes): %w", i, alg, len(m.Digests[alg]), ErrDigestSizeMismatch)
+			}
+		}
+		expected[m.PCRIndex] = append(expected[m.PCRIndex], i)
+	}
+
+	actual := make(map[PCRIndex][]int)
+	for i, event := range l.Events {
+		if event.EventType == EventTypeNoAction {
+			continue
+		}
+		if _, ok := expected[event.PCRIndex]; !ok {
+			continue
+		}
+		actual[event.PCRIndex] = append(actual[event.PCRIndex], i)
+	}
+
+	var pcrs []PCRIndex
+	for pcr := range expected {
+		pcrs = append(pcrs, pcr)
+	}
+	sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+
+	var deviations []ManifestDeviation
+	for _, pcr := range pcrs {
+		events := actual[pcr]
+		measurements := expected[pcr]
+
+		for i, mi := range measurements {
+			if i >= len(events) {
+				deviations = append(deviations, ManifestDeviation{
+					Type:             ManifestDeviationMissingEvent,
+					PCRIndex:         pcr,
+					EventIndex:       -1,
+					MeasurementIndex: mi})
+				continue
+			}
+
+			event := l.Events[events[i]]
+			m := &rim.Measurements[mi]
+
+			if event.EventType != m.EventType {
+				deviations = append(deviations, ManifestDeviation{
+					Type:             ManifestDeviationEventTypeMismatch,
+					PCRIndex:         pcr,
+					EventIndex:       events[i],
+					MeasurementIndex: mi})
+			}
+
+			var algs AlgorithmIdList
+			for alg := range m.Digests {
+				algs = append(algs, alg)
+			}
+			sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+
+			for _, alg := range algs {
+				if event.Digests[alg].Equal(m.Digests[alg]) {
+					continue
+				}
+				deviations = append(deviations, ManifestDeviation{
+					Type:             ManifestDeviationDigestMismatch,
+					PCRIndex:         pcr,
+					EventIndex:       events[i],
+					MeasurementIndex: mi,
+					Algorithm:        alg})
+			}
+		}
+
+		if len(events) <= len(measurements) {
+			continue
+		}
+		for _, ei := range events[len(measurements):] {
+			deviations = append(deviations, ManifestDeviation{
+				Type:             ManifestDeviationUnexpectedEvent,
+				PCRIndex:         pcr,
+				EventIndex:       ei,
+				MeasurementIndex: -1})
+		}
+	}
+
+	return deviations, nil
+}
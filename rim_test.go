@@ -0,0 +1,155 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"github.com/canonical/go-tpm2"
+
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type rimSuite struct{}
+
+var _ = Suite(&rimSuite{})
+
+// makeManifest creates a reference manifest from the events in the supplied
+// log that are measured to the specified PCRs.
+func (s *rimSuite) makeManifest(log *Log, pcrs ...PCRIndex) *ReferenceManifest {
+	rim := new(ReferenceManifest)
+	for _, event := range log.Events {
+		if event.EventType == EventTypeNoAction {
+			continue
+		}
+		for _, pcr := range pcrs {
+			if event.PCRIndex != pcr {
+				continue
+			}
+			digests := make(DigestMap)
+			for alg, digest := range event.Digests {
+				digests[alg] = append(Digest(nil), digest...)
+			}
+			rim.Measurements = append(rim.Measurements, ReferenceMeasurement{
+				PCRIndex:  event.PCRIndex,
+				EventType: event.EventType,
+				Digests:   digests})
+		}
+	}
+	return rim
+}
+
+// eventIndex returns the index of the n'th event measured to the specified PCR.
+func (s *rimSuite) eventIndex(c *C, log *Log, pcr PCRIndex, n int) int {
+	for i, event := range log.Events {
+		if event.PCRIndex != pcr || event.EventType == EventTypeNoAction {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	c.Fatalf("no event")
+	return -1
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMGood(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	deviations, err := log.VerifyAgainstRIM(s.makeManifest(log, 0, 1, 2, 3, 4, 5, 6, 7))
+	c.Check(err, IsNil)
+	c.Check(deviations, HasLen, 0)
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMDigestMismatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	rim := s.makeManifest(log, 4)
+	rim.Measurements[2].Digests[tpm2.HashAlgorithmSHA256][0] ^= 0xff
+
+	deviations, err := log.VerifyAgainstRIM(rim)
+	c.Check(err, IsNil)
+	c.Check(deviations, DeepEquals, []ManifestDeviation{
+		{
+			Type:             ManifestDeviationDigestMismatch,
+			PCRIndex:         4,
+			EventIndex:       s.eventIndex(c, log, 4, 2),
+			MeasurementIndex: 2,
+			Algorithm:        tpm2.HashAlgorithmSHA256}})
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMEventTypeMismatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	rim := s.makeManifest(log, 7)
+	rim.Measurements[0].EventType = EventTypeAction
+
+	deviations, err := log.VerifyAgainstRIM(rim)
+	c.Check(err, IsNil)
+	c.Check(deviations, DeepEquals, []ManifestDeviation{
+		{
+			Type:             ManifestDeviationEventTypeMismatch,
+			PCRIndex:         7,
+			EventIndex:       s.eventIndex(c, log, 7, 0),
+			MeasurementIndex: 0}})
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMUnexpectedEvent(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	rim := s.makeManifest(log, 7)
+	n := len(rim.Measurements)
+	rim.Measurements = rim.Measurements[:n-1]
+
+	deviations, err := log.VerifyAgainstRIM(rim)
+	c.Check(err, IsNil)
+	c.Check(deviations, DeepEquals, []ManifestDeviation{
+		{
+			Type:             ManifestDeviationUnexpectedEvent,
+			PCRIndex:         7,
+			EventIndex:       s.eventIndex(c, log, 7, n-1),
+			MeasurementIndex: -1}})
+	c.Check(deviations[0].String(), Matches, `event [[:digit:]]+ in PCR 7 has no reference measurement`)
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMMissingEvent(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	rim := s.makeManifest(log, 5)
+	rim.Measurements = append(rim.Measurements, ReferenceMeasurement{
+		PCRIndex:  5,
+		EventType: EventTypeEFIAction,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA256: make(Digest, 32)}})
+
+	deviations, err := log.VerifyAgainstRIM(rim)
+	c.Check(err, IsNil)
+	c.Check(deviations, DeepEquals, []ManifestDeviation{
+		{
+			Type:             ManifestDeviationMissingEvent,
+			PCRIndex:         5,
+			EventIndex:       -1,
+			MeasurementIndex: len(rim.Measurements) - 1}})
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMUnknownAlgorithm(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	rim := &ReferenceManifest{Measurements: []ReferenceMeasurement{{
+		PCRIndex:  0,
+		EventType: EventTypeSCRTMVersion,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA384: make(Digest, 48)}}}}
+
+	_, err := log.VerifyAgainstRIM(rim)
+	c.Check(err, ErrorMatches, `reference measurement 0 has a digest for algorithm TPM_ALG_SHA384 which is not present in the log`)
+}
+
+func (s *rimSuite) TestVerifyAgainstRIMDigestSizeMismatch(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	rim := &ReferenceManifest{Measurements: []ReferenceMeasurement{{
+		PCRIndex:  0,
+		EventType: EventTypeSCRTMVersion,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA256: make(Digest, 20)}}}}
+
+	_, err := log.VerifyAgainstRIM(rim)
+	c.Check(err, ErrorMatches, `reference measurement 0 has a digest for algorithm TPM_ALG_SHA256 with the wrong size \(20 bytes\): digest sizes don't match`)
+	c.Check(xerrors.Is(err, ErrDigestSizeMismatch), Equals, true)
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/go-efilib"
+)
+
+// shimLockGuid is the vendor GUID of the variables that shim measures.
+var shimLockGuid = efi.MakeGUID(0x605dab50, 0xe046, 0x4300, 0xabb6, [...]uint8{0x3d, 0xd8, 0x10, 0xdd, 0x8b, 0x23})
+
+// SbatRevocation is an entry in a SBAT level, which specifies the minimum generation
+// of a component that is permitted to execute.
+type SbatRevocation struct {
+	Component  string
+	Generation uint
+}
+
+// SbatLevel is the decoded form of shim's SbatLevel variable, which contains the SBAT
+// revocations that are enforced by shim.
+type SbatLevel struct {
+	Version     uint   // The version of the SBAT level format
+	Datestamp   string // The datestamp that identifies this SBAT level
+	Revocations []SbatRevocation
+}
+
+// ParseSbatLevel decodes the supplied SBAT level, which consists of a header line
+// with the format "sbat,<version>,<datestamp>" followed by a line with the format
+// "<component>,<generation>" for each revoked component generation.
+func ParseSbatLevel(data string) (*SbatLevel, error) {
+	data = strings.TrimRight(data, "\x00")
+
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("empty SBAT level")
+	}
+
+	hdr := strings.Split(lines[0], ",")
+	if len(hdr) < 3 || hdr[0] != "sbat" {
+		return nil, fmt.Errorf("invalid header %q", lines[0])
+	}
+	version, err := strconv.ParseUint(hdr[1], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q", hdr[1])
+	}
+
+	out := &SbatLevel{Version: uint(version), Datestamp: hdr[2]}
+	for i, line := range lines[1:] {
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid revocation %d (%q)", i, line)
+		}
+		generation, err := strconv.ParseUint(fields[1], 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid generation for component %q: %q", fields[0], fields[1])
+		}
+		out.Revocations = append(out.Revocations, SbatRevocation{Component: fields[0], Generation: uint(generation)})
+	}
+
+	return out, nil
+}
+
+// Generation returns the minimum generation of the specified component that is
+// permitted to execute by this SBAT level. This returns false if the component is
+// not revoked by this SBAT level.
+func (l *SbatLevel) Generation(component string) (uint, bool) {
+	for _, r := range l.Revocations {
+		if r.Component == component {
+			return r.Generation, true
+		}
+	}
+	return 0, false
+}
+
+// SbatLevel returns the SBAT level measured by shim to the EV_EFI_VARIABLE_AUTHORITY
+// event for its SbatLevel variable in PCR 7. This returns false if the log doesn't
+// contain this event. The returned value can be decoded with ParseSbatLevel.
+func (l *Log) SbatLevel() (string, bool) {
+	for _, event := range l.Events {
+		if event.PCRIndex != 7 || event.EventType != EventTypeEFIVariableAuthority {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok || data.VariableName != shimLockGuid || data.UnicodeName != "SbatLevel" {
+			continue
+		}
+		return string(bytes.TrimRight(data.VariableData, "\x00")), true
+	}
+	return "", false
+}
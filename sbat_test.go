@@ -0,0 +1,88 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type sbatSuite struct{}
+
+var _ = Suite(&sbatSuite{})
+
+func (s *sbatSuite) TestLogSbatLevel(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	level, ok := log.SbatLevel()
+	c.Check(ok, Equals, true)
+	c.Check(level, Equals, "sbat,1,2021030218\n")
+}
+
+func (s *sbatSuite) TestLogSbatLevelMissing(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for i, event := range log.Events {
+		if data, ok := event.Data.(*EFIVariableData); ok && data.UnicodeName == "SbatLevel" {
+			log.Events = append(log.Events[:i], log.Events[i+1:]...)
+			break
+		}
+	}
+
+	_, ok := log.SbatLevel()
+	c.Check(ok, Equals, false)
+}
+
+func (s *sbatSuite) TestParseSbatLevelFromLog(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	data, ok := log.SbatLevel()
+	c.Assert(ok, Equals, true)
+
+	level, err := ParseSbatLevel(data)
+	c.Assert(err, IsNil)
+	c.Check(level, DeepEquals, &SbatLevel{Version: 1, Datestamp: "2021030218"})
+}
+
+func (s *sbatSuite) TestParseSbatLevelWithRevocations(c *C) {
+	level, err := ParseSbatLevel("sbat,1,2022111500\nshim,2\ngrub,3\n\x00")
+	c.Assert(err, IsNil)
+	c.Check(level, DeepEquals, &SbatLevel{
+		Version:   1,
+		Datestamp: "2022111500",
+		Revocations: []SbatRevocation{
+			{Component: "shim", Generation: 2},
+			{Component: "grub", Generation: 3}}})
+
+	generation, ok := level.Generation("grub")
+	c.Check(ok, Equals, true)
+	c.Check(generation, Equals, uint(3))
+
+	_, ok = level.Generation("grub.debian")
+	c.Check(ok, Equals, false)
+}
+
+func (s *sbatSuite) TestParseSbatLevelEmpty(c *C) {
+	_, err := ParseSbatLevel("\x00")
+	c.Check(err, ErrorMatches, `empty SBAT level`)
+}
+
+func (s *sbatSuite) TestParseSbatLevelInvalidHeader(c *C) {
+	_, err := ParseSbatLevel("shim,1\n")
+	c.Check(err, ErrorMatches, `invalid header "shim,1"`)
+}
+
+func (s *sbatSuite) TestParseSbatLevelInvalidVersion(c *C) {
+	_, err := ParseSbatLevel("sbat,a,2021030218\n")
+	c.Check(err, ErrorMatches, `invalid version "a"`)
+}
+
+func (s *sbatSuite) TestParseSbatLevelInvalidGeneration(c *C) {
+	_, err := ParseSbatLevel("sbat,1,2022111500\nshim,2\ngrub,x\n")
+	c.Check(err, ErrorMatches, `invalid generation for component "grub": "x"`)
+}
+
+func (s *sbatSuite) TestParseSbatLevelInvalidRevocation(c *C) {
+	_, err := ParseSbatLevel("sbat,1,2022111500\nshim\n")
+	c.Check(err, ErrorMatches, `invalid revocation 0 \("shim"\)`)
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SystemdEventTag corresponds to the taggedEventID of an EV_EVENT_TAG event measured by
+// systemd-boot or systemd's EFI stub linux loader (*_EVENT_TAG_ID from systemd's
+// tpm2-pcr.h).
+type SystemdEventTag uint32
+
+const (
+	SystemdLoaderConfEventTag      SystemdEventTag = 0xf5bc582a // LOADER_CONF_EVENT_TAG_ID, for loader.conf in PCR 5
+	SystemdLoadOptionsEventTag     SystemdEventTag = 0x8f3b22ed // LOAD_OPTIONS_EVENT_TAG_ID, for the kernel commandline in PCR 12
+	SystemdDevicetreeAddonEventTag SystemdEventTag = 0x6c46f751 // DEVICETREE_ADDON_EVENT_TAG_ID, for devicetree addons in PCR 12
+	SystemdInitrdAddonEventTag     SystemdEventTag = 0x49dffe0f // INITRD_ADDON_EVENT_TAG_ID, for initrd addons in PCR 12
+	SystemdUcodeAddonEventTag      SystemdEventTag = 0xdac08e1a // UCODE_ADDON_EVENT_TAG_ID, for microcode addons in PCR 12
+	SystemdUKIProfileEventTag      SystemdEventTag = 0x13aed6db // UKI_PROFILE_EVENT_TAG_ID, for the selected UKI profile in PCR 12
+)
+
+func (t SystemdEventTag) String() string {
+	switch t {
+	case SystemdLoaderConfEventTag:
+		return "loader-conf"
+	case SystemdLoadOptionsEventTag:
+		return "load-options"
+	case SystemdDevicetreeAddonEventTag:
+		return "devicetree-addon"
+	case SystemdInitrdAddonEventTag:
+		return "initrd-addon"
+	case SystemdUcodeAddonEventTag:
+		return "ucode-addon"
+	case SystemdUKIProfileEventTag:
+		return "uki-profile"
+	default:
+		return fmt.Sprintf("%#08x", uint32(t))
+	}
+}
+
+func (t SystemdEventTag) isKnown() bool {
+	switch t {
+	case SystemdLoaderConfEventTag, SystemdLoadOptionsEventTag, SystemdDevicetreeAddonEventTag,
+		SystemdInitrdAddonEventTag, SystemdUcodeAddonEventTag, SystemdUKIProfileEventTag:
+		return true
+	default:
+		return false
+	}
+}
+
+// SystemdBootEventData is the event data associated with an EV_EVENT_TAG event measured
+// by systemd-boot or systemd's EFI stub linux loader. The event data is a
+// TCG_PCClientTaggedEvent structure that contains a UTF-16 description of the measured
+// data, such as the path of a loader entry configuration or addon, or the kernel
+// commandline. The event digest is computed from the measured data rather than the
+// event data, so it can't be verified from the log alone.
+type SystemdBootEventData struct {
+	rawEventData
+	Tag         SystemdEventTag
+	Description string
+}
+
+func (e *SystemdBootEventData) String() string {
+	return fmt.Sprintf("systemd %s: %s", e.Tag, e.Description)
+}
+
+func (e *SystemdBootEventData) Write(w io.Writer) error {
+	description := append(convertStringToUtf16(e.Description), 0)
+	if len(description)*2 > math.MaxUint32 {
+		return errors.New("Description is too long")
+	}
+
+	hdr := struct {
+		EventID uint32
+		Size    uint32
+	}{EventID: uint32(e.Tag), Size: uint32(len(description) * 2)}
+	if err := binary.Write(w, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, description)
+}
+
+func (e *SystemdBootEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SystemdBootEventData)
+	return ok && e.Tag == o.Tag && e.Description == o.Description
+}
+
+func decodeEventDataSystemdBoot(data []byte, eventType EventType) *SystemdBootEventData {
+	if eventType != EventTypeEventTag {
+		return nil
+	}
+	if len(data) < 8 {
+		return nil
+	}
+
+	tag := SystemdEventTag(binary.LittleEndian.Uint32(data))
+	if !tag.isKnown() {
+		return nil
+	}
+
+	// The description is a NULL terminated UTF-16 string which occupies the rest of the
+	// event data.
+	description := data[8:]
+	if binary.LittleEndian.Uint32(data[4:]) != uint32(len(description)) || len(description)%2 != 0 {
+		return nil
+	}
+
+	utf16Str := make([]uint16, len(description)/2)
+	for i := range utf16Str {
+		utf16Str[i] = binary.LittleEndian.Uint16(description[i*2:])
+	}
+	for len(utf16Str) > 0 && utf16Str[len(utf16Str)-1] == 0 {
+		utf16Str = utf16Str[:len(utf16Str)-1]
+	}
+
+	return &SystemdBootEventData{rawEventData: data, Tag: tag, Description: convertUtf16ToString(utf16Str)}
+}
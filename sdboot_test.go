@@ -0,0 +1,67 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type sdbootSuite struct{}
+
+var _ = Suite(&sdbootSuite{})
+
+const sdbootLoaderConfHex = "2a58bcf5360000006c006f0061006400650072002f0065006e00740072006900650073002f007500620075006e00740075002e0063006f006e0066000000"
+
+func (s *sdbootSuite) TestDecodeEventDataSystemdBoot(c *C) {
+	data := decodeHexString(c, sdbootLoaderConfHex)
+
+	event := DecodeEventDataSystemdBoot(data, EventTypeEventTag)
+	c.Assert(event, NotNil)
+	c.Check(event.Bytes(), DeepEquals, data)
+	c.Check(event.Tag, Equals, SystemdLoaderConfEventTag)
+	c.Check(event.Description, Equals, "loader/entries/ubuntu.conf")
+	c.Check(event.String(), Equals, "systemd loader-conf: loader/entries/ubuntu.conf")
+}
+
+func (s *sdbootSuite) TestDecodeEventDataSystemdBootWrongType(c *C) {
+	c.Check(DecodeEventDataSystemdBoot(decodeHexString(c, sdbootLoaderConfHex), EventTypeIPL), IsNil)
+}
+
+func (s *sdbootSuite) TestDecodeEventDataSystemdBootUnknownTag(c *C) {
+	c.Check(DecodeEventDataSystemdBoot(decodeHexString(c, "2b58bcf50400000061000000"), EventTypeEventTag), IsNil)
+}
+
+func (s *sdbootSuite) TestDecodeEventDataSystemdBootInvalidSize(c *C) {
+	c.Check(DecodeEventDataSystemdBoot(decodeHexString(c, "2a58bcf50600000061000000"), EventTypeEventTag), IsNil)
+	c.Check(DecodeEventDataSystemdBoot(decodeHexString(c, "2a58bcf503000000610000"), EventTypeEventTag), IsNil)
+	c.Check(DecodeEventDataSystemdBoot(decodeHexString(c, "2a58bcf5"), EventTypeEventTag), IsNil)
+}
+
+func (s *sdbootSuite) TestSystemdBootEventDataWrite(c *C) {
+	event := SystemdBootEventData{Tag: SystemdLoaderConfEventTag, Description: "loader/entries/ubuntu.conf"}
+
+	w := new(bytes.Buffer)
+	c.Check(event.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, decodeHexString(c, sdbootLoaderConfHex))
+}
+
+func (s *sdbootSuite) TestSystemdBootEventDataEqual(c *C) {
+	event := DecodeEventDataSystemdBoot(decodeHexString(c, sdbootLoaderConfHex), EventTypeEventTag)
+	c.Assert(event, NotNil)
+
+	c.Check(event.Equal(&SystemdBootEventData{Tag: SystemdLoaderConfEventTag, Description: "loader/entries/ubuntu.conf"}), Equals, true)
+	c.Check(event.Equal(&SystemdBootEventData{Tag: SystemdLoadOptionsEventTag, Description: "loader/entries/ubuntu.conf"}), Equals, false)
+	c.Check(event.Equal(&SystemdBootEventData{Tag: SystemdLoaderConfEventTag, Description: "loader/entries/other.conf"}), Equals, false)
+}
+
+func (s *sdbootSuite) TestSystemdEventTagString(c *C) {
+	c.Check(SystemdLoadOptionsEventTag.String(), Equals, "load-options")
+	c.Check(SystemdInitrdAddonEventTag.String(), Equals, "initrd-addon")
+	c.Check(SystemdEventTag(0x1234).String(), Equals, "0x00001234")
+}
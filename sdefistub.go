@@ -30,6 +30,11 @@ func (e *SystemdEFIStubCommandline) Write(w io.Writer) error {
 	return err
 }
 
+func (e *SystemdEFIStubCommandline) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SystemdEFIStubCommandline)
+	return ok && e.Str == o.Str
+}
+
 // ComputeSystemdEFIStubCommandlineDigest computes the digest measured by the systemd EFI stub
 // linux loader for the specified kernel commandline. The commandline is supplied to the stub
 // via the LoadOptions as a UTF-16 or UCS-2 string and is measured as such before being converted
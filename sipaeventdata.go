@@ -0,0 +1,293 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/canonical/tcglog-parser/internal/ioerr"
+)
+
+// SIPAEventType corresponds to the type of a tagged measurement made by Windows
+// (SIPAEVENTTYPE_* and SIPAEVENT_* from wbcl.h).
+type SIPAEventType uint32
+
+const (
+	sipaEventTypeCategoryMask SIPAEventType = 0x00ff0000
+	sipaEventTypeContainer    SIPAEventType = 0x00010000
+
+	SIPAEventTrustBoundary                   SIPAEventType = 0x40010001 // SIPAEVENT_TRUSTBOUNDARY
+	SIPAEventELAMAggregation                 SIPAEventType = 0x40010002 // SIPAEVENT_ELAM_AGGREGATION
+	SIPAEventLoadedModuleAggregation         SIPAEventType = 0x40010003 // SIPAEVENT_LOADEDMODULE_AGGREGATION
+	SIPAEventTrustpointAggregation           SIPAEventType = 0xc0010004 // SIPAEVENT_TRUSTPOINT_AGGREGATION
+	SIPAEventKSRAggregation                  SIPAEventType = 0x40010005 // SIPAEVENT_KSR_AGGREGATION
+	SIPAEventKSRSignedMeasurementAggregation SIPAEventType = 0x40010006 // SIPAEVENT_KSR_SIGNED_MEASUREMENT_AGGREGATION
+	SIPAEventInformation                     SIPAEventType = 0x00020001 // SIPAEVENT_INFORMATION
+	SIPAEventBootCounter                     SIPAEventType = 0x00020002 // SIPAEVENT_BOOTCOUNTER
+	SIPAEventTransferControl                 SIPAEventType = 0x00020003 // SIPAEVENT_TRANSFER_CONTROL
+	SIPAEventApplicationReturn               SIPAEventType = 0x00020004 // SIPAEVENT_APPLICATION_RETURN
+	SIPAEventBitlockerUnlock                 SIPAEventType = 0x00020005 // SIPAEVENT_BITLOCKER_UNLOCK
+	SIPAEventEventCounter                    SIPAEventType = 0x00020006 // SIPAEVENT_EVENTCOUNTER
+	SIPAEventCounterID                       SIPAEventType = 0x00020007 // SIPAEVENT_COUNTERID
+	SIPAEventBootDebugging                   SIPAEventType = 0x00040001 // SIPAEVENT_BOOTDEBUGGING
+	SIPAEventBootRevocationList              SIPAEventType = 0x00040002 // SIPAEVENT_BOOT_REVOCATION_LIST
+	SIPAEventOSKernelDebug                   SIPAEventType = 0x00050001 // SIPAEVENT_OSKERNELDEBUG
+	SIPAEventCodeIntegrity                   SIPAEventType = 0x00050002 // SIPAEVENT_CODEINTEGRITY
+	SIPAEventTestSigning                     SIPAEventType = 0x00050003 // SIPAEVENT_TESTSIGNING
+	SIPAEventDataExecutionPrevention         SIPAEventType = 0x00050004 // SIPAEVENT_DATAEXECUTIONPREVENTION
+	SIPAEventSafeMode                        SIPAEventType = 0x00050005 // SIPAEVENT_SAFEMODE
+	SIPAEventWinPE                           SIPAEventType = 0x00050006 // SIPAEVENT_WINPE
+	SIPAEventPhysicalAddressExtension        SIPAEventType = 0x00050007 // SIPAEVENT_PHYSICALADDRESSEXTENSION
+	SIPAEventOSDevice                        SIPAEventType = 0x00050008 // SIPAEVENT_OSDEVICE
+	SIPAEventSystemRoot                      SIPAEventType = 0x00050009 // SIPAEVENT_SYSTEMROOT
+	SIPAEventHypervisorLaunchType            SIPAEventType = 0x0005000a // SIPAEVENT_HYPERVISOR_LAUNCH_TYPE
+	SIPAEventHypervisorPath                  SIPAEventType = 0x0005000b // SIPAEVENT_HYPERVISOR_PATH
+	SIPAEventHypervisorIOMMUPolicy           SIPAEventType = 0x0005000c // SIPAEVENT_HYPERVISOR_IOMMU_POLICY
+	SIPAEventHypervisorDebug                 SIPAEventType = 0x0005000d // SIPAEVENT_HYPERVISOR_DEBUG
+	SIPAEventDriverLoadPolicy                SIPAEventType = 0x0005000e // SIPAEVENT_DRIVER_LOAD_POLICY
+	SIPAEventSIPolicy                        SIPAEventType = 0x0005000f // SIPAEVENT_SI_POLICY
+	SIPAEventNoAuthority                     SIPAEventType = 0x00060001 // SIPAEVENT_NOAUTHORITY
+	SIPAEventAuthorityPubKey                 SIPAEventType = 0x00060002 // SIPAEVENT_AUTHORITYPUBKEY
+	SIPAEventFilePath                        SIPAEventType = 0x00070001 // SIPAEVENT_FILEPATH
+	SIPAEventImageSize                       SIPAEventType = 0x00070002 // SIPAEVENT_IMAGESIZE
+	SIPAEventHashAlgorithmID                 SIPAEventType = 0x00070003 // SIPAEVENT_HASHALGORITHMID
+	SIPAEventAuthenticodeHash                SIPAEventType = 0x00070004 // SIPAEVENT_AUTHENTICODEHASH
+	SIPAEventAuthorityIssuer                 SIPAEventType = 0x00070005 // SIPAEVENT_AUTHORITYISSUER
+	SIPAEventAuthoritySerial                 SIPAEventType = 0x00070006 // SIPAEVENT_AUTHORITYSERIAL
+	SIPAEventImageBase                       SIPAEventType = 0x00070007 // SIPAEVENT_IMAGEBASE
+	SIPAEventAuthorityPublisher              SIPAEventType = 0x00070008 // SIPAEVENT_AUTHORITYPUBLISHER
+	SIPAEventAuthoritySHA1Thumbprint         SIPAEventType = 0x00070009 // SIPAEVENT_AUTHORITYSHA1THUMBPRINT
+	SIPAEventImageValidated                  SIPAEventType = 0x0007000a // SIPAEVENT_IMAGEVALIDATED
+	SIPAEventModuleSVN                       SIPAEventType = 0x0007000b // SIPAEVENT_MODULE_SVN
+	SIPAEventELAMKeyname                     SIPAEventType = 0x00090001 // SIPAEVENT_ELAM_KEYNAME
+	SIPAEventELAMConfiguration               SIPAEventType = 0x00090002 // SIPAEVENT_ELAM_CONFIGURATION
+	SIPAEventELAMPolicy                      SIPAEventType = 0x00090003 // SIPAEVENT_ELAM_POLICY
+	SIPAEventELAMMeasured                    SIPAEventType = 0x00090004 // SIPAEVENT_ELAM_MEASURED
+)
+
+var sipaEventTypeNames = map[SIPAEventType]string{
+	SIPAEventTrustBoundary:                   "SIPAEVENT_TRUSTBOUNDARY",
+	SIPAEventELAMAggregation:                 "SIPAEVENT_ELAM_AGGREGATION",
+	SIPAEventLoadedModuleAggregation:         "SIPAEVENT_LOADEDMODULE_AGGREGATION",
+	SIPAEventTrustpointAggregation:           "SIPAEVENT_TRUSTPOINT_AGGREGATION",
+	SIPAEventKSRAggregation:                  "SIPAEVENT_KSR_AGGREGATION",
+	SIPAEventKSRSignedMeasurementAggregation: "SIPAEVENT_KSR_SIGNED_MEASUREMENT_AGGREGATION",
+	SIPAEventInformation:                     "SIPAEVENT_INFORMATION",
+	SIPAEventBootCounter:                     "SIPAEVENT_BOOTCOUNTER",
+	SIPAEventTransferControl:                 "SIPAEVENT_TRANSFER_CONTROL",
+	SIPAEventApplicationReturn:               "SIPAEVENT_APPLICATION_RETURN",
+	SIPAEventBitlockerUnlock:                 "SIPAEVENT_BITLOCKER_UNLOCK",
+	SIPAEventEventCounter:                    "SIPAEVENT_EVENTCOUNTER",
+	SIPAEventCounterID:                       "SIPAEVENT_COUNTERID",
+	SIPAEventBootDebugging:                   "SIPAEVENT_BOOTDEBUGGING",
+	SIPAEventBootRevocationList:              "SIPAEVENT_BOOT_REVOCATION_LIST",
+	SIPAEventOSKernelDebug:                   "SIPAEVENT_OSKERNELDEBUG",
+	SIPAEventCodeIntegrity:                   "SIPAEVENT_CODEINTEGRITY",
+	SIPAEventTestSigning:                     "SIPAEVENT_TESTSIGNING",
+	SIPAEventDataExecutionPrevention:         "SIPAEVENT_DATAEXECUTIONPREVENTION",
+	SIPAEventSafeMode:                        "SIPAEVENT_SAFEMODE",
+	SIPAEventWinPE:                           "SIPAEVENT_WINPE",
+	SIPAEventPhysicalAddressExtension:        "SIPAEVENT_PHYSICALADDRESSEXTENSION",
+	SIPAEventOSDevice:                        "SIPAEVENT_OSDEVICE",
+	SIPAEventSystemRoot:                      "SIPAEVENT_SYSTEMROOT",
+	SIPAEventHypervisorLaunchType:            "SIPAEVENT_HYPERVISOR_LAUNCH_TYPE",
+	SIPAEventHypervisorPath:                  "SIPAEVENT_HYPERVISOR_PATH",
+	SIPAEventHypervisorIOMMUPolicy:           "SIPAEVENT_HYPERVISOR_IOMMU_POLICY",
+	SIPAEventHypervisorDebug:                 "SIPAEVENT_HYPERVISOR_DEBUG",
+	SIPAEventDriverLoadPolicy:                "SIPAEVENT_DRIVER_LOAD_POLICY",
+	SIPAEventSIPolicy:                        "SIPAEVENT_SI_POLICY",
+	SIPAEventNoAuthority:                     "SIPAEVENT_NOAUTHORITY",
+	SIPAEventAuthorityPubKey:                 "SIPAEVENT_AUTHORITYPUBKEY",
+	SIPAEventFilePath:                        "SIPAEVENT_FILEPATH",
+	SIPAEventImageSize:                       "SIPAEVENT_IMAGESIZE",
+	SIPAEventHashAlgorithmID:                 "SIPAEVENT_HASHALGORITHMID",
+	SIPAEventAuthenticodeHash:                "SIPAEVENT_AUTHENTICODEHASH",
+	SIPAEventAuthorityIssuer:                 "SIPAEVENT_AUTHORITYISSUER",
+	SIPAEventAuthoritySerial:                 "SIPAEVENT_AUTHORITYSERIAL",
+	SIPAEventImageBase:                       "SIPAEVENT_IMAGEBASE",
+	SIPAEventAuthorityPublisher:              "SIPAEVENT_AUTHORITYPUBLISHER",
+	SIPAEventAuthoritySHA1Thumbprint:         "SIPAEVENT_AUTHORITYSHA1THUMBPRINT",
+	SIPAEventImageValidated:                  "SIPAEVENT_IMAGEVALIDATED",
+	SIPAEventModuleSVN:                       "SIPAEVENT_MODULE_SVN",
+	SIPAEventELAMKeyname:                     "SIPAEVENT_ELAM_KEYNAME",
+	SIPAEventELAMConfiguration:               "SIPAEVENT_ELAM_CONFIGURATION",
+	SIPAEventELAMPolicy:                      "SIPAEVENT_ELAM_POLICY",
+	SIPAEventELAMMeasured:                    "SIPAEVENT_ELAM_MEASURED",
+}
+
+func (t SIPAEventType) String() string {
+	if name, ok := sipaEventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("%08x", uint32(t))
+}
+
+// IsContainer indicates that events of this type contain a sequence of
+// other events rather than a value.
+func (t SIPAEventType) IsContainer() bool {
+	return t&sipaEventTypeCategoryMask == sipaEventTypeContainer
+}
+
+// SIPAEvent corresponds to a single tagged measurement made by Windows.
+type SIPAEvent struct {
+	Type     SIPAEventType
+	Data     []byte      // The value associated with this event, if it isn't a container
+	Children []SIPAEvent // The events contained within this event, if it is a container
+}
+
+func (e *SIPAEvent) String() string {
+	if !e.Type.IsContainer() {
+		return fmt.Sprintf("%s: %x", e.Type, e.Data)
+	}
+
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "%s[", e.Type)
+	for i, child := range e.Children {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		fmt.Fprintf(&builder, " %s", &child)
+	}
+	builder.WriteString(" ]")
+	return builder.String()
+}
+
+func (e *SIPAEvent) Write(w io.Writer) error {
+	data := e.Data
+	if e.Type.IsContainer() {
+		w2 := new(bytes.Buffer)
+		for _, child := range e.Children {
+			if err := child.Write(w2); err != nil {
+				return err
+			}
+		}
+		data = w2.Bytes()
+	}
+
+	if len(data) > math.MaxUint32 {
+		return errors.New("data too large")
+	}
+
+	hdr := struct {
+		Type SIPAEventType
+		Size uint32
+	}{Type: e.Type, Size: uint32(len(data))}
+	if err := binary.Write(w, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// Equal indicates whether this event is equal to other. Events are equal if they
+// have the same type and either the same value or equal children.
+func (e *SIPAEvent) Equal(other *SIPAEvent) bool {
+	if e.Type != other.Type {
+		return false
+	}
+	if !e.Type.IsContainer() {
+		return bytes.Equal(e.Data, other.Data)
+	}
+	return sipaEventsEqual(e.Children, other.Children)
+}
+
+func sipaEventsEqual(a, b []SIPAEvent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(&b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// SIPAEventData is the event data associated with a EV_EVENT_TAG event
+// measured by Windows, which consists of a sequence of tagged measurements.
+type SIPAEventData struct {
+	rawEventData
+	Events []SIPAEvent
+}
+
+func (e *SIPAEventData) String() string {
+	var builder bytes.Buffer
+	builder.WriteString("SIPA{")
+	for i, event := range e.Events {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		fmt.Fprintf(&builder, " %s", &event)
+	}
+	builder.WriteString(" }")
+	return builder.String()
+}
+
+func (e *SIPAEventData) Write(w io.Writer) error {
+	for _, event := range e.Events {
+		if err := event.Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *SIPAEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SIPAEventData)
+	return ok && sipaEventsEqual(e.Events, o.Events)
+}
+
+func decodeSIPAEvents(data []byte) (out []SIPAEvent, err error) {
+	r := bytes.NewReader(data)
+
+	for r.Len() > 0 {
+		var hdr struct {
+			Type SIPAEventType
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+			return nil, ioerr.EOFIsUnexpected(err)
+		}
+		if int64(hdr.Size) > int64(r.Len()) {
+			return nil, fmt.Errorf("size of %v event (%d) exceeds the remaining data (%d)", hdr.Type, hdr.Size, r.Len())
+		}
+
+		event := SIPAEvent{Type: hdr.Type, Data: make([]byte, hdr.Size)}
+		if _, err := io.ReadFull(r, event.Data); err != nil {
+			return nil, ioerr.EOFIsUnexpected(err)
+		}
+
+		if event.Type.IsContainer() {
+			children, err := decodeSIPAEvents(event.Data)
+			if err != nil {
+				return nil, err
+			}
+			event.Data = nil
+			event.Children = children
+		}
+
+		out = append(out, event)
+	}
+
+	return out, nil
+}
+
+func decodeEventDataSIPA(data []byte, eventType EventType) *SIPAEventData {
+	if eventType != EventTypeEventTag {
+		return nil
+	}
+
+	events, err := decodeSIPAEvents(data)
+	if err != nil || len(events) == 0 {
+		return nil
+	}
+
+	return &SIPAEventData{rawEventData: data, Events: events}
+}
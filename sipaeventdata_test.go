@@ -0,0 +1,90 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type sipaeventdataSuite struct{}
+
+var _ = Suite(&sipaeventdataSuite{})
+
+func (s *sipaeventdataSuite) TestDecodeEventDataSIPA(c *C) {
+	data := decodeHexString(c, "010001401c000000020002000800000001000000000000000100050004000000010000000100070002000000aa55")
+
+	event := DecodeEventDataSIPA(data, EventTypeEventTag)
+	c.Assert(event, NotNil)
+	c.Check(event.Bytes(), DeepEquals, data)
+	c.Check(event.Events, DeepEquals, []SIPAEvent{
+		{
+			Type: SIPAEventTrustBoundary,
+			Children: []SIPAEvent{
+				{Type: SIPAEventBootCounter, Data: []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+				{Type: SIPAEventOSKernelDebug, Data: []byte{0x01, 0x00, 0x00, 0x00}}}},
+		{Type: SIPAEventFilePath, Data: []byte{0xaa, 0x55}}})
+}
+
+func (s *sipaeventdataSuite) TestDecodeEventDataSIPAWrongType(c *C) {
+	data := decodeHexString(c, "010007000200000000aa55")
+	c.Check(DecodeEventDataSIPA(data, EventTypeIPL), IsNil)
+}
+
+func (s *sipaeventdataSuite) TestDecodeEventDataSIPAInvalidSize(c *C) {
+	data := decodeHexString(c, "0100070004000000aa55")
+	c.Check(DecodeEventDataSIPA(data, EventTypeEventTag), IsNil)
+}
+
+func (s *sipaeventdataSuite) TestSIPAEventDataString(c *C) {
+	event := SIPAEventData{
+		Events: []SIPAEvent{
+			{
+				Type: SIPAEventTrustBoundary,
+				Children: []SIPAEvent{
+					{Type: SIPAEventBootCounter, Data: []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+					{Type: SIPAEventType(0x0005ffff), Data: []byte{0x01}}}},
+			{Type: SIPAEventFilePath, Data: []byte{0xaa, 0x55}}}}
+	c.Check(event.String(), Equals, "SIPA{ SIPAEVENT_TRUSTBOUNDARY[ SIPAEVENT_BOOTCOUNTER: 0100000000000000, 0005ffff: 01 ], SIPAEVENT_FILEPATH: aa55 }")
+}
+
+func (s *sipaeventdataSuite) TestSIPAEventDataWrite(c *C) {
+	event := SIPAEventData{
+		Events: []SIPAEvent{
+			{
+				Type: SIPAEventTrustBoundary,
+				Children: []SIPAEvent{
+					{Type: SIPAEventBootCounter, Data: []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+					{Type: SIPAEventOSKernelDebug, Data: []byte{0x01, 0x00, 0x00, 0x00}}}},
+			{Type: SIPAEventFilePath, Data: []byte{0xaa, 0x55}}}}
+
+	w := new(bytes.Buffer)
+	c.Check(event.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, decodeHexString(c, "010001401c000000020002000800000001000000000000000100050004000000010000000100070002000000aa55"))
+}
+
+func (s *sipaeventdataSuite) TestSIPAEventTypeIsContainer(c *C) {
+	c.Check(SIPAEventTrustBoundary.IsContainer(), Equals, true)
+	c.Check(SIPAEventTrustpointAggregation.IsContainer(), Equals, true)
+	c.Check(SIPAEventBootCounter.IsContainer(), Equals, false)
+}
+
+func (s *sipaeventdataSuite) TestSIPAEventDataEqual(c *C) {
+	newData := func(bootCounter byte) *SIPAEventData {
+		return &SIPAEventData{
+			Events: []SIPAEvent{
+				{
+					Type: SIPAEventTrustBoundary,
+					Children: []SIPAEvent{
+						{Type: SIPAEventBootCounter, Data: []byte{bootCounter, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}}}},
+				{Type: SIPAEventFilePath, Data: []byte{0xaa, 0x55}}}}
+	}
+	c.Check(newData(1).Equal(newData(1)), Equals, true)
+	c.Check(newData(1).Equal(newData(2)), Equals, false)
+	c.Check(newData(1).Equal(OpaqueEventData(nil)), Equals, false)
+}
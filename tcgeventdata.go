@@ -34,6 +34,11 @@ func (d StringEventData) Write(w io.Writer) error {
 	return err
 }
 
+func (d StringEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(StringEventData)
+	return ok && d == o
+}
+
 func (d StringEventData) Bytes() []byte {
 	return []byte(d)
 }
@@ -92,22 +97,233 @@ func decodeEventDataNoAction(data []byte) (EventData, error) {
 			return nil, xerrors.Errorf("cannot decode StartupLocality data: %w", err)
 		}
 		return out, nil
+	case "NvIndexInstance":
+		out, err := decodeNvIndexInstanceEvent(data, r)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode NvIndexInstance data: %w", err)
+		}
+		return out, nil
+	case "NvIndexDynamic":
+		out, err := decodeNvIndexDynamicEvent(data, r)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode NvIndexDynamic data: %w", err)
+		}
+		return out, nil
 	default:
 		return nil, nil
 	}
 }
 
+// ActionType identifies an action string defined by the TCG specifications that is
+// measured by an EV_ACTION event.
+type ActionType int
+
+const (
+	ActionUnknown                      ActionType = iota // The action string is not one defined by the TCG specifications
+	ActionCallingInt19h                                  // "Calling INT 19h"
+	ActionReturnedInt19h                                 // "Returned INT 19h"
+	ActionReturnViaInt18h                                // "Return via INT 18h"
+	ActionBootingBCVDevice                               // "Booting BCV Device s"
+	ActionBootingBEVDevice                               // "Booting BEV Device s"
+	ActionEnteringROMBasedSetup                          // "Entering ROM Based Setup"
+	ActionUserPasswordEntered                            // "User Password Entered"
+	ActionAdministratorPasswordEntered                   // "Administrator Password Entered"
+	ActionPasswordFailure                                // "Password Failure"
+	ActionWakeEvent                                      // "Wake Event n"
+	ActionBootSequenceUserIntervention                   // "Boot Sequence User Intervention"
+	ActionChassisIntrusion                               // "Chassis Intrusion"
+	ActionNonFatalError                                  // "Non Fatal Error"
+	ActionStartOptionROMScan                             // "Start Option ROM Scan"
+	ActionUnhiddenOptionROMCode                          // "Unhidden Option ROM Code"
+)
+
+// actionStrings maps the action strings defined by the TCG specifications to their
+// type. Strings with a trailing space are a prefix to a variable suffix.
+var actionStrings = []struct {
+	str    string
+	action ActionType
+}{
+	{"Calling INT 19h", ActionCallingInt19h},
+	{"Returned INT 19h", ActionReturnedInt19h},
+	{"Return via INT 18h", ActionReturnViaInt18h},
+	{"Booting BCV Device ", ActionBootingBCVDevice},
+	{"Booting BEV Device ", ActionBootingBEVDevice},
+	{"Entering ROM Based Setup", ActionEnteringROMBasedSetup},
+	{"User Password Entered", ActionUserPasswordEntered},
+	{"Administrator Password Entered", ActionAdministratorPasswordEntered},
+	{"Password Failure", ActionPasswordFailure},
+	{"Wake Event ", ActionWakeEvent},
+	{"Boot Sequence User Intervention", ActionBootSequenceUserIntervention},
+	{"Chassis Intrusion", ActionChassisIntrusion},
+	{"Non Fatal Error", ActionNonFatalError},
+	{"Start Option ROM Scan", ActionStartOptionROMScan},
+	{"Unhidden Option ROM Code", ActionUnhiddenOptionROMCode},
+}
+
+// ActionEventData describes the action string measured by an EV_ACTION event, which is
+// a non-NULL terminated ASCII string. The event data for EV_ACTION events is
+// StringEventData, and an ActionEventData for them is obtained from Event.ActionData.
+type ActionEventData struct {
+	rawEventData
+	Type ActionType // The type of action, or ActionUnknown if the string is not defined by the TCG specifications
+	Str  string     // The action string
+}
+
+func (e *ActionEventData) String() string {
+	if e.Type == ActionUnknown {
+		return fmt.Sprintf("unknown action: %s", e.Str)
+	}
+	return e.Str
+}
+
+func (e *ActionEventData) Write(w io.Writer) error {
+	_, err := io.WriteString(w, e.Str)
+	return err
+}
+
+func (e *ActionEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*ActionEventData)
+	return ok && e.Str == o.Str
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.3 "EV_ACTION event types")
 // https://trustedcomputinggroup.org/wp-content/uploads/PC-ClientSpecific_Platform_Profile_for_TPM_2p0_Systems_v51.pdf (section 9.4.3 "EV_ACTION Event Types")
+func newActionEventData(data []byte) *ActionEventData {
+	str := string(data)
+	for _, s := range actionStrings {
+		if str == s.str || (strings.HasSuffix(s.str, " ") && strings.HasPrefix(str, s.str)) {
+			return &ActionEventData{rawEventData: data, Type: s.action, Str: str}
+		}
+	}
+	return &ActionEventData{rawEventData: data, Type: ActionUnknown, Str: str}
+}
+
 func decodeEventDataAction(data []byte) StringEventData {
 	return StringEventData(data)
 }
 
+func decodeEventDataEFIAction(data []byte) StringEventData {
+	return StringEventData(data)
+}
+
 func decodeEventDataHostPlatformSpecificCompactHash(data []byte) StringEventData {
 	return StringEventData(data)
 }
 
+// platformDefinedEventData is the common implementation of the event data types for events
+// with a platform defined format, which this package doesn't decode any further. These types
+// are distinct from OpaqueEventData so that they can be distinguished from events that
+// aren't decoded at all.
+type platformDefinedEventData struct {
+	OpaqueEventData
+	name string
+}
+
+func (d *platformDefinedEventData) String() string {
+	if s := d.OpaqueEventData.String(); s != "" {
+		return fmt.Sprintf("%s{ %s }", d.name, s)
+	}
+	return fmt.Sprintf("%s{ data: %x }", d.name, []byte(d.OpaqueEventData))
+}
+
+// platformDefinedEventDataProvider is implemented by all of the types that embed
+// platformDefinedEventData.
+type platformDefinedEventDataProvider interface {
+	platformDefined() *platformDefinedEventData
+}
+
+func (d *platformDefinedEventData) platformDefined() *platformDefinedEventData {
+	return d
+}
+
+func (d *platformDefinedEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(platformDefinedEventDataProvider)
+	if !ok {
+		return false
+	}
+	od := o.platformDefined()
+	return d.name == od.name && bytes.Equal(d.OpaqueEventData, od.OpaqueEventData)
+}
+
+// PlatformConfigFlagsEventData is the event data associated with a EV_PLATFORM_CONFIG_FLAGS
+// event. The format of this is platform defined, although it is often a 4-byte set of flags.
+type PlatformConfigFlagsEventData struct {
+	platformDefinedEventData
+}
+
+func (d *PlatformConfigFlagsEventData) String() string {
+	if flags, ok := d.Flags(); ok {
+		return fmt.Sprintf("PlatformConfigFlags{ flags: 0x%08x }", flags)
+	}
+	return fmt.Sprintf("PlatformConfigFlags{ data: %x }", []byte(d.OpaqueEventData))
+}
+
+// Flags returns the event data as a little-endian 32-bit value. If the event data is not
+// 4 bytes long, then it is in a vendor-defined format and false is returned.
+func (d *PlatformConfigFlagsEventData) Flags() (uint32, bool) {
+	if len(d.OpaqueEventData) != binary.Size(uint32(0)) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(d.OpaqueEventData), true
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.1 "Event Types")
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.4.1 "Event Types")
+func decodeEventDataPlatformConfigFlags(data []byte) *PlatformConfigFlagsEventData {
+	return &PlatformConfigFlagsEventData{platformDefinedEventData{OpaqueEventData: data, name: "PlatformConfigFlags"}}
+}
+
+// CPUMicrocodeEventData is the event data associated with a EV_CPU_MICROCODE event. The
+// format of this is platform defined, and it typically identifies the microcode update
+// that was loaded.
+type CPUMicrocodeEventData struct {
+	platformDefinedEventData
+}
+
+func decodeEventDataCPUMicrocode(data []byte) *CPUMicrocodeEventData {
+	return &CPUMicrocodeEventData{platformDefinedEventData{OpaqueEventData: data, name: "CPUMicrocode"}}
+}
+
+// TableOfDevicesEventData is the event data associated with a EV_TABLE_OF_DEVICES event.
+// The format of this is platform defined, and it typically describes the devices that
+// were enumerated by the firmware.
+type TableOfDevicesEventData struct {
+	platformDefinedEventData
+}
+
+func decodeEventDataTableOfDevices(data []byte) *TableOfDevicesEventData {
+	return &TableOfDevicesEventData{platformDefinedEventData{OpaqueEventData: data, name: "TableOfDevices"}}
+}
+
+// NonhostConfigEventData is the event data associated with a EV_NONHOST_CONFIG event.
+// The format of this is platform defined, and it describes the configuration of a
+// non-host platform component, such as a management engine.
+type NonhostConfigEventData struct {
+	platformDefinedEventData
+}
+
+func decodeEventDataNonhostConfig(data []byte) *NonhostConfigEventData {
+	return &NonhostConfigEventData{platformDefinedEventData{OpaqueEventData: data, name: "NonhostConfig"}}
+}
+
+// NonhostInfoEventData is the event data associated with a EV_NONHOST_INFO event.
+// The format of this is platform defined, and it contains information about a
+// non-host platform component, such as a management engine.
+type NonhostInfoEventData struct {
+	platformDefinedEventData
+}
+
+func decodeEventDataNonhostInfo(data []byte) *NonhostInfoEventData {
+	return &NonhostInfoEventData{platformDefinedEventData{OpaqueEventData: data, name: "NonhostInfo"}}
+}
+
 // SeparatorEventData is the event data associated with a EV_SEPARATOR event.
+//
+// For a normal separator, Value is decoded from the 4 bytes of event data in little-endian
+// byte order, and is either SeparatorEventNormalValue or SeparatorEventAltNormalValue.
+// For a separator that indicates an error, Value is SeparatorEventErrorValue, which is the
+// value that was measured to the TPM, and the event data contains an implementation defined
+// indication of the error.
 type SeparatorEventData struct {
 	rawEventData
 	Value uint32 // The separator value measured to the TPM
@@ -143,6 +359,20 @@ func (e *SeparatorEventData) Write(w io.Writer) error {
 	}
 }
 
+func (e *SeparatorEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SeparatorEventData)
+	switch {
+	case !ok:
+		return false
+	case e.Value != o.Value:
+		return false
+	case e.IsError():
+		return bytes.Equal(e.rawEventData, o.rawEventData)
+	default:
+		return true
+	}
+}
+
 // ComputeSeparatorEventDigest computes the digest associated with the separator event. The value
 // argument should be one of SeparatorEventNormalValue, SeparatorEventAltNormalValue or
 // SeparatorEventErrorValue.
@@ -160,21 +390,27 @@ func ComputeSeparatorEventDigest(alg crypto.Hash, value uint32) []byte {
 func decodeEventDataSeparator(data []byte, digests DigestMap) (*SeparatorEventData, error) {
 	var alg tpm2.HashAlgorithmId
 	for a, _ := range digests {
+		if !a.Available() {
+			// Ignore digests for algorithms that we can't compute.
+			continue
+		}
 		if !alg.IsValid() || a.Size() > alg.Size() {
 			alg = a
 		}
 	}
 
-	errorDigest, ok := separatorErrorDigests[alg]
-	if !ok {
-		h := alg.NewHash()
-		binary.Write(h, binary.LittleEndian, SeparatorEventErrorValue)
-		separatorErrorDigests[alg] = h.Sum(nil)
-		errorDigest = separatorErrorDigests[alg]
-	}
+	if alg.Available() {
+		errorDigest, ok := separatorErrorDigests[alg]
+		if !ok {
+			h := alg.NewHash()
+			binary.Write(h, binary.LittleEndian, SeparatorEventErrorValue)
+			separatorErrorDigests[alg] = h.Sum(nil)
+			errorDigest = separatorErrorDigests[alg]
+		}
 
-	if bytes.Equal(digests[alg], errorDigest) {
-		return &SeparatorEventData{rawEventData: data, Value: SeparatorEventErrorValue}, nil
+		if bytes.Equal(digests[alg], errorDigest) {
+			return &SeparatorEventData{rawEventData: data, Value: SeparatorEventErrorValue}, nil
+		}
 	}
 
 	if len(data) != binary.Size(uint32(0)) {
@@ -194,14 +430,32 @@ func decodeEventDataSeparator(data []byte, digests DigestMap) (*SeparatorEventDa
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.1 "Event Types")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf (section 7.2 "Event Types")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.4.1 "Event Types")
-func decodeEventDataTCG(data []byte, pcrIndex PCRIndex, eventType EventType, digests DigestMap) (out EventData, err error) {
+func decodeEventDataTCG(data []byte, pcrIndex PCRIndex, eventType EventType, digests DigestMap, spec Spec) (out EventData, err error) {
 	switch eventType {
 	case EventTypeNoAction:
 		return decodeEventDataNoAction(data)
 	case EventTypeSeparator:
 		return decodeEventDataSeparator(data, digests)
-	case EventTypeAction, EventTypeEFIAction:
+	case EventTypeAction:
 		return decodeEventDataAction(data), nil
+	case EventTypeEFIAction:
+		return decodeEventDataEFIAction(data), nil
+	case EventTypeCPUMicrocode:
+		return decodeEventDataCPUMicrocode(data), nil
+	case EventTypePlatformConfigFlags:
+		return decodeEventDataPlatformConfigFlags(data), nil
+	case EventTypeTableOfDevices:
+		return decodeEventDataTableOfDevices(data), nil
+	case EventTypeNonhostConfig:
+		return decodeEventDataNonhostConfig(data), nil
+	case EventTypeNonhostInfo:
+		return decodeEventDataNonhostInfo(data), nil
+	case EventTypeIPLPartitionData:
+		return decodeEventDataIPLPartition(data), nil
+	case EventTypeEventTag:
+		if spec.IsBIOS() || spec.IsEFI_1_2() {
+			return decodeEventDataTagged(data)
+		}
 	case EventTypeCompactHash:
 		if pcrIndex == 6 {
 			return decodeEventDataHostPlatformSpecificCompactHash(data), nil
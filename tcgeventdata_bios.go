@@ -5,12 +5,15 @@
 package tcglog
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 
+	"golang.org/x/xerrors"
+
 	"github.com/canonical/tcglog-parser/internal/ioerr"
 )
 
@@ -65,6 +68,13 @@ func (e *SpecIdEvent00) Write(w io.Writer) error {
 	return err
 }
 
+func (e *SpecIdEvent00) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SpecIdEvent00)
+	return ok && e.PlatformClass == o.PlatformClass && e.SpecVersionMinor == o.SpecVersionMinor &&
+		e.SpecVersionMajor == o.SpecVersionMajor && e.SpecErrata == o.SpecErrata &&
+		bytes.Equal(e.VendorInfo, o.VendorInfo)
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf
 //  (section 11.3.4.1 "Specification Event")
 func decodeSpecIdEvent00(data []byte, r io.Reader) (out *SpecIdEvent00, err error) {
@@ -86,3 +96,123 @@ func decodeSpecIdEvent00(data []byte, r io.Reader) (out *SpecIdEvent00, err erro
 
 	return out, nil
 }
+
+// MBRPartitionEntry corresponds to an entry in the partition table of a legacy
+// MBR partitioned disk.
+type MBRPartitionEntry struct {
+	BootIndicator uint8
+	StartingCHS   [3]uint8
+	OSType        uint8
+	EndingCHS     [3]uint8
+	StartingLBA   uint32
+	SizeInLBA     uint32
+}
+
+// IPLPartitionEventData is the event data associated with a EV_IPL_PARTITION_DATA
+// event, which contains the partition table of the IPL device. If the event data is
+// not a sequence of MBR partition table entries, Entries is nil and the data can be
+// obtained from Bytes().
+type IPLPartitionEventData struct {
+	rawEventData
+	Entries []MBRPartitionEntry
+}
+
+func (e *IPLPartitionEventData) String() string {
+	if e.Entries == nil {
+		return fmt.Sprintf("IPLPartitionData{ data: %x }", []byte(e.rawEventData))
+	}
+
+	var builder bytes.Buffer
+	builder.WriteString("IPLPartitionData{ entries: [")
+	for i, entry := range e.Entries {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		fmt.Fprintf(&builder, " { bootIndicator: 0x%02x, osType: 0x%02x, startingLBA: %d, sizeInLBA: %d }",
+			entry.BootIndicator, entry.OSType, entry.StartingLBA, entry.SizeInLBA)
+	}
+	builder.WriteString(" ] }")
+	return builder.String()
+}
+
+func (e *IPLPartitionEventData) Write(w io.Writer) error {
+	if e.Entries == nil {
+		_, err := w.Write(e.rawEventData)
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, e.Entries)
+}
+
+func (e *IPLPartitionEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*IPLPartitionEventData)
+	return ok && bytes.Equal(e.Bytes(), o.Bytes())
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.1 "Event Types")
+func decodeEventDataIPLPartition(data []byte) *IPLPartitionEventData {
+	out := &IPLPartitionEventData{rawEventData: data}
+
+	entrySize := binary.Size(MBRPartitionEntry{})
+	if len(data) == 0 || len(data)%entrySize != 0 {
+		return out
+	}
+
+	out.Entries = make([]MBRPartitionEntry, len(data)/entrySize)
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, out.Entries)
+	return out
+}
+
+// TaggedEventData corresponds to the TCG_PCClientTaggedEventStruct type and is the
+// event data associated with EV_EVENT_TAG events in logs for TPM family 1.2, such as
+// those created by TrouSerS-era firmware and software.
+type TaggedEventData struct {
+	rawEventData
+	EventID uint32
+	Data    []byte
+}
+
+func (e *TaggedEventData) String() string {
+	return fmt.Sprintf("PCClientTaggedEvent{ taggedEventID=%d, taggedEventData=%x }", e.EventID, e.Data)
+}
+
+func (e *TaggedEventData) Write(w io.Writer) error {
+	if int64(len(e.Data)) > math.MaxUint32 {
+		return errors.New("Data too large")
+	}
+	if err := binary.Write(w, binary.LittleEndian, e.EventID); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(e.Data))); err != nil {
+		return err
+	}
+	_, err := w.Write(e.Data)
+	return err
+}
+
+func (e *TaggedEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*TaggedEventData)
+	return ok && e.EventID == o.EventID && bytes.Equal(e.Data, o.Data)
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.2.2 "TCG_PCClientTaggedEventStruct")
+func decodeEventDataTagged(data []byte) (*TaggedEventData, error) {
+	r := bytes.NewReader(data)
+
+	var hdr struct {
+		EventID       uint32
+		EventDataSize uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, ioerr.EOFIsUnexpected(err)
+	}
+	if int64(hdr.EventDataSize) > int64(r.Len()) {
+		return nil, xerrors.Errorf("taggedEventDataSize (%d) is too large for the event size: %w", hdr.EventDataSize, ErrTruncated)
+	}
+
+	out := &TaggedEventData{rawEventData: data, EventID: hdr.EventID, Data: make([]byte, hdr.EventDataSize)}
+	r.Read(out.Data)
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d unexpected trailing bytes", r.Len())
+	}
+	return out, nil
+}
@@ -6,6 +6,10 @@ package tcglog_test
 
 import (
 	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+
+	"github.com/canonical/go-tpm2"
 
 	. "gopkg.in/check.v1"
 
@@ -50,3 +54,139 @@ func (s *tcgeventdataBiosSuite) TestSpecIdEvent00Write(c *C) {
 	c.Check(event.Write(w), IsNil)
 	c.Check(w.Bytes(), DeepEquals, decodeHexString(c, "53706563204944204576656e74303000000000000201010000"))
 }
+
+func (s *tcgeventdataBiosSuite) TestDecodeIPLPartitionData(c *C) {
+	raw := decodeHexString(c, "80202100830a0d1e0008000000200300"+
+		"000a0e1e05feffff002803000088ffff"+
+		"00000000000000000000000000000000"+
+		"00000000000000000000000000000000")
+	event := readTestEvent(c, &LogOptions{}, 5, EventTypeIPLPartitionData, nil, raw)
+
+	data, ok := event.Data.(*IPLPartitionEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Bytes(), DeepEquals, raw)
+	c.Assert(data.Entries, HasLen, 4)
+	c.Check(data.Entries[0], DeepEquals, MBRPartitionEntry{
+		BootIndicator: 0x80,
+		StartingCHS:   [3]uint8{0x20, 0x21, 0x00},
+		OSType:        0x83,
+		EndingCHS:     [3]uint8{0x0a, 0x0d, 0x1e},
+		StartingLBA:   2048,
+		SizeInLBA:     204800})
+	c.Check(data.Entries[1], DeepEquals, MBRPartitionEntry{
+		BootIndicator: 0x00,
+		StartingCHS:   [3]uint8{0x0a, 0x0e, 0x1e},
+		OSType:        0x05,
+		EndingCHS:     [3]uint8{0xfe, 0xff, 0xff},
+		StartingLBA:   206848,
+		SizeInLBA:     4294936576})
+	c.Check(data.Entries[2], DeepEquals, MBRPartitionEntry{})
+	c.Check(data.Entries[3], DeepEquals, MBRPartitionEntry{})
+
+	c.Check(data.String(), Equals, "IPLPartitionData{ entries: [ "+
+		"{ bootIndicator: 0x80, osType: 0x83, startingLBA: 2048, sizeInLBA: 204800 }, "+
+		"{ bootIndicator: 0x00, osType: 0x05, startingLBA: 206848, sizeInLBA: 4294936576 }, "+
+		"{ bootIndicator: 0x00, osType: 0x00, startingLBA: 0, sizeInLBA: 0 }, "+
+		"{ bootIndicator: 0x00, osType: 0x00, startingLBA: 0, sizeInLBA: 0 } ] }")
+
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, raw)
+
+	c.Check(data.Equal(readTestEvent(c, &LogOptions{}, 5, EventTypeIPLPartitionData, nil, raw).Data), Equals, true)
+	c.Check(data.Equal(readTestEvent(c, &LogOptions{}, 5, EventTypeIPLPartitionData, nil, raw[:48]).Data), Equals, false)
+}
+
+func (s *tcgeventdataBiosSuite) TestDecodeIPLPartitionDataUnexpectedLength(c *C) {
+	raw := decodeHexString(c, "80202100830a0d1e00080000002003")
+	event := readTestEvent(c, &LogOptions{}, 5, EventTypeIPLPartitionData, nil, raw)
+
+	data, ok := event.Data.(*IPLPartitionEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Entries, IsNil)
+	c.Check(data.Bytes(), DeepEquals, raw)
+	c.Check(data.String(), Equals, "IPLPartitionData{ data: 80202100830a0d1e00080000002003 }")
+
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, raw)
+}
+
+func (s *tcgeventdataBiosSuite) readLogWithTaggedEvent(c *C, spec EventData, data []byte) *Log {
+	return readTestLogFromEvents(c, &LogOptions{},
+		&Event{
+			PCRIndex:  0,
+			EventType: EventTypeNoAction,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+			Data:      spec},
+		&Event{
+			PCRIndex:  5,
+			EventType: EventTypeEventTag,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: ComputeEventDigest(crypto.SHA1, data)},
+			Data:      OpaqueEventData(data)})
+}
+
+func (s *tcgeventdataBiosSuite) TestDecodeTaggedEventBIOS(c *C) {
+	raw := decodeHexString(c, "0100000004000000deadbeef")
+	log := s.readLogWithTaggedEvent(c, &SpecIdEvent00{SpecVersionMinor: 2, SpecVersionMajor: 1, SpecErrata: 1}, raw)
+	c.Check(log.Spec.IsBIOS(), Equals, true)
+	c.Check(log.Warnings, HasLen, 0)
+
+	data, ok := log.Events[1].Data.(*TaggedEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.EventID, Equals, uint32(1))
+	c.Check(data.Data, DeepEquals, decodeHexString(c, "deadbeef"))
+	c.Check(data.Bytes(), DeepEquals, raw)
+	c.Check(data.String(), Equals, "PCClientTaggedEvent{ taggedEventID=1, taggedEventData=deadbeef }")
+
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, raw)
+
+	c.Check(data.Equal(&TaggedEventData{EventID: 1, Data: decodeHexString(c, "deadbeef")}), Equals, true)
+	c.Check(data.Equal(&TaggedEventData{EventID: 2, Data: decodeHexString(c, "deadbeef")}), Equals, false)
+}
+
+func (s *tcgeventdataBiosSuite) TestDecodeTaggedEventEFI_1_2(c *C) {
+	raw := decodeHexString(c, "2a00000000000000")
+	log := s.readLogWithTaggedEvent(c, &SpecIdEvent02{SpecVersionMinor: 2, SpecVersionMajor: 1, SpecErrata: 2, UintnSize: 2}, raw)
+	c.Check(log.Spec.IsEFI_1_2(), Equals, true)
+
+	data, ok := log.Events[1].Data.(*TaggedEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.EventID, Equals, uint32(42))
+	c.Check(data.Data, DeepEquals, []byte{})
+}
+
+func (s *tcgeventdataBiosSuite) TestDecodeTaggedEventTruncated(c *C) {
+	raw := decodeHexString(c, "0100000008000000deadbeef")
+	log := s.readLogWithTaggedEvent(c, &SpecIdEvent00{SpecVersionMinor: 2, SpecVersionMajor: 1, SpecErrata: 1}, raw)
+
+	c.Check(log.Events[1].Data.Bytes(), DeepEquals, raw)
+	c.Assert(log.Warnings, HasLen, 1)
+	c.Check(log.Warnings[0], ErrorMatches, `cannot decode data for event 1 \(PCR 5, type EV_EVENT_TAG, offset 0x39\): `+
+		`taggedEventDataSize \(8\) is too large for the event size: event is truncated`)
+}
+
+func (s *tcgeventdataBiosSuite) TestDecodeTaggedEventTrailingBytes(c *C) {
+	raw := decodeHexString(c, "0100000002000000deadbeef")
+	log := s.readLogWithTaggedEvent(c, &SpecIdEvent00{SpecVersionMinor: 2, SpecVersionMajor: 1, SpecErrata: 1}, raw)
+
+	c.Assert(log.Warnings, HasLen, 1)
+	c.Check(log.Warnings[0], ErrorMatches, `.*: 2 unexpected trailing bytes`)
+}
+
+func (s *tcgeventdataBiosSuite) TestTaggedEventNotDecodedForEFI_2(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	c.Assert(log.Spec.IsEFI_2(), Equals, true)
+
+	for _, event := range log.Events {
+		_, ok := event.Data.(*TaggedEventData)
+		c.Check(ok, Equals, false)
+	}
+
+	event, err := ReadEvent(bytes.NewReader(decodeHexString(c, "05000000060000000000000000000000000000000000000000000000080000002a00000000000000")), &LogOptions{})
+	c.Assert(err, IsNil)
+	_, ok := event.Data.(*TaggedEventData)
+	c.Check(ok, Equals, false)
+}
@@ -7,17 +7,20 @@ package tcglog
 import (
 	"bytes"
 	"crypto"
+	"crypto/x509"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/canonical/go-efilib"
 	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
 
 	"golang.org/x/xerrors"
 
@@ -116,6 +119,13 @@ func (e *SpecIdEvent02) Write(w io.Writer) error {
 	return err
 }
 
+func (e *SpecIdEvent02) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SpecIdEvent02)
+	return ok && e.PlatformClass == o.PlatformClass && e.SpecVersionMinor == o.SpecVersionMinor &&
+		e.SpecVersionMajor == o.SpecVersionMajor && e.SpecErrata == o.SpecErrata &&
+		e.UintnSize == o.UintnSize && bytes.Equal(e.VendorInfo, o.VendorInfo)
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf
 //  (section 7.4 "EV_NO_ACTION Event Types")
 func decodeSpecIdEvent02(data []byte, r io.Reader) (out *SpecIdEvent02, err error) {
@@ -218,8 +228,35 @@ func (e *SpecIdEvent03) Write(w io.Writer) error {
 	return err
 }
 
+func (e *SpecIdEvent03) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SpecIdEvent03)
+	if !ok || len(e.DigestSizes) != len(o.DigestSizes) {
+		return false
+	}
+	for i := range e.DigestSizes {
+		if e.DigestSizes[i] != o.DigestSizes[i] {
+			return false
+		}
+	}
+	return e.PlatformClass == o.PlatformClass && e.SpecVersionMinor == o.SpecVersionMinor &&
+		e.SpecVersionMajor == o.SpecVersionMajor && e.SpecErrata == o.SpecErrata &&
+		e.UintnSize == o.UintnSize && bytes.Equal(e.VendorInfo, o.VendorInfo)
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
 //  (secion 9.4.5.1 "Specification ID Version Event")
+// checkDigestSizes checks that the digest sizes declared for each algorithm that is
+// known to this package match the size of the algorithm. The sizes of unknown
+// algorithms can't be checked.
+func checkDigestSizes(digestSizes []EFISpecIdEventAlgorithmSize) error {
+	for _, d := range digestSizes {
+		if d.AlgorithmId.IsValid() && d.AlgorithmId.Size() != int(d.DigestSize) {
+			return fmt.Errorf("digest size for algorithm %v (%d) does not match the expected size (%d)", d.AlgorithmId, d.DigestSize, d.AlgorithmId.Size())
+		}
+	}
+	return nil
+}
+
 func decodeSpecIdEvent03(data []byte, r io.Reader) (out *SpecIdEvent03, err error) {
 	var spec rawSpecIdEvent03Hdr
 	if err := binary.Read(r, binary.LittleEndian, &spec); err != nil {
@@ -238,14 +275,16 @@ func decodeSpecIdEvent03(data []byte, r io.Reader) (out *SpecIdEvent03, err erro
 		return nil, errors.New("numberOfAlgorithms is zero")
 	}
 
+	if uint64(spec.NumberOfAlgorithms)*uint64(binary.Size(EFISpecIdEventAlgorithmSize{})) > uint64(len(data)) {
+		return nil, xerrors.Errorf("numberOfAlgorithms (%d) is too large for the event size: %w", spec.NumberOfAlgorithms, ErrTruncated)
+	}
+
 	out.DigestSizes = make([]EFISpecIdEventAlgorithmSize, spec.NumberOfAlgorithms)
 	if err := binary.Read(r, binary.LittleEndian, out.DigestSizes); err != nil {
 		return nil, ioerr.EOFIsUnexpected(err)
 	}
-	for _, d := range out.DigestSizes {
-		if d.AlgorithmId.IsValid() && d.AlgorithmId.Size() != int(d.DigestSize) {
-			return nil, fmt.Errorf("digestSize for algorithmId %v does not match expected size", d.AlgorithmId)
-		}
+	if err := checkDigestSizes(out.DigestSizes); err != nil {
+		return nil, err
 	}
 	var vendorInfoSize uint8
 	if err := binary.Read(r, binary.LittleEndian, &vendorInfoSize); err != nil {
@@ -280,6 +319,11 @@ func (e *StartupLocalityEventData) Write(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, e.StartupLocality)
 }
 
+func (e *StartupLocalityEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*StartupLocalityEventData)
+	return ok && e.StartupLocality == o.StartupLocality
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
 //  (section 9.4.5.3 "Startup Locality Event")
 func decodeStartupLocalityEvent(data []byte, r io.Reader) (*StartupLocalityEventData, error) {
@@ -291,6 +335,171 @@ func decodeStartupLocalityEvent(data []byte, r io.Reader) (*StartupLocalityEvent
 	return &StartupLocalityEventData{rawEventData: data, StartupLocality: locality}, nil
 }
 
+// NvIndexInstanceEventData is the event data for a NvIndexInstance EV_NO_ACTION event,
+// which records the public area and contents of a NV index.
+type NvIndexInstanceEventData struct {
+	rawEventData
+	Version  uint16
+	NVPublic *tpm2.NVPublic // The public area of the NV index
+	Contents []byte         // The contents of the NV index
+}
+
+func (e *NvIndexInstanceEventData) String() string {
+	if e.NVPublic == nil {
+		return fmt.Sprintf("NvIndexInstanceEvent{ Version: %d, Contents: %x }", e.Version, e.Contents)
+	}
+	return fmt.Sprintf("NvIndexInstanceEvent{ Version: %d, NvIndex: %#08x, NameAlg: %v, Size: %d, Contents: %x }",
+		e.Version, e.NVPublic.Index, e.NVPublic.NameAlg, e.NVPublic.Size, e.Contents)
+}
+
+func (e *NvIndexInstanceEventData) Write(w io.Writer) error {
+	var signature [16]byte
+	copy(signature[:], []byte("NvIndexInstance"))
+	if _, err := w.Write(signature[:]); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, e.Version); err != nil {
+		return err
+	}
+	var reserved [6]byte
+	if _, err := w.Write(reserved[:]); err != nil {
+		return err
+	}
+
+	_, err := mu.MarshalToWriter(w, mu.Sized(e.NVPublic), e.Contents)
+	return err
+}
+
+func (e *NvIndexInstanceEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*NvIndexInstanceEventData)
+	return ok && e.Version == o.Version && reflect.DeepEqual(e.NVPublic, o.NVPublic) && bytes.Equal(e.Contents, o.Contents)
+}
+
+// See the "NV Index Instance Event" section of newer revisions of the "TCG PC Client
+// Platform Firmware Profile Specification".
+func decodeNvIndexInstanceEvent(data []byte, r io.Reader) (*NvIndexInstanceEventData, error) {
+	var hdr struct {
+		Version  uint16
+		Reserved [6]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read header: %w", err)
+	}
+
+	// The NV index public area and contents are TPM2B structures, which are in the TPM's
+	// byte order.
+	var nvPublic *tpm2.NVPublic
+	if _, err := mu.UnmarshalFromReader(r, mu.Sized(&nvPublic)); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read NvPublic: %w", err)
+	}
+	var contents []byte
+	if _, err := mu.UnmarshalFromReader(r, &contents); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read NVContents: %w", err)
+	}
+
+	return &NvIndexInstanceEventData{
+		rawEventData: data,
+		Version:      hdr.Version,
+		NVPublic:     nvPublic,
+		Contents:     contents}, nil
+}
+
+// NvIndexDynamicEventData is the event data for a NvIndexDynamic EV_NO_ACTION event,
+// which records the contents of a NV index that can change during boot.
+type NvIndexDynamicEventData struct {
+	rawEventData
+	Version     uint16
+	UID         uint64 // Unique identifier for the NV index
+	Description string // Description of the NV index
+	Data        []byte // The measured contents of the NV index
+}
+
+func (e *NvIndexDynamicEventData) String() string {
+	return fmt.Sprintf("NvIndexDynamicEvent{ Version: %d, UID: %#x, Description: %q, Data: %x }",
+		e.Version, e.UID, e.Description, e.Data)
+}
+
+func (e *NvIndexDynamicEventData) Write(w io.Writer) error {
+	var signature [16]byte
+	copy(signature[:], []byte("NvIndexDynamic"))
+	if _, err := w.Write(signature[:]); err != nil {
+		return err
+	}
+
+	if len(e.Description) > math.MaxUint16 {
+		return errors.New("Description is too long")
+	}
+	if len(e.Data) > math.MaxUint16 {
+		return errors.New("Data is too long")
+	}
+
+	var reserved [6]byte
+	if err := binary.Write(w, binary.LittleEndian, e.Version); err != nil {
+		return err
+	}
+	if _, err := w.Write(reserved[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, e.UID); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(e.Description))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, e.Description); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(e.Data))); err != nil {
+		return err
+	}
+	_, err := w.Write(e.Data)
+	return err
+}
+
+func (e *NvIndexDynamicEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*NvIndexDynamicEventData)
+	return ok && e.Version == o.Version && e.UID == o.UID && e.Description == o.Description && bytes.Equal(e.Data, o.Data)
+}
+
+// See the "NV Index Dynamic Event" section of newer revisions of the "TCG PC Client
+// Platform Firmware Profile Specification".
+func decodeNvIndexDynamicEvent(data []byte, r io.Reader) (*NvIndexDynamicEventData, error) {
+	var hdr struct {
+		Version  uint16
+		Reserved [6]byte
+		UID      uint64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read header: %w", err)
+	}
+
+	var descriptionSize uint16
+	if err := binary.Read(r, binary.LittleEndian, &descriptionSize); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read DescriptionSize: %w", err)
+	}
+	description := make([]byte, descriptionSize)
+	if _, err := io.ReadFull(r, description); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read Description: %w", err)
+	}
+
+	var dataSize uint16
+	if err := binary.Read(r, binary.LittleEndian, &dataSize); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read DataSize: %w", err)
+	}
+	d := make([]byte, dataSize)
+	if _, err := io.ReadFull(r, d); err != nil {
+		return nil, ioerr.EOFIsUnexpected("cannot read Data: %w", err)
+	}
+
+	return &NvIndexDynamicEventData{
+		rawEventData: data,
+		Version:      hdr.Version,
+		UID:          hdr.UID,
+		Description:  string(description),
+		Data:         d}, nil
+}
+
 // SP800_155_PlatformIdEventData corresponds to the event data for a SP800-155-Event
 // EV_NO_ACTION event
 type SP800_155_PlatformIdEventData struct {
@@ -317,6 +526,11 @@ func (e *SP800_155_PlatformIdEventData) Write(w io.Writer) error {
 	return err
 }
 
+func (e *SP800_155_PlatformIdEventData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*SP800_155_PlatformIdEventData)
+	return ok && e.VendorId == o.VendorId && e.ReferenceManifestGuid == o.ReferenceManifestGuid
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
 //  (section 9.4.5.2 "BIOS Integrity Measurement Reference Manifest Event")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf
@@ -364,8 +578,78 @@ func (e *EFIVariableData) Write(w io.Writer) error {
 	return err
 }
 
+func (e *EFIVariableData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*EFIVariableData)
+	return ok && e.VariableName == o.VariableName && e.UnicodeName == o.UnicodeName &&
+		bytes.Equal(e.VariableData, o.VariableData)
+}
+
+// SignatureDatabaseDescription returns a description of the UEFI secure boot signature
+// database that this variable corresponds to, for the PK, KEK, db, dbx, dbt and dbr
+// variables defined in section 32.6 of the UEFI specification. This returns false if
+// the variable isn't one of these.
+func (e *EFIVariableData) SignatureDatabaseDescription() (string, bool) {
+	switch e.VariableName {
+	case efi.GlobalVariable:
+		switch e.UnicodeName {
+		case "PK":
+			return "platform key", true
+		case "KEK":
+			return "key exchange key database", true
+		}
+	case efi.ImageSecurityDatabaseGuid:
+		switch e.UnicodeName {
+		case "db":
+			return "authorized signature database", true
+		case "dbx":
+			return "forbidden signature database", true
+		case "dbt":
+			return "authorized timestamp signature database", true
+		case "dbr":
+			return "authorized recovery signature database", true
+		}
+	}
+	return "", false
+}
+
+// Authority decodes the variable data of an EV_EFI_VARIABLE_AUTHORITY event, which
+// identifies the authority used to verify an image. The variable data is normally an
+// EFI_SIGNATURE_DATA structure copied from the signature database entry that authorized
+// the image, which consists of a signature owner GUID followed by either a X.509
+// certificate or an image digest. In the case of a digest, this returns a nil
+// certificate.
+//
+// Shim measures its vendor certificate without the EFI_SIGNATURE_DATA header, in which
+// case this returns a zero owner GUID and the certificate.
+func (e *EFIVariableData) Authority() (owner efi.GUID, cert *x509.Certificate, hash Digest, err error) {
+	if len(e.VariableData) < len(owner) {
+		return efi.GUID{}, nil, nil, errors.New("variable data too short")
+	}
+	data := e.VariableData[copy(owner[:], e.VariableData):]
+
+	switch len(data) {
+	case crypto.SHA1.Size(), crypto.SHA224.Size(), crypto.SHA256.Size(), crypto.SHA384.Size(), crypto.SHA512.Size():
+		return owner, nil, Digest(data), nil
+	}
+
+	cert, err = x509.ParseCertificate(data)
+	if err == nil {
+		return owner, cert, nil, nil
+	}
+
+	// Shim doesn't measure a EFI_SIGNATURE_DATA structure when verifying an
+	// image with its vendor certificate.
+	if vendorCert, vendorErr := x509.ParseCertificate(e.VariableData); vendorErr == nil {
+		return efi.GUID{}, vendorCert, nil, nil
+	}
+	return efi.GUID{}, nil, nil, xerrors.Errorf("cannot decode certificate: %w", err)
+}
+
 // ComputeEFIVariableDataDigest computes the EFI_VARIABLE_DATA digest associated with the supplied
-// parameters
+// parameters. This is the digest measured by EV_EFI_VARIABLE_DRIVER_CONFIG, EV_EFI_VARIABLE_BOOT2
+// and EV_EFI_VARIABLE_AUTHORITY events. EV_EFI_VARIABLE_BOOT events should only measure the
+// variable data, which can be computed with ComputeEventDigest, except in logs for TPM family
+// 1.2 (see ComputeEFIVariableBootDigest).
 func ComputeEFIVariableDataDigest(alg crypto.Hash, name string, guid efi.GUID, data []byte) []byte {
 	h := alg.New()
 	varData := EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: data}
@@ -373,6 +657,18 @@ func ComputeEFIVariableDataDigest(alg crypto.Hash, name string, guid efi.GUID, d
 	return h.Sum(nil)
 }
 
+// ComputeEFIVariableBootDigest computes the digest measured by an EV_EFI_VARIABLE_BOOT event
+// with the supplied parameters to a log that conforms to the specified spec. Logs that conform
+// to the "TCG EFI Platform Specification For TPM Family 1.1 or 1.2" measure the entire
+// UEFI_VARIABLE_DATA structure for these events, whereas logs that conform to the "TCG PC
+// Client Platform Firmware Profile Specification" should only measure the variable data.
+func ComputeEFIVariableBootDigest(alg crypto.Hash, spec Spec, name string, guid efi.GUID, data []byte) []byte {
+	if spec.IsEFI_1_2() {
+		return ComputeEFIVariableDataDigest(alg, name, guid, data)
+	}
+	return ComputeEventDigest(alg, data)
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf (section 7.8 "Measuring EFI Variables")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.2.6 "Measuring UEFI Variables")
 func decodeEventDataEFIVariable(data []byte) (*EFIVariableData, error) {
@@ -382,29 +678,37 @@ func decodeEventDataEFIVariable(data []byte) (*EFIVariableData, error) {
 
 	variableName, err := efi.ReadGUID(r)
 	if err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read VariableName: %w", err)
 	}
 	d.VariableName = variableName
 
 	var unicodeNameLength uint64
 	if err := binary.Read(r, binary.LittleEndian, &unicodeNameLength); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read UnicodeNameLength: %w", err)
 	}
 
 	var variableDataLength uint64
 	if err := binary.Read(r, binary.LittleEndian, &variableDataLength); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read VariableDataLength: %w", err)
+	}
+
+	if unicodeNameLength > uint64(r.Len())/2 {
+		return nil, xerrors.Errorf("UnicodeNameLength (%d) is too large for the event size: %w", unicodeNameLength, ErrTruncated)
 	}
 
 	utf16Name, err := extractUTF16Buffer(r, unicodeNameLength)
 	if err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read UnicodeName: %w", err)
 	}
 	d.UnicodeName = convertUtf16ToString(utf16Name)
 
+	if variableDataLength > uint64(r.Len()) {
+		return nil, xerrors.Errorf("VariableDataLength (%d) is too large for the event size: %w", variableDataLength, ErrTruncated)
+	}
+
 	d.VariableData = make([]byte, variableDataLength)
 	if _, err := io.ReadFull(r, d.VariableData); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read VariableData: %w", err)
 	}
 
 	return d, nil
@@ -449,6 +753,23 @@ func (e *EFIImageLoadEvent) Write(w io.Writer) error {
 	return err
 }
 
+func (e *EFIImageLoadEvent) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*EFIImageLoadEvent)
+	if !ok || e.LocationInMemory != o.LocationInMemory || e.LengthInMemory != o.LengthInMemory ||
+		e.LinkTimeAddress != o.LinkTimeAddress {
+		return false
+	}
+	a, err := e.DevicePath.Bytes()
+	if err != nil {
+		return false
+	}
+	b, err := o.DevicePath.Bytes()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf (section 4 "Measuring PE/COFF Image Files")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.2.3 "UEFI_IMAGE_LOAD_EVENT Structure")
 func decodeEventDataEFIImageLoad(data []byte) (*EFIImageLoadEvent, error) {
@@ -456,13 +777,17 @@ func decodeEventDataEFIImageLoad(data []byte) (*EFIImageLoadEvent, error) {
 
 	var e rawEFIImageLoadEventHdr
 	if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read header: %w", err)
+	}
+
+	if e.LengthOfDevicePath > uint64(r.Len()) {
+		return nil, xerrors.Errorf("LengthOfDevicePath (%d) is larger than the remaining event data (%d bytes): %w", e.LengthOfDevicePath, r.Len(), ErrTruncated)
 	}
 
 	lr := io.LimitReader(r, int64(e.LengthOfDevicePath))
 	path, err := efi.ReadDevicePath(lr)
 	if err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read DevicePath (%d bytes): %w", e.LengthOfDevicePath, err)
 	}
 
 	return &EFIImageLoadEvent{
@@ -490,6 +815,11 @@ func (e *EFIGPTData) String() string {
 	return builder.String()
 }
 
+// Write serializes this event data from Hdr and Partitions. For data decoded from a
+// log, this may not reproduce the original event data if it contains non-zero reserved
+// fields, data after the NULL terminator of partition names or trailing bytes which
+// aren't represented by the decoded fields. The original event data is always available
+// from Bytes.
 func (e *EFIGPTData) Write(w io.Writer) error {
 	if err := e.Hdr.Write(w); err != nil {
 		return err
@@ -519,6 +849,11 @@ func (e *EFIGPTData) Write(w io.Writer) error {
 	return nil
 }
 
+func (e *EFIGPTData) Equal(other EventData) bool {
+	o, ok := resolveEventData(other).(*EFIGPTData)
+	return ok && reflect.DeepEqual(e.Hdr, o.Hdr) && reflect.DeepEqual(e.Partitions, o.Partitions)
+}
+
 func decodeEventDataEFIGPT(data []byte) (*EFIGPTData, error) {
 	r := bytes.NewReader(data)
 
@@ -527,14 +862,14 @@ func decodeEventDataEFIGPT(data []byte) (*EFIGPTData, error) {
 	// UEFI_GPT_DATA.UEFIPartitionHeader
 	hdr, err := efi.ReadPartitionTableHeader(r, false)
 	if err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read UEFIPartitionHeader: %w", err)
 	}
 	d.Hdr = *hdr
 
 	// UEFI_GPT_DATA.NumberOfPartitions
 	var numberOfParts uint64
 	if err := binary.Read(r, binary.LittleEndian, &numberOfParts); err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read NumberOfPartitions: %w", err)
 	}
 
 	if numberOfParts > math.MaxUint32 {
@@ -544,14 +879,15 @@ func decodeEventDataEFIGPT(data []byte) (*EFIGPTData, error) {
 	// UEFI_GPT_DATA.Partitions
 	partitions, err := efi.ReadPartitionEntries(r, uint32(numberOfParts), hdr.SizeOfPartitionEntry)
 	if err != nil {
-		return nil, ioerr.EOFIsUnexpected(err)
+		return nil, ioerr.EOFIsUnexpected("cannot read %d partition entries of %d bytes from %d bytes: %w", numberOfParts, hdr.SizeOfPartitionEntry, r.Len(), err)
 	}
 	d.Partitions = partitions
 
 	return d, nil
 }
 
-// ComputeEFIGPTDataDigest computes a UEFI_GPT_DATA digest from the supplied data.
+// ComputeEFIGPTDataDigest computes a UEFI_GPT_DATA digest from the supplied data, as
+// serialized by EFIGPTData.Write.
 func ComputeEFIGPTDataDigest(alg crypto.Hash, data *EFIGPTData) ([]byte, error) {
 	h := alg.New()
 	if err := data.Write(h); err != nil {
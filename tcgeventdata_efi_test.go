@@ -9,9 +9,13 @@ import (
 	"crypto"
 	_ "crypto/sha1"
 	_ "crypto/sha256"
+	"encoding/binary"
 
 	"github.com/canonical/go-efilib"
 	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+
+	"golang.org/x/xerrors"
 
 	. "gopkg.in/check.v1"
 
@@ -90,6 +94,11 @@ func (s *tcgeventdataEfiSuite) TestSpecIdEvent03Write(c *C) {
 	c.Check(w.Bytes(), DeepEquals, decodeHexString(c, "53706563204944204576656e74303300000000000002000202000000040014000b00200000"))
 }
 
+func (s *tcgeventdataEfiSuite) TestDecodeSpecIdEvent03InvalidDigestSize(c *C) {
+	_, err := DecodeEventDataNoAction(decodeHexString(c, "53706563204944204576656e74303300000000000002000202000000040014000b00140000"))
+	c.Check(err, ErrorMatches, `.*digest size for algorithm TPM_ALG_SHA256 \(20\) does not match the expected size \(32\)`)
+}
+
 func (s *tcgeventdataEfiSuite) TestSpecIdEvent03WriteWithVendorInfo(c *C) {
 	event := SpecIdEvent03{
 		PlatformClass:    0,
@@ -197,6 +206,37 @@ func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIVariable2(c *C) {
 	c.Check(event.VariableData, DeepEquals, []byte{0x01})
 }
 
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIVariableUnicodeNameLengthTooLarge(c *C) {
+	data := decodeHexString(c, "61dfe48bca93d211aa0d00e098032b8c0a00000000000000010000000000000053006500630075007200650042006f006f00740001")
+	binary.LittleEndian.PutUint64(data[16:], 0x8000000000000000)
+	_, err := DecodeEventDataEFIVariable(data)
+	c.Check(err, ErrorMatches, `UnicodeNameLength \(9223372036854775808\) is too large for the event size: event is truncated`)
+	c.Check(xerrors.Is(err, ErrTruncated), Equals, true)
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIVariableVariableDataLengthTooLarge(c *C) {
+	data := decodeHexString(c, "61dfe48bca93d211aa0d00e098032b8c0a00000000000000010000000000000053006500630075007200650042006f006f00740001")
+	binary.LittleEndian.PutUint64(data[24:], 2)
+	_, err := DecodeEventDataEFIVariable(data)
+	c.Check(err, ErrorMatches, `VariableDataLength \(2\) is too large for the event size: event is truncated`)
+	c.Check(xerrors.Is(err, ErrTruncated), Equals, true)
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIVariableTruncatedHeader(c *C) {
+	data := decodeHexString(c, "61dfe48bca93d211aa0d00e098032b8c0a000000")
+	_, err := DecodeEventDataEFIVariable(data)
+	c.Check(err, ErrorMatches, `cannot read UnicodeNameLength: unexpected EOF`)
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIImageLoadLengthOfDevicePathTooLarge(c *C) {
+	data := make([]byte, 32)
+	binary.LittleEndian.PutUint64(data[24:], 16)
+	data = append(data, 0x7f, 0xff, 0x04, 0x00)
+	_, err := DecodeEventDataEFIImageLoad(data)
+	c.Check(err, ErrorMatches, `LengthOfDevicePath \(16\) is larger than the remaining event data \(4 bytes\): event is truncated`)
+	c.Check(xerrors.Is(err, ErrTruncated), Equals, true)
+}
+
 func (s *tcgeventdataEfiSuite) TestEFIImageLoadEventString(c *C) {
 	event := EFIImageLoadEvent{
 		LocationInMemory: 0x6556c018,
@@ -307,14 +347,7 @@ func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIImageLoad2(c *C) {
 }
 
 func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIGPT(c *C) {
-	data := decodeHexString(c, "4546492050415254000001005c000000edeb4e64000000000100000000000000af5277ee0000000022000000000000008e52"+
-		"77ee00000000c273aea42f0e1345bd3c456da7f7f0fd02000000000000008000000080000000f628450b030000000000000028732ac11ff8d211ba4b00a0"+
-		"c93ec93b7b94de66b2fd2545b75230d66bb2b9600008000000000000ff071000000000000000000000000000450046004900200053007900730074006500"+
-		"6d00200050006100720074006900740069006f006e000000000000000000000000000000000000000000000000000000000000000000af3dc60f83847247"+
-		"8e793d69d8477de4dc171b63b7ed1d4da7616dce3efce4150008100000000000ffe726000000000000000000000000000000000000000000000000000000"+
-		"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000af3dc60f"+
-		"838472478e793d69d8477de421f54ac6f114f24eadb520b59ca2335a00e8260000000000ff4f77ee00000000000000000000000000000000000000000000"+
-		"0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
+	data := s.gptEventData(c)
 
 	event, err := DecodeEventDataEFIGPT(data)
 	c.Assert(err, IsNil)
@@ -357,6 +390,99 @@ func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIGPT(c *C) {
 		}})
 }
 
+func (s *tcgeventdataEfiSuite) gptEventData(c *C) []byte {
+	return decodeHexString(c, "4546492050415254000001005c000000edeb4e64000000000100000000000000af5277ee0000000022000000000000008e52"+
+		"77ee00000000c273aea42f0e1345bd3c456da7f7f0fd02000000000000008000000080000000f628450b030000000000000028732ac11ff8d211ba4b00a0"+
+		"c93ec93b7b94de66b2fd2545b75230d66bb2b9600008000000000000ff071000000000000000000000000000450046004900200053007900730074006500"+
+		"6d00200050006100720074006900740069006f006e000000000000000000000000000000000000000000000000000000000000000000af3dc60f83847247"+
+		"8e793d69d8477de4dc171b63b7ed1d4da7616dce3efce4150008100000000000ffe726000000000000000000000000000000000000000000000000000000"+
+		"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000af3dc60f"+
+		"838472478e793d69d8477de421f54ac6f114f24eadb520b59ca2335a00e8260000000000ff4f77ee00000000000000000000000000000000000000000000"+
+		"0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIGPTBadHeaderCRC(c *C) {
+	// The header CRC isn't checked.
+	data := s.gptEventData(c)
+	data[24] = 0x02 // UEFIPartitionHeader.MyLBA
+
+	event, err := DecodeEventDataEFIGPT(data)
+	c.Assert(err, IsNil)
+	c.Check(event.Hdr.MyLBA, Equals, efi.LBA(2))
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIGPTNotReproducible(c *C) {
+	// Data that isn't represented by the decoded fields, such as bytes after the
+	// NULL terminator of a partition name and trailing bytes, is preserved by
+	// Bytes but not by Write.
+	orig := s.gptEventData(c)
+	data := s.gptEventData(c)
+	data[len(data)-2] = 0x41 // the name of the last partition is empty, so this is after the NULL terminator
+	data = append(data, 0x00, 0x00, 0x00, 0x00)
+
+	event, err := DecodeEventDataEFIGPT(data)
+	c.Assert(err, IsNil)
+	c.Check(event.Partitions, HasLen, 3)
+	c.Check(event.Bytes(), DeepEquals, data)
+
+	w := new(bytes.Buffer)
+	c.Check(event.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, orig)
+
+	digest, err := ComputeEFIGPTDataDigest(crypto.SHA256, event)
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, ComputeEventDigest(crypto.SHA256, orig))
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeEventDataEFIGPTModified(c *C) {
+	// Changes to the decoded fields are serialized by Write.
+	data := s.gptEventData(c)
+
+	event, err := DecodeEventDataEFIGPT(data)
+	c.Assert(err, IsNil)
+	event.Partitions = event.Partitions[:2]
+
+	w := new(bytes.Buffer)
+	c.Check(event.Write(w), IsNil)
+	c.Check(w.Bytes(), Not(DeepEquals), data)
+
+	modified, err := DecodeEventDataEFIGPT(w.Bytes())
+	c.Assert(err, IsNil)
+	c.Check(modified.Partitions, DeepEquals, event.Partitions)
+	c.Check(event.Bytes(), DeepEquals, data)
+
+	digest, err := ComputeEFIGPTDataDigest(crypto.SHA256, event)
+	c.Check(err, IsNil)
+	c.Check(digest, DeepEquals, ComputeEventDigest(crypto.SHA256, w.Bytes()))
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIGPTDataFromLog(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	var event *Event
+	for _, e := range log.Events {
+		if e.EventType == EventTypeEFIGPTEvent {
+			event = e
+			break
+		}
+	}
+	c.Assert(event, NotNil)
+
+	data, ok := event.Data.(*EFIGPTData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Partitions, HasLen, 3)
+
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, data.Bytes())
+
+	for _, alg := range log.Algorithms {
+		digest, err := ComputeEFIGPTDataDigest(alg.GetHash(), data)
+		c.Check(err, IsNil)
+		c.Check(Digest(digest), DeepEquals, event.Digests[alg], Commentf("algorithm %v", alg))
+	}
+}
+
 func (s *tcgeventdataEfiSuite) TestEFIGPTDataString(c *C) {
 	event := EFIGPTData{
 		Hdr: efi.PartitionTableHeader{
@@ -544,3 +670,304 @@ func (s *tcgeventdataEfiSuite) TestComputeEFIGPTDataDigestSHA1(c *C) {
 	c.Check(err, IsNil)
 	c.Check(digest, DeepEquals, decodeHexString(c, "4243b31b1b3a540afd2df40ab96f272bdab403f3"))
 }
+
+// The measured bytes for EFI variable events depend on the event type:
+//   - EV_EFI_VARIABLE_DRIVER_CONFIG, EV_EFI_VARIABLE_BOOT2 and EV_EFI_VARIABLE_AUTHORITY
+//     events measure the entire UEFI_VARIABLE_DATA structure.
+//   - EV_EFI_VARIABLE_BOOT events should measure only the variable data, which is what
+//     EDK2 does and what the 1.05 revision of the PC Client Platform Firmware Profile
+//     Specification requires. Some firmware measures the entire UEFI_VARIABLE_DATA
+//     structure instead, including the firmware that generated the test log.
+func (s *tcgeventdataEfiSuite) checkEFIVariableMeasuredBytes(c *C, eventType EventType, measured func(*EFIVariableData) []byte) {
+	log := readTestLog(c, &LogOptions{})
+
+	n := 0
+	for i, event := range log.Events {
+		if event.EventType != eventType {
+			continue
+		}
+		n++
+
+		data, ok := event.Data.(*EFIVariableData)
+		c.Assert(ok, Equals, true)
+		for alg, digest := range event.Digests {
+			c.Check(digest, DeepEquals, Digest(ComputeEventDigest(alg.GetHash(), measured(data))), Commentf("event %d, alg %v", i, alg))
+		}
+	}
+	c.Check(n > 0, Equals, true)
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDriverConfigMeasuredBytes(c *C) {
+	s.checkEFIVariableMeasuredBytes(c, EventTypeEFIVariableDriverConfig, func(data *EFIVariableData) []byte {
+		return data.Bytes()
+	})
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDriverConfigMeasuredBytesMatchesCompute(c *C) {
+	s.checkEFIVariableMeasuredBytes(c, EventTypeEFIVariableDriverConfig, func(data *EFIVariableData) []byte {
+		w := new(bytes.Buffer)
+		c.Check(data.Write(w), IsNil)
+		return w.Bytes()
+	})
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableAuthorityMeasuredBytes(c *C) {
+	s.checkEFIVariableMeasuredBytes(c, EventTypeEFIVariableAuthority, func(data *EFIVariableData) []byte {
+		return data.Bytes()
+	})
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableBootMeasuredBytes(c *C) {
+	// Logs for TPM family 2.0 only measure the variable data for EV_EFI_VARIABLE_BOOT
+	// events. The quirk that accepts the entire structure is tested in internal/check.
+	data := &EFIVariableData{
+		VariableName: efi.GlobalVariable,
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x03, 0x00, 0x00, 0x00, 0x01, 0x00}}
+	event := &Event{
+		PCRIndex:  1,
+		EventType: EventTypeEFIVariableBoot,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA256: ComputeEventDigest(crypto.SHA256, data.VariableData)},
+		Data:      data}
+
+	efi2 := Spec{PlatformType: PlatformTypeEFI, Major: 2}
+	c.Check(Digest(ComputeEFIVariableBootDigest(crypto.SHA256, efi2, data.UnicodeName, data.VariableName, data.VariableData)), DeepEquals,
+		event.Digests[tpm2.HashAlgorithmSHA256])
+	c.Check(Digest(ComputeEFIVariableDataDigest(crypto.SHA256, data.UnicodeName, data.VariableName, data.VariableData)), Not(DeepEquals),
+		event.Digests[tpm2.HashAlgorithmSHA256])
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableBootMeasuredBytesQuirk(c *C) {
+	s.checkEFIVariableMeasuredBytes(c, EventTypeEFIVariableBoot, func(data *EFIVariableData) []byte {
+		return data.Bytes()
+	})
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableBoot2MeasuredBytes(c *C) {
+	data := &EFIVariableData{
+		VariableName: efi.GlobalVariable,
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x03, 0x00, 0x00, 0x00, 0x01, 0x00}}
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+
+	c.Check(ComputeEFIVariableDataDigest(crypto.SHA256, data.UnicodeName, data.VariableName, data.VariableData), DeepEquals,
+		ComputeEventDigest(crypto.SHA256, w.Bytes()))
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDataEqual(c *C) {
+	a := &EFIVariableData{VariableName: efi.GlobalVariable, UnicodeName: "SecureBoot", VariableData: []byte{0x01}}
+	c.Check(a.Equal(&EFIVariableData{VariableName: efi.GlobalVariable, UnicodeName: "SecureBoot", VariableData: []byte{0x01}}), Equals, true)
+	c.Check(a.Equal(&EFIVariableData{VariableName: efi.GlobalVariable, UnicodeName: "SecureBoot", VariableData: []byte{0x00}}), Equals, false)
+	c.Check(a.Equal(&EFIVariableData{VariableName: efi.GlobalVariable, UnicodeName: "AuditMode", VariableData: []byte{0x01}}), Equals, false)
+	c.Check(a.Equal(&EFIVariableData{VariableName: efi.ImageSecurityDatabaseGuid, UnicodeName: "SecureBoot", VariableData: []byte{0x01}}), Equals, false)
+	c.Check(a.Equal(OpaqueEventData(a.Bytes())), Equals, false)
+}
+
+func (s *tcgeventdataEfiSuite) TestComputeEFIVariableBootDigest(c *C) {
+	data := decodeHexString(c, "0300000001000200")
+
+	efi2 := Spec{PlatformType: PlatformTypeEFI, Major: 2}
+	c.Check(ComputeEFIVariableBootDigest(crypto.SHA256, efi2, "BootOrder", efi.GlobalVariable, data), DeepEquals,
+		ComputeEventDigest(crypto.SHA256, data))
+
+	efi12 := Spec{PlatformType: PlatformTypeEFI, Major: 1, Minor: 2}
+	c.Check(ComputeEFIVariableBootDigest(crypto.SHA1, efi12, "BootOrder", efi.GlobalVariable, data), DeepEquals,
+		ComputeEFIVariableDataDigest(crypto.SHA1, "BootOrder", efi.GlobalVariable, data))
+}
+
+func (s *tcgeventdataEfiSuite) TestComputeEFIVariableBootDigestTPM12Log(c *C) {
+	// Build a log for TPM family 1.2 in which EV_EFI_VARIABLE_BOOT events measure
+	// the entire UEFI_VARIABLE_DATA structure, as EDK2's TcgDxe does.
+	varData := &EFIVariableData{VariableName: efi.GlobalVariable, UnicodeName: "BootOrder", VariableData: decodeHexString(c, "0300000001000200")}
+	w := new(bytes.Buffer)
+	c.Assert(varData.Write(w), IsNil)
+
+	log := NewLogForTesting([]*Event{
+		{
+			PCRIndex:  0,
+			EventType: EventTypeNoAction,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: make(Digest, tpm2.HashAlgorithmSHA1.Size())},
+			Data:      &SpecIdEvent02{SpecVersionMinor: 2, SpecVersionMajor: 1, SpecErrata: 2, UintnSize: 2}},
+		{
+			PCRIndex:  1,
+			EventType: EventTypeEFIVariableBoot,
+			Digests:   DigestMap{tpm2.HashAlgorithmSHA1: ComputeEventDigest(crypto.SHA1, w.Bytes())},
+			Data:      varData}})
+
+	w = new(bytes.Buffer)
+	c.Assert(log.Write(w), IsNil)
+	log, err := ReadLog(w, &LogOptions{})
+	c.Assert(err, IsNil)
+	c.Check(log.Spec.IsEFI_1_2(), Equals, true)
+	c.Assert(log.Events, HasLen, 2)
+
+	event := log.Events[1]
+	data, ok := event.Data.(*EFIVariableData)
+	c.Assert(ok, Equals, true)
+	c.Check(event.Digests[tpm2.HashAlgorithmSHA1], DeepEquals,
+		Digest(ComputeEFIVariableBootDigest(crypto.SHA1, log.Spec, data.UnicodeName, data.VariableName, data.VariableData)))
+}
+
+func (s *tcgeventdataEfiSuite) authorityEvents(c *C) (out []*EFIVariableData) {
+	log := readTestLog(c, &LogOptions{})
+	for _, event := range log.Events {
+		if event.EventType != EventTypeEFIVariableAuthority {
+			continue
+		}
+		data, ok := event.Data.(*EFIVariableData)
+		c.Assert(ok, Equals, true)
+		if data.UnicodeName == "SbatLevel" {
+			continue
+		}
+		out = append(out, data)
+	}
+	return out
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDataAuthorityCert(c *C) {
+	events := s.authorityEvents(c)
+	c.Assert(events, HasLen, 2)
+
+	owner, cert, hash, err := events[0].Authority()
+	c.Check(err, IsNil)
+	c.Check(owner, Equals, efi.MakeGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b}))
+	c.Assert(cert, NotNil)
+	c.Check(cert.Subject.CommonName, Equals, "Microsoft Corporation UEFI CA 2011")
+	c.Check(hash, IsNil)
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDataAuthorityShimVendorCert(c *C) {
+	events := s.authorityEvents(c)
+	c.Assert(events, HasLen, 2)
+
+	owner, cert, hash, err := events[1].Authority()
+	c.Check(err, IsNil)
+	c.Check(owner, Equals, efi.GUID{})
+	c.Assert(cert, NotNil)
+	c.Check(cert.Subject.CommonName, Equals, "Canonical Ltd. Master Certificate Authority")
+	c.Check(hash, IsNil)
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDataAuthorityHash(c *C) {
+	owner := efi.MakeGUID(0x605dab50, 0xe046, 0x4300, 0xabb6, [...]uint8{0x3d, 0xd8, 0x10, 0xdd, 0x8b, 0x23})
+	digest := decodeHexString(c, "80b4d96931bf0d02fd91a61e19d14f1da452e66db2408ca8604d411f92659f0a")
+
+	data := &EFIVariableData{
+		VariableName: efi.ImageSecurityDatabaseGuid,
+		UnicodeName:  "db",
+		VariableData: append(owner[:], digest...)}
+	decodedOwner, cert, hash, err := data.Authority()
+	c.Check(err, IsNil)
+	c.Check(decodedOwner, Equals, owner)
+	c.Check(cert, IsNil)
+	c.Check(hash, DeepEquals, Digest(digest))
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDataAuthorityInvalid(c *C) {
+	data := &EFIVariableData{
+		VariableName: efi.ImageSecurityDatabaseGuid,
+		UnicodeName:  "db",
+		VariableData: make([]byte, 40)}
+	_, _, _, err := data.Authority()
+	c.Check(err, ErrorMatches, `cannot decode certificate: .*`)
+
+	data.VariableData = make([]byte, 10)
+	_, _, _, err = data.Authority()
+	c.Check(err, ErrorMatches, `variable data too short`)
+}
+
+func (s *tcgeventdataEfiSuite) TestEFIVariableDataSignatureDatabaseDescription(c *C) {
+	for _, t := range []struct {
+		guid        efi.GUID
+		name        string
+		description string
+	}{
+		{efi.GlobalVariable, "PK", "platform key"},
+		{efi.GlobalVariable, "KEK", "key exchange key database"},
+		{efi.ImageSecurityDatabaseGuid, "db", "authorized signature database"},
+		{efi.ImageSecurityDatabaseGuid, "dbx", "forbidden signature database"},
+		{efi.ImageSecurityDatabaseGuid, "dbt", "authorized timestamp signature database"},
+		{efi.ImageSecurityDatabaseGuid, "dbr", "authorized recovery signature database"},
+	} {
+		data := &EFIVariableData{VariableName: t.guid, UnicodeName: t.name}
+		description, ok := data.SignatureDatabaseDescription()
+		c.Check(ok, Equals, true, Commentf("%s", t.name))
+		c.Check(description, Equals, t.description, Commentf("%s", t.name))
+	}
+
+	for _, data := range []*EFIVariableData{
+		{VariableName: efi.GlobalVariable, UnicodeName: "SecureBoot"},
+		{VariableName: efi.GlobalVariable, UnicodeName: "dbt"},
+		{VariableName: efi.ImageSecurityDatabaseGuid, UnicodeName: "PK"},
+	} {
+		_, ok := data.SignatureDatabaseDescription()
+		c.Check(ok, Equals, false, Commentf("%s", data.UnicodeName))
+	}
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeNvIndexInstanceEvent(c *C) {
+	nvPublic := &tpm2.NVPublic{
+		Index:   0x01c00002,
+		NameAlg: tpm2.HashAlgorithmSHA256,
+		Attrs:   tpm2.NVTypeOrdinary.WithAttrs(tpm2.AttrNVPPRead | tpm2.AttrNVAuthRead | tpm2.AttrNVWritten),
+		Size:    4}
+
+	w := new(bytes.Buffer)
+	w.Write([]byte("NvIndexInstance\x00"))
+	w.Write([]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	_, err := mu.MarshalToWriter(w, mu.Sized(nvPublic), []byte{0xde, 0xad, 0xbe, 0xef})
+	c.Assert(err, IsNil)
+	raw := w.Bytes()
+
+	e, err := DecodeEventDataNoAction(raw)
+	c.Assert(err, IsNil)
+	data, ok := e.(*NvIndexInstanceEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Version, Equals, uint16(1))
+	c.Check(data.NVPublic, DeepEquals, nvPublic)
+	c.Check(data.Contents, DeepEquals, []byte{0xde, 0xad, 0xbe, 0xef})
+	c.Check(data.Bytes(), DeepEquals, raw)
+	c.Check(data.String(), Equals, "NvIndexInstanceEvent{ Version: 1, NvIndex: 0x01c00002, NameAlg: TPM_ALG_SHA256, Size: 4, Contents: deadbeef }")
+
+	w = new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, raw)
+	c.Check(data.Equal(&NvIndexInstanceEventData{Version: 1, NVPublic: nvPublic, Contents: []byte{0xde, 0xad, 0xbe, 0xef}}), Equals, true)
+	c.Check(data.Equal(&NvIndexInstanceEventData{Version: 1, NVPublic: nvPublic, Contents: []byte{0xde, 0xad}}), Equals, false)
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeNvIndexInstanceEventTruncated(c *C) {
+	_, err := DecodeEventDataNoAction(decodeHexString(c, "4e76496e646578496e7374616e6365000100000000000000000e01c00002000b"))
+	c.Check(err, ErrorMatches, `(?s)cannot decode NvIndexInstance data: cannot read NvPublic: .*`)
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeNvIndexDynamicEvent(c *C) {
+	raw := decodeHexString(c, "4e76496e64657844796e616d69630000"+ // Signature
+		"0100"+"000000000000"+ // Version, Reserved
+		"0807060504030201"+ // UID
+		"0300"+"666f6f"+ // DescriptionSize, Description
+		"0400"+"deadbeef") // DataSize, Data
+
+	e, err := DecodeEventDataNoAction(raw)
+	c.Assert(err, IsNil)
+	data, ok := e.(*NvIndexDynamicEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.Version, Equals, uint16(1))
+	c.Check(data.UID, Equals, uint64(0x0102030405060708))
+	c.Check(data.Description, Equals, "foo")
+	c.Check(data.Data, DeepEquals, []byte{0xde, 0xad, 0xbe, 0xef})
+	c.Check(data.String(), Equals, `NvIndexDynamicEvent{ Version: 1, UID: 0x102030405060708, Description: "foo", Data: deadbeef }`)
+
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, raw)
+	c.Check(data.Equal(&NvIndexDynamicEventData{Version: 1, UID: 0x0102030405060708, Description: "foo", Data: []byte{0xde, 0xad, 0xbe, 0xef}}), Equals, true)
+	c.Check(data.Equal(&NvIndexDynamicEventData{Version: 1, UID: 0x0102030405060708, Description: "bar", Data: []byte{0xde, 0xad, 0xbe, 0xef}}), Equals, false)
+}
+
+func (s *tcgeventdataEfiSuite) TestDecodeNvIndexDynamicEventTruncated(c *C) {
+	_, err := DecodeEventDataNoAction(decodeHexString(c, "4e76496e64657844796e616d69630000"+
+		"0100"+"000000000000"+"0807060504030201"+"0300"+"666f6f"+"0400"+"dead"))
+	c.Check(err, ErrorMatches, `cannot decode NvIndexDynamic data: cannot read Data: unexpected EOF`)
+}
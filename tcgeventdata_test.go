@@ -234,3 +234,153 @@ func (s *tcgeventdataSuite) TestDecodeEventDataNoActionSpecIdEvent03WithVendorIn
 		{AlgorithmId: tpm2.HashAlgorithmSHA256, DigestSize: 32}})
 	c.Check(event.VendorInfo, DeepEquals, []byte{0xa5, 0xa5, 0xa5, 0xa5})
 }
+
+func (s *tcgeventdataSuite) TestDecodePlatformDefinedEventData(c *C) {
+	for _, t := range []struct {
+		eventType EventType
+		data      []byte
+		expected  EventData
+		str       string
+	}{
+		{eventType: EventTypePlatformConfigFlags, data: []byte{0x8b, 0x04, 0x00, 0x00, 0x01}, expected: (*PlatformConfigFlagsEventData)(nil), str: "PlatformConfigFlags{ data: 8b04000001 }"},
+		{eventType: EventTypeCPUMicrocode, data: []byte{0x01, 0x00, 0x00, 0x00, 0xea, 0x00, 0x00, 0x00}, expected: (*CPUMicrocodeEventData)(nil), str: "CPUMicrocode{ data: 01000000ea000000 }"},
+		{eventType: EventTypeCPUMicrocode, data: []byte("Microcode Update\x00"), expected: (*CPUMicrocodeEventData)(nil), str: "CPUMicrocode{ Microcode Update }"},
+		{eventType: EventTypeTableOfDevices, data: []byte{0x02, 0x00, 0x01, 0x80}, expected: (*TableOfDevicesEventData)(nil), str: "TableOfDevices{ data: 02000180 }"},
+		{eventType: EventTypeNonhostConfig, data: []byte{0x01, 0x02, 0x03, 0x04}, expected: (*NonhostConfigEventData)(nil), str: "NonhostConfig{ data: 01020304 }"},
+		{eventType: EventTypeNonhostInfo, data: []byte{0x01, 0x02, 0x03, 0x04}, expected: (*NonhostInfoEventData)(nil), str: "NonhostInfo{ data: 01020304 }"},
+		{eventType: EventTypeNonhostInfo, data: []byte("ME Firmware\x00"), expected: (*NonhostInfoEventData)(nil), str: "NonhostInfo{ ME Firmware }"},
+	} {
+		data := readTestEvent(c, &LogOptions{}, 1, t.eventType, nil, t.data).Data
+		c.Check(data, FitsTypeOf, t.expected)
+		c.Check(data.String(), Equals, t.str)
+		c.Check(data.Bytes(), DeepEquals, t.data)
+
+		w := new(bytes.Buffer)
+		c.Check(data.Write(w), IsNil)
+		c.Check(w.Bytes(), DeepEquals, t.data)
+
+		c.Check(data.Equal(readTestEvent(c, &LogOptions{}, 1, t.eventType, nil, t.data).Data), Equals, true)
+		c.Check(data.Equal(OpaqueEventData(t.data)), Equals, false)
+	}
+}
+
+func (s *tcgeventdataSuite) TestPlatformDefinedEventDataEqualDifferentType(c *C) {
+	config := readTestEvent(c, &LogOptions{}, 1, EventTypeNonhostConfig, nil, []byte{0x01, 0x02, 0x03, 0x04}).Data
+	info := readTestEvent(c, &LogOptions{}, 1, EventTypeNonhostInfo, nil, []byte{0x01, 0x02, 0x03, 0x04}).Data
+	c.Check(config.Equal(info), Equals, false)
+	c.Check(config.Equal(readTestEvent(c, &LogOptions{}, 1, EventTypeNonhostConfig, nil, []byte{0x01, 0x02, 0x03, 0x05}).Data), Equals, false)
+}
+
+func (s *tcgeventdataSuite) TestPlatformConfigFlagsEventDataFlags(c *C) {
+	event, ok := readTestEvent(c, &LogOptions{}, 1, EventTypePlatformConfigFlags, nil, []byte{0x01, 0x02, 0x00, 0x00}).Data.(*PlatformConfigFlagsEventData)
+	c.Assert(ok, Equals, true)
+	flags, ok := event.Flags()
+	c.Check(ok, Equals, true)
+	c.Check(flags, Equals, uint32(0x201))
+	c.Check(event.String(), Equals, "PlatformConfigFlags{ flags: 0x00000201 }")
+
+	event, ok = readTestEvent(c, &LogOptions{}, 1, EventTypePlatformConfigFlags, nil, []byte{0x8b, 0x04, 0x00, 0x00, 0x01}).Data.(*PlatformConfigFlagsEventData)
+	c.Assert(ok, Equals, true)
+	_, ok = event.Flags()
+	c.Check(ok, Equals, false)
+}
+
+func (s *tcgeventdataSuite) TestDecodeErrorSeparator(c *C) {
+	event := readTestEvent(c, &LogOptions{}, 7, EventTypeSeparator, ComputeSeparatorEventDigest(crypto.SHA1, SeparatorEventErrorValue), []byte("error"))
+	data, ok := event.Data.(*SeparatorEventData)
+	c.Assert(ok, Equals, true)
+	c.Check(data.IsError(), Equals, true)
+}
+
+func (s *tcgeventdataSuite) TestDecodeErrorSeparatorDisabledAlgorithm(c *C) {
+	// The error digest can't be computed, so the event data is interpreted
+	// as a separator value and fails to decode.
+	event := readTestEvent(c, &LogOptions{DisabledAlgorithms: AlgorithmIdList{tpm2.HashAlgorithmSHA1}}, 7, EventTypeSeparator,
+		ComputeSeparatorEventDigest(crypto.SHA1, SeparatorEventErrorValue), []byte("error"))
+	c.Check(event.Data, ErrorMatches, `data is the wrong size`)
+}
+
+func (s *tcgeventdataSuite) TestSeparatorEventDataEqual(c *C) {
+	normal := &SeparatorEventData{Value: SeparatorEventNormalValue}
+	c.Check(normal.Equal(&SeparatorEventData{Value: SeparatorEventNormalValue}), Equals, true)
+	c.Check(normal.Equal(&SeparatorEventData{Value: SeparatorEventAltNormalValue}), Equals, false)
+	c.Check(NewErrorSeparatorEventData([]byte("foo")).Equal(NewErrorSeparatorEventData([]byte("foo"))), Equals, true)
+	c.Check(NewErrorSeparatorEventData([]byte("foo")).Equal(NewErrorSeparatorEventData([]byte("bar"))), Equals, false)
+}
+
+func (s *tcgeventdataSuite) decodeActionEvent(c *C, str string) *ActionEventData {
+	event := readTestEvent(c, &LogOptions{}, 4, EventTypeAction, ComputeStringEventDigest(crypto.SHA1, str), []byte(str))
+	c.Check(event.Data, DeepEquals, StringEventData(str))
+	data := event.ActionData()
+	c.Assert(data, NotNil)
+	return data
+}
+
+func (s *tcgeventdataSuite) TestDecodeActionEventData(c *C) {
+	data := s.decodeActionEvent(c, "Calling INT 19h")
+	c.Check(data.Type, Equals, ActionCallingInt19h)
+	c.Check(data.Str, Equals, "Calling INT 19h")
+	c.Check(data.String(), Equals, "Calling INT 19h")
+	c.Check(data.Bytes(), DeepEquals, []byte("Calling INT 19h"))
+}
+
+func (s *tcgeventdataSuite) TestDecodeActionEventDataWithSuffix(c *C) {
+	data := s.decodeActionEvent(c, "Booting BCV Device SATA0")
+	c.Check(data.Type, Equals, ActionBootingBCVDevice)
+	c.Check(data.Str, Equals, "Booting BCV Device SATA0")
+
+	data = s.decodeActionEvent(c, "Wake Event 3")
+	c.Check(data.Type, Equals, ActionWakeEvent)
+}
+
+func (s *tcgeventdataSuite) TestDecodeActionEventDataUnknown(c *C) {
+	data := s.decodeActionEvent(c, "Vendor specific action")
+	c.Check(data.Type, Equals, ActionUnknown)
+	c.Check(data.Str, Equals, "Vendor specific action")
+	c.Check(data.String(), Equals, "unknown action: Vendor specific action")
+
+	// A prefix match only applies to strings with a variable suffix.
+	data = s.decodeActionEvent(c, "Calling INT 19h again")
+	c.Check(data.Type, Equals, ActionUnknown)
+}
+
+func (s *tcgeventdataSuite) TestActionEventDataWrite(c *C) {
+	data := &ActionEventData{Type: ActionReturnedInt19h, Str: "Returned INT 19h"}
+	w := new(bytes.Buffer)
+	c.Check(data.Write(w), IsNil)
+	c.Check(w.Bytes(), DeepEquals, []byte("Returned INT 19h"))
+}
+
+func (s *tcgeventdataSuite) TestActionEventDataEqual(c *C) {
+	data := &ActionEventData{Type: ActionReturnedInt19h, Str: "Returned INT 19h"}
+	c.Check(data.Equal(&ActionEventData{Type: ActionReturnedInt19h, Str: "Returned INT 19h"}), Equals, true)
+	c.Check(data.Equal(&ActionEventData{Type: ActionCallingInt19h, Str: "Calling INT 19h"}), Equals, false)
+	c.Check(data.Equal(StringEventData("Returned INT 19h")), Equals, false)
+}
+
+func (s *tcgeventdataSuite) TestSeparatorEventDataFromLog(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	n := 0
+	for _, event := range log.Events {
+		if event.EventType != EventTypeSeparator {
+			continue
+		}
+		n++
+
+		data, ok := event.Data.(*SeparatorEventData)
+		c.Assert(ok, Equals, true)
+		c.Check(data.Value, Equals, SeparatorEventNormalValue)
+		c.Check(data.IsError(), Equals, false)
+		c.Check(data.Bytes(), DeepEquals, []byte{0x00, 0x00, 0x00, 0x00})
+	}
+	c.Check(n, Equals, 8)
+}
+
+func (s *tcgeventdataSuite) TestEventActionDataNotAction(c *C) {
+	event := &Event{
+		PCRIndex:  7,
+		EventType: EventTypeEFIAction,
+		Data:      StringEventData("Calling EFI Application from Boot Option")}
+	c.Check(event.ActionData(), IsNil)
+}
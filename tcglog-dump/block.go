@@ -19,6 +19,7 @@ import (
 type blockFormatter struct {
 	dst io.Writer
 
+	category   bool
 	verbosity  int
 	hexdump    bool
 	varHexdump bool
@@ -29,6 +30,9 @@ func (*blockFormatter) printHeader() {}
 func (f *blockFormatter) printEvent(event *tcglog.Event) {
 	fmt.Fprintf(f.dst, "\nPCR: %d\n", event.PCRIndex)
 	fmt.Fprintf(f.dst, "TYPE: %s\n", event.EventType)
+	if f.category {
+		fmt.Fprintf(f.dst, "CATEGORY: %s\n", event.Category())
+	}
 	for _, alg := range []tpm2.HashAlgorithmId{
 		tpm2.HashAlgorithmSHA1,
 		tpm2.HashAlgorithmSHA256,
@@ -65,9 +69,10 @@ func (f *blockFormatter) printEvent(event *tcglog.Event) {
 
 func (*blockFormatter) flush() {}
 
-func newBlockFormatter(f *os.File, verbosity int, hexdump, varHexdump bool) formatter {
+func newBlockFormatter(f *os.File, category bool, verbosity int, hexdump, varHexdump bool) formatter {
 	return &blockFormatter{
 		dst:        f,
+		category:   category,
 		verbosity:  verbosity,
 		hexdump:    hexdump,
 		varHexdump: varHexdump}
@@ -0,0 +1,38 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"github.com/canonical/tcglog-parser/internal/check"
+)
+
+type checkCommand struct {
+	check.Options
+
+	Strict       bool `long:"strict" description:"Enable all optional checks, and fail if any event data fails to decode correctly. Equivalent to --check-separators --check-event-order"`
+	Grub         bool `long:"grub" description:"Equivalent to --with-grub"`
+	EFIBootQuirk bool `long:"efi-boot-quirk" description:"Equivalent to --efi-variable-boot-quirk"`
+
+	Positional struct {
+		LogPath string `positional-arg-name:"log-path"`
+	} `positional-args:"true"`
+}
+
+func (c *checkCommand) Execute(args []string) error {
+	opts := c.Options
+	if c.Strict {
+		opts.CheckSeparators = true
+		opts.CheckEventOrder = true
+		opts.IgnoreDataDecodeErrors = false
+	}
+	if c.Grub {
+		opts.WithGrub = true
+	}
+	if c.EFIBootQuirk {
+		opts.EfiVariableBootQuirk = true
+	}
+
+	return check.Run(&opts, c.Positional.LogPath)
+}
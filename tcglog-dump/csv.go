@@ -0,0 +1,76 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/canonical/go-tpm2"
+
+	internal_flags "github.com/canonical/tcglog-parser/internal/flags"
+)
+
+type csvCommand struct {
+	Algs []internal_flags.HashAlgorithmId `long:"alg" description:"Display digests for the specified bank. Can be specified multiple times. Defaults to all banks in the log" choice:"sha1" choice:"sha256" choice:"sha384" choice:"sha512"`
+	Pcrs internal_flags.PCRRange          `short:"p" long:"pcrs" description:"Display events associated with the specified PCRs. Can be specified multiple times"`
+
+	Positional struct {
+		LogPath string `positional-arg-name:"log-path"`
+	} `positional-args:"true"`
+}
+
+func (c *csvCommand) Execute(args []string) error {
+	log, err := readLog(c.Positional.LogPath)
+	if err != nil {
+		return err
+	}
+
+	algs := log.Algorithms
+	if len(c.Algs) > 0 {
+		algs = nil
+		for _, alg := range c.Algs {
+			if !log.Algorithms.Contains(tpm2.HashAlgorithmId(alg)) {
+				return fmt.Errorf("the log does not contain entries for the %v digest algorithm", tpm2.HashAlgorithmId(alg))
+			}
+			algs = append(algs, tpm2.HashAlgorithmId(alg))
+		}
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"INDEX", "PCR", "TYPE", "ALGORITHM", "DIGEST", "DETAILS"}); err != nil {
+		return err
+	}
+
+	for i, event := range log.Events {
+		if len(c.Pcrs) > 0 && !c.Pcrs.Contains(event.PCRIndex) {
+			continue
+		}
+
+		details := (&tableStringer{eventDetailsStringer(event, false)}).String()
+
+		// Emit one row for each bank.
+		for _, alg := range algs {
+			digest, ok := event.Digests[alg]
+			if !ok {
+				continue
+			}
+			if err := w.Write([]string{
+				strconv.Itoa(i),
+				strconv.FormatUint(uint64(event.PCRIndex), 10),
+				event.EventType.String(),
+				fmt.Sprintf("%v", alg),
+				fmt.Sprintf("%x", digest),
+				details}); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -7,7 +7,6 @@ package main
 import (
 	"bytes"
 	"crypto/sha1"
-	"crypto/x509"
 	"encoding/binary"
 	"fmt"
 	"strings"
@@ -83,9 +82,10 @@ func (s *boolVariableStringer) String() string {
 }
 
 type dbVariableStringer struct {
-	desc    varDescriptor
-	data    []byte
-	verbose bool
+	desc        varDescriptor
+	description string
+	data        []byte
+	verbose     bool
 }
 
 func (s *dbVariableStringer) String() string {
@@ -95,6 +95,9 @@ func (s *dbVariableStringer) String() string {
 	}
 
 	str := fmt.Sprintf("%s:", s.desc)
+	if s.verbose && s.description != "" {
+		str = fmt.Sprintf("%s (%s):", s.desc, s.description)
+	}
 
 	counts := make(map[efi.GUID]int)
 	for _, l := range db {
@@ -119,34 +122,27 @@ func (s *dbVariableStringer) String() string {
 
 type variableAuthorityStringer struct {
 	desc    varDescriptor
-	data    []byte
+	data    *tcglog.EFIVariableData
 	verbose bool
 }
 
 func (s *variableAuthorityStringer) String() string {
-	var owner efi.GUID
-	data := s.data[copy(owner[:], s.data):]
-
-	switch len(s.data) {
-	case 36, 44, 48, 64, 80:
-		return fmt.Sprintf("hash: %x, owner: %s, source: %s", data, owner, s.desc)
-	default:
-		cert, err := x509.ParseCertificate(data)
-		if err != nil {
-			// Shim doesn't log a EFI_SIGNATURE_DATA when doing verification
-			// with its vendor cert.
-			cert, err = x509.ParseCertificate(s.data)
-			if err != nil {
-				return fmt.Sprintf("Invalid authority event for %s - not a hash or X509 certificate: %v", s.desc, err)
-			}
-			if !s.verbose {
-				return fmt.Sprintf("subject: \"%s\", source: %s", cert.Subject, s.desc)
-			}
-			return fmt.Sprintf("subject: \"%s\", fingerprint: %x, source: %s", cert.Subject, sha1.Sum(cert.Raw), s.desc)
-		}
+	owner, cert, hash, err := s.data.Authority()
+	switch {
+	case err != nil:
+		return fmt.Sprintf("Invalid authority event for %s - not a hash or X509 certificate: %v", s.desc, err)
+	case cert == nil:
+		return fmt.Sprintf("hash: %x, owner: %s, source: %s", hash, owner, s.desc)
+	case owner == efi.GUID{}:
+		// Shim doesn't log a EFI_SIGNATURE_DATA when doing verification
+		// with its vendor cert.
 		if !s.verbose {
-			return fmt.Sprintf("subject: \"%s\", owner: %s, source: %s", cert.Subject, owner, s.desc)
+			return fmt.Sprintf("subject: \"%s\", source: %s", cert.Subject, s.desc)
 		}
+		return fmt.Sprintf("subject: \"%s\", fingerprint: %x, source: %s", cert.Subject, sha1.Sum(cert.Raw), s.desc)
+	case !s.verbose:
+		return fmt.Sprintf("subject: \"%s\", owner: %s, source: %s", cert.Subject, owner, s.desc)
+	default:
 		return fmt.Sprintf("subject: \"%s\", fingerprint: %x, owner: %s, source: %s", cert.Subject, sha1.Sum(cert.Raw), owner, s.desc)
 	}
 }
@@ -197,7 +193,8 @@ func customEventDetailsStringer(event *tcglog.Event, verbose bool) fmt.Stringer
 				return &boolVariableStringer{varDescriptor{Name: varData.UnicodeName, GUID: varData.VariableName}, varData.VariableData}
 			}
 		}
-		return &dbVariableStringer{varDescriptor{Name: varData.UnicodeName, GUID: varData.VariableName}, varData.VariableData, verbose}
+		description, _ := varData.SignatureDatabaseDescription()
+		return &dbVariableStringer{varDescriptor{Name: varData.UnicodeName, GUID: varData.VariableName}, description, varData.VariableData, verbose}
 	case event.EventType == tcglog.EventTypeEFIVariableAuthority:
 		varData, ok := event.Data.(*tcglog.EFIVariableData)
 		if !ok {
@@ -213,7 +210,7 @@ func customEventDetailsStringer(event *tcglog.Event, verbose bool) fmt.Stringer
 			}
 		}
 
-		return &variableAuthorityStringer{varDescriptor{Name: varData.UnicodeName, GUID: varData.VariableName}, varData.VariableData, verbose}
+		return &variableAuthorityStringer{varDescriptor{Name: varData.UnicodeName, GUID: varData.VariableName}, varData, verbose}
 	case event.EventType == tcglog.EventTypeEFIGPTEvent && !verbose:
 		data, ok := event.Data.(*tcglog.EFIGPTData)
 		if !ok {
@@ -247,11 +244,32 @@ func eventDetailsStringer(event *tcglog.Event, verbose bool) fmt.Stringer {
 	case *tcglog.GrubStringEventData:
 		return d
 	case tcglog.OpaqueEventData:
+		return event.OpaqueData()
+	case *tcglog.CPUMicrocodeEventData:
+		return d
+	case *tcglog.NonhostConfigEventData:
+		return d
+	case *tcglog.NonhostInfoEventData:
+		return d
+	case *tcglog.IPLPartitionEventData:
+		return d
+	case *tcglog.TaggedEventData:
+		return d
+	case *tcglog.PlatformConfigFlagsEventData:
 		return d
 	case tcglog.StringEventData:
+		if action := event.ActionData(); action != nil {
+			return action
+		}
+		return d
+	case *tcglog.TableOfDevicesEventData:
 		return d
 	case *tcglog.SystemdEFIStubCommandline:
 		return d
+	case *tcglog.SystemdBootEventData:
+		return d
+	case *tcglog.SIPAEventData:
+		return d
 	default:
 		if verbose {
 			return event.Data
@@ -20,17 +20,16 @@ import (
 type options struct {
 	Alg                internal_flags.HashAlgorithmId `long:"alg" description:"Hash algorithm to display" default:"auto" choice:"auto" choice:"sha1" choice:"sha256" choice:"sha384" choice:"sha512"`
 	Verbose            []bool                         `short:"v" long:"verbose" description:"Display summary of event data"`
+	Category           bool                           `long:"category" description:"Display a human readable category for each event"`
 	Hexdump            bool                           `long:"hexdump" description:"Display hexdump of event data associated with each event"`
 	VarHexdump         bool                           `long:"varhexdump" description:"Display hexdump of variable data for events associated with the measurement of EFI variables"`
 	ExtractData        string                         `long:"extract-data" description:"Extract event data associated with each event to individual files named with the supplied prefix (format: <prefix>-<num>)" optional:"true" optional-value:"data"`
 	ExtractVars        string                         `long:"extract-vars" description:"Extract variable data for events associated with the measurement of EFI variables to individual files named with the supplied prefix (format: <prefix>-<num>)" optional:"true" optional-value:"var"`
 	WithGrub           bool                           `long:"with-grub" description:"Decode event data measured by GRUB to PCRs 8 and 9"`
 	WithSystemdEFIStub *tcglog.PCRIndex               `long:"with-systemd-efi-stub" description:"Decode event data measured by systemd's EFI stub Linux loader to the specified PCR" optional:"true" optional-value:"8"`
+	WithSystemdBoot    bool                           `long:"with-systemd-boot" description:"Decode EV_EVENT_TAG event data measured by systemd-boot and systemd's EFI stub Linux loader"`
+	WithWindowsSIPA    bool                           `long:"with-windows-sipa" description:"Decode EV_EVENT_TAG event data measured by Windows"`
 	Pcrs               internal_flags.PCRRange        `short:"p" long:"pcrs" description:"Display events associated with the specified PCRs. Can be specified multiple times"`
-
-	Positional struct {
-		LogPath string `positional-arg-name:"log-path"`
-	} `positional-args:"true"`
 }
 
 var opts options
@@ -42,23 +41,18 @@ func shouldDisplayEvent(event *tcglog.Event) bool {
 	return opts.Pcrs.Contains(event.PCRIndex)
 }
 
-func run() error {
-	if _, err := flags.Parse(&opts); err != nil {
-		return err
-	}
-
-	path := opts.Positional.LogPath
+func readLog(path string) (*tcglog.Log, error) {
 	if path == "" {
 		path = "/sys/kernel/security/tpm0/binary_bios_measurements"
 	}
 
 	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 
-	logOpts := tcglog.LogOptions{EnableGrub: opts.WithGrub}
+	logOpts := tcglog.LogOptions{EnableGrub: opts.WithGrub, EnableSystemdBoot: opts.WithSystemdBoot, EnableWindowsSIPA: opts.WithWindowsSIPA}
 	if opts.WithSystemdEFIStub != nil {
 		logOpts.EnableSystemdEFIStub = true
 		logOpts.SystemdEFIStubPCR = *opts.WithSystemdEFIStub
@@ -66,7 +60,51 @@ func run() error {
 
 	log, err := tcglog.ReadLog(f, &logOpts)
 	if err != nil {
-		return fmt.Errorf("cannot read log: %v", err)
+		return nil, fmt.Errorf("cannot read log: %v", err)
+	}
+
+	return log, nil
+}
+
+func run() error {
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.Usage = "[OPTIONS] [log-path]"
+	parser.SubcommandsOptional = true
+	if _, err := parser.AddCommand("pcrs", "Display PCR values computed from the log",
+		"Replay the events in the log and display the resulting PCR values for each bank", &pcrsCommand{}); err != nil {
+		return err
+	}
+	if _, err := parser.AddCommand("csv", "Export the events in the log as CSV",
+		"Display the events in the log in CSV format, with one row for each bank of each event", &csvCommand{}); err != nil {
+		return err
+	}
+	if _, err := parser.AddCommand("check", "Check the log for problems",
+		"Perform the same checks as tcglog-check on the log, printing details of each problem that is found and "+
+			"exiting with a non-zero status if any of the checks fail", &checkCommand{}); err != nil {
+		return err
+	}
+
+	args, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+	if parser.Active != nil {
+		// The subcommand has already been executed.
+		return nil
+	}
+
+	var path string
+	switch len(args) {
+	case 0:
+	case 1:
+		path = args[0]
+	default:
+		return errors.New("too many arguments")
+	}
+
+	log, err := readLog(path)
+	if err != nil {
+		return err
 	}
 
 	alg := tpm2.HashAlgorithmId(opts.Alg)
@@ -85,12 +123,12 @@ func run() error {
 	var formatter formatter
 	if len(opts.Verbose) < 2 && !opts.Hexdump && !opts.VarHexdump {
 		var err error
-		formatter, err = newTableFormatter(os.Stdout, alg, len(opts.Verbose) > 0)
+		formatter, err = newTableFormatter(os.Stdout, alg, opts.Category, len(opts.Verbose) > 0)
 		if err != nil {
 			return err
 		}
 	} else {
-		formatter = newBlockFormatter(os.Stdout, len(opts.Verbose), opts.Hexdump, opts.VarHexdump)
+		formatter = newBlockFormatter(os.Stdout, opts.Category, len(opts.Verbose), opts.Hexdump, opts.VarHexdump)
 	}
 
 	formatter.printHeader()
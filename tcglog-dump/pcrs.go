@@ -0,0 +1,90 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/canonical/go-tpm2"
+
+	"github.com/canonical/tcglog-parser"
+	internal_flags "github.com/canonical/tcglog-parser/internal/flags"
+)
+
+type pcrsCommand struct {
+	Algs []internal_flags.HashAlgorithmId `long:"alg" description:"Display PCR values for the specified bank. Can be specified multiple times. Defaults to all banks in the log" choice:"sha1" choice:"sha256" choice:"sha384" choice:"sha512"`
+	Pcrs internal_flags.PCRRange          `short:"p" long:"pcrs" description:"Display the values of the specified PCRs. Can be specified multiple times. Defaults to all PCRs extended by the log"`
+
+	Format string `long:"format" description:"Output format. The tpm2-tools format displays each value as <bank>:<pcr>:0x<digest>, for comparison with the output of tpm2_pcrread" choice:"table" choice:"tpm2-tools" default:"table"`
+
+	Positional struct {
+		LogPath string `positional-arg-name:"log-path"`
+	} `positional-args:"true"`
+}
+
+func (c *pcrsCommand) Execute(args []string) error {
+	log, err := readLog(c.Positional.LogPath)
+	if err != nil {
+		return err
+	}
+
+	algs := log.Algorithms
+	if len(c.Algs) > 0 {
+		algs = nil
+		for _, alg := range c.Algs {
+			if !log.Algorithms.Contains(tpm2.HashAlgorithmId(alg)) {
+				return fmt.Errorf("the log does not contain entries for the %v digest algorithm", tpm2.HashAlgorithmId(alg))
+			}
+			algs = append(algs, tpm2.HashAlgorithmId(alg))
+		}
+	}
+
+	values, err := log.ReplayPCRs(algs)
+	if err != nil {
+		return fmt.Errorf("cannot replay log: %v", err)
+	}
+
+	pcrs := c.Pcrs
+	if len(pcrs) == 0 {
+		for pcr := range values {
+			pcrs = append(pcrs, pcr)
+		}
+		sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+	}
+
+	digest := func(pcr tcglog.PCRIndex, alg tpm2.HashAlgorithmId) tcglog.Digest {
+		digest, ok := values[pcr][alg]
+		if !ok {
+			// This PCR isn't extended by any events in the log.
+			digest = make(tcglog.Digest, alg.Size())
+		}
+		return digest
+	}
+
+	if c.Format == "tpm2-tools" {
+		for _, alg := range algs {
+			name, err := internal_flags.HashAlgorithmId(alg).MarshalFlag()
+			if err != nil {
+				return err
+			}
+			for _, pcr := range pcrs {
+				fmt.Printf("%s:%d:0x%X\n", name, pcr, digest(pcr, alg))
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "PCR\tBANK\tDIGEST\n")
+	for _, pcr := range pcrs {
+		for _, alg := range algs {
+			fmt.Fprintf(w, "%d\t%v\t%x\n", pcr, alg, digest(pcr, alg))
+		}
+	}
+	return w.Flush()
+}
@@ -76,12 +76,16 @@ func (s *tableStringer) String() string {
 type tableFormatter struct {
 	dst *tabwriter.Writer
 
-	alg     tpm2.HashAlgorithmId
-	verbose bool
+	alg      tpm2.HashAlgorithmId
+	category bool
+	verbose  bool
 }
 
 func (f *tableFormatter) printHeader() {
 	fmt.Fprint(f.dst, "PCR\tDIGEST\tTYPE")
+	if f.category {
+		fmt.Fprint(f.dst, "\tCATEGORY")
+	}
 	if f.verbose {
 		fmt.Fprint(f.dst, "\tDETAILS")
 	}
@@ -90,6 +94,9 @@ func (f *tableFormatter) printHeader() {
 
 func (f *tableFormatter) printEvent(event *tcglog.Event) {
 	fmt.Fprintf(f.dst, "%d\t%x\t%s", event.PCRIndex, event.Digests[f.alg], event.EventType)
+	if f.category {
+		fmt.Fprintf(f.dst, "\t%s", event.Category())
+	}
 	if f.verbose {
 		fmt.Fprintf(f.dst, "\t%s", &tableStringer{eventDetailsStringer(event, false)})
 	}
@@ -100,7 +107,7 @@ func (f *tableFormatter) flush() {
 	f.dst.Flush()
 }
 
-func newTableFormatter(f *os.File, alg tpm2.HashAlgorithmId, verbose bool) (formatter, error) {
+func newTableFormatter(f *os.File, alg tpm2.HashAlgorithmId, category, verbose bool) (formatter, error) {
 	var w io.Writer = f
 
 	sz, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
@@ -114,7 +121,8 @@ func newTableFormatter(f *os.File, alg tpm2.HashAlgorithmId, verbose bool) (form
 	}
 
 	return &tableFormatter{
-		dst:     tabwriter.NewWriter(w, 0, 0, 2, ' ', 0),
-		alg:     alg,
-		verbose: verbose}, nil
+		dst:      tabwriter.NewWriter(w, 0, 0, 2, ' ', 0),
+		alg:      alg,
+		category: category,
+		verbose:  verbose}, nil
 }
@@ -5,10 +5,16 @@
 package tcglog_test
 
 import (
+	"bytes"
 	"encoding/hex"
+	"os"
 	"testing"
 
+	"github.com/canonical/go-tpm2"
+
 	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -18,3 +24,44 @@ func decodeHexString(c *C, str string) []byte {
 	c.Assert(err, IsNil)
 	return b
 }
+
+func readTestLog(c *C, options *LogOptions) *Log {
+	f, err := os.Open("testdata/binary_bios_measurements")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	log, err := ReadLog(f, options)
+	c.Assert(err, IsNil)
+	return log
+}
+
+// readTestEvent serializes an event with the supplied PCR index, type, SHA-1 digest and
+// data, and decodes it again with ReadEvent. A nil digest is serialized as a zero digest.
+func readTestEvent(c *C, options *LogOptions, pcrIndex PCRIndex, eventType EventType, digest Digest, data []byte) *Event {
+	if digest == nil {
+		digest = make(Digest, tpm2.HashAlgorithmSHA1.Size())
+	}
+	event := &Event{
+		PCRIndex:  pcrIndex,
+		EventType: eventType,
+		Digests:   DigestMap{tpm2.HashAlgorithmSHA1: digest},
+		Data:      OpaqueEventData(data)}
+	w := new(bytes.Buffer)
+	c.Assert(event.Write(w), IsNil)
+
+	event, err := ReadEvent(w, options)
+	c.Assert(err, IsNil)
+	return event
+}
+
+// readTestLogFromEvents serializes a log containing the supplied events, and decodes it
+// again with ReadLog.
+func readTestLogFromEvents(c *C, options *LogOptions, events ...*Event) *Log {
+	w := new(bytes.Buffer)
+	c.Assert(NewLogForTesting(events).Write(w), IsNil)
+
+	log, err := ReadLog(w, options)
+	c.Assert(err, IsNil)
+	c.Assert(log.Events, HasLen, len(events))
+	return log
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+
+	"github.com/canonical/go-efilib"
+
+	"golang.org/x/xerrors"
+)
+
+// AuthorityTrust describes the result of verifying an authority measured by an
+// EV_EFI_VARIABLE_AUTHORITY event.
+type AuthorityTrust struct {
+	EventIndex  int               // The index of the event in the log
+	Source      string            // The name of the variable that the authority was obtained from, eg, "db"
+	Owner       efi.GUID          // The signature owner of the authority
+	Certificate *x509.Certificate // The authority's certificate, or nil if it is an image digest
+	Digest      Digest            // The authority's image digest, or nil if it is a certificate
+
+	InDB    bool // The authority is present in the measured db
+	Revoked bool // The authority is revoked by the measured dbx
+}
+
+// TrustReport describes the result of verifying the secure boot authorities measured
+// to a log.
+type TrustReport struct {
+	Authorities []AuthorityTrust
+}
+
+// Trusted indicates whether every authority that was verified by the firmware from
+// db is present in the measured db, and none of the measured authorities are revoked
+// by the measured dbx. Authorities obtained from other sources, such as shim's vendor
+// certificate or MokList, are managed by shim and are not expected to be in db.
+func (r *TrustReport) Trusted() bool {
+	for _, a := range r.Authorities {
+		if a.Revoked {
+			return false
+		}
+		if a.Source == "db" && !a.InDB {
+			return false
+		}
+	}
+	return true
+}
+
+func signatureDatabaseContainsEntry(db efi.SignatureDatabase, entry *efi.SignatureData) bool {
+	for _, list := range db {
+		for _, sig := range list.Signatures {
+			if sig.Equal(entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyBootChainTrust verifies the EV_EFI_VARIABLE_AUTHORITY events measured to PCR 7
+// in this log against the db and dbx signature databases measured to PCR 7. Each
+// authority that was obtained from db is checked for a matching entry in db, and each
+// authority is checked that it isn't revoked by a SHA-256 or X.509 entry in dbx.
+//
+// This does not verify that the images loaded during boot are signed by the measured
+// authorities - that is not possible from the log alone because the signatures aren't
+// measured.
+//
+// Events that measure shim's SbatLevel and MokSBState variables to PCR 7 are not
+// authorities and are ignored. An error is returned if db or dbx aren't measured, or
+// if any of the authority events cannot be decoded.
+func (l *Log) VerifyBootChainTrust() (*TrustReport, error) {
+	db, err := l.signatureDatabase("db", efi.ImageSecurityDatabaseGuid)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.signatureDatabase("dbx", efi.ImageSecurityDatabaseGuid); err != nil {
+		return nil, err
+	}
+
+	report := new(TrustReport)
+	for i, event := range l.Events {
+		if event.PCRIndex != 7 || event.EventType != EventTypeEFIVariableAuthority {
+			continue
+		}
+
+		data, ok := resolveEventData(event.Data).(*EFIVariableData)
+		if !ok {
+			return nil, xerrors.Errorf("authority event %d has unexpected event data type %T", i, event.Data)
+		}
+		switch data.UnicodeName {
+		case "SbatLevel", "MokSBState":
+			continue
+		}
+
+		owner, cert, hash, err := data.Authority()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode authority event %d: %w", i, err)
+		}
+
+		a := AuthorityTrust{
+			EventIndex:  i,
+			Source:      data.UnicodeName,
+			Owner:       owner,
+			Certificate: cert,
+			Digest:      hash}
+
+		var entry []byte
+		var revocationDigest Digest
+		switch {
+		case cert != nil:
+			entry = cert.Raw
+			h := sha256.Sum256(cert.Raw)
+			revocationDigest = h[:]
+		default:
+			entry = hash
+			if len(hash) == sha256.Size {
+				revocationDigest = hash
+			}
+		}
+
+		if data.VariableName == efi.ImageSecurityDatabaseGuid && data.UnicodeName == "db" {
+			a.InDB = signatureDatabaseContainsEntry(db, &efi.SignatureData{Owner: owner, Data: entry})
+		}
+		if revocationDigest != nil {
+			a.Revoked, err = l.DBXContains(revocationDigest)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		report.Authorities = append(report.Authorities, a)
+	}
+
+	return report, nil
+}
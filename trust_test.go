@@ -0,0 +1,102 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/canonical/go-efilib"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type trustSuite struct{}
+
+var _ = Suite(&trustSuite{})
+
+func (s *trustSuite) TestVerifyBootChainTrust(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	report, err := log.VerifyBootChainTrust()
+	c.Assert(err, IsNil)
+	c.Check(report.Trusted(), Equals, true)
+	c.Assert(report.Authorities, HasLen, 2)
+
+	a := report.Authorities[0]
+	c.Check(a.Source, Equals, "db")
+	c.Check(a.Owner, Equals, efi.MakeGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b}))
+	c.Assert(a.Certificate, NotNil)
+	c.Check(a.Certificate.Subject.CommonName, Equals, "Microsoft Corporation UEFI CA 2011")
+	c.Check(a.InDB, Equals, true)
+	c.Check(a.Revoked, Equals, false)
+	c.Check(log.Events[a.EventIndex].EventType, Equals, EventTypeEFIVariableAuthority)
+
+	a = report.Authorities[1]
+	c.Check(a.Source, Equals, "Shim")
+	c.Assert(a.Certificate, NotNil)
+	c.Check(a.Certificate.Subject.CommonName, Equals, "Canonical Ltd. Master Certificate Authority")
+	c.Check(a.InDB, Equals, false)
+	c.Check(a.Revoked, Equals, false)
+}
+
+func (s *trustSuite) TestVerifyBootChainTrustNotInDB(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for _, event := range log.Events {
+		data, ok := event.Data.(*EFIVariableData)
+		if !ok || event.EventType != EventTypeEFIVariableAuthority || data.UnicodeName != "db" {
+			continue
+		}
+		// Change the owner so that the authority doesn't match the db entry.
+		data.VariableData = append([]byte(nil), data.VariableData...)
+		data.VariableData[0] ^= 0xff
+	}
+
+	report, err := log.VerifyBootChainTrust()
+	c.Assert(err, IsNil)
+	c.Check(report.Trusted(), Equals, false)
+	c.Assert(report.Authorities, HasLen, 2)
+	c.Check(report.Authorities[0].InDB, Equals, false)
+}
+
+func (s *trustSuite) TestVerifyBootChainTrustRevoked(c *C) {
+	log := readTestLog(c, &LogOptions{})
+
+	// Replace the first SHA-256 entry in dbx with the digest of shim's
+	// vendor certificate.
+	report, err := log.VerifyBootChainTrust()
+	c.Assert(err, IsNil)
+	shimCert := report.Authorities[1].Certificate
+	digest := sha256.Sum256(shimCert.Raw)
+
+	for _, event := range log.Events {
+		data, ok := event.Data.(*EFIVariableData)
+		if !ok || event.EventType != EventTypeEFIVariableDriverConfig || data.UnicodeName != "dbx" {
+			continue
+		}
+		db, err := efi.ReadSignatureDatabase(bytes.NewReader(data.VariableData))
+		c.Assert(err, IsNil)
+		for _, l := range db {
+			if l.Type != efi.CertSHA256Guid {
+				continue
+			}
+			l.Signatures[0].Data = digest[:]
+		}
+		data.VariableData, err = db.Bytes()
+		c.Assert(err, IsNil)
+	}
+
+	report, err = log.VerifyBootChainTrust()
+	c.Assert(err, IsNil)
+	c.Check(report.Trusted(), Equals, false)
+	c.Check(report.Authorities[0].Revoked, Equals, false)
+	c.Check(report.Authorities[1].Revoked, Equals, true)
+}
+
+func (s *trustSuite) TestVerifyBootChainTrustNoDB(c *C) {
+	_, err := NewLogForTesting(nil).VerifyBootChainTrust()
+	c.Check(err, ErrorMatches, `db is not measured to PCR 7`)
+}
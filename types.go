@@ -5,6 +5,8 @@
 package tcglog
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 
 	"github.com/canonical/go-tpm2"
@@ -13,15 +15,116 @@ import (
 // PCRIndex corresponds to the index of a PCR on the TPM.
 type PCRIndex uint32
 
+// Usage returns a description of the conventional usage of this PCR, as defined
+// by the "TCG PC Client Platform Firmware Profile Specification". An empty string
+// is returned for indices that are outside of the range defined by the
+// specification.
+func (i PCRIndex) Usage() string {
+	switch {
+	case i == 0:
+		return "SRTM, BIOS, Host Platform Extensions, Embedded Option ROMs and PI Drivers"
+	case i == 1:
+		return "Host Platform Configuration"
+	case i == 2:
+		return "UEFI driver and application Code"
+	case i == 3:
+		return "UEFI driver and application Configuration and Data"
+	case i == 4:
+		return "UEFI Boot Manager Code and Boot Attempts"
+	case i == 5:
+		return "Boot Manager Code Configuration and Data and GPT/Partition Table"
+	case i == 6:
+		return "Host Platform Manufacturer Specific"
+	case i == 7:
+		return "Secure Boot Policy"
+	case i >= 8 && i <= 15:
+		return "Defined for use by the Static OS"
+	case i == 16:
+		return "Debug"
+	case i == 17:
+		return "DRTM and launch control policy"
+	case i == 18:
+		return "Trusted OS start-up code"
+	case i >= 19 && i <= 22:
+		return "Defined for use by the Trusted OS"
+	case i == 23:
+		return "Application Support"
+	default:
+		return ""
+	}
+}
+
 // EventType corresponds to the type of an event in an event log.
 type EventType uint32
 
 // Digest is the result of hashing some data.
 type Digest []byte
 
+// Equal indicates whether this digest is equal to other. The comparison is
+// performed in constant time with respect to the contents of the digests.
+func (d Digest) Equal(other Digest) bool {
+	return subtle.ConstantTimeCompare(d, other) == 1
+}
+
+// ErrDigestSizeMismatch is returned from functions that compare digests when the
+// digests have different sizes, which typically indicates that digests for different
+// algorithms are being compared.
+var ErrDigestSizeMismatch = errors.New("digest sizes don't match")
+
+// EqualChecked indicates whether this digest is equal to other, but returns an error
+// that wraps ErrDigestSizeMismatch if the digests have different sizes rather than
+// returning false, so that this can be distinguished from digests of the same size
+// with different values. The comparison is performed in constant time with respect
+// to the contents of the digests.
+func (d Digest) EqualChecked(other Digest) (bool, error) {
+	if len(d) != len(other) {
+		return false, fmt.Errorf("cannot compare %d byte digest with %d byte digest: %w", len(d), len(other), ErrDigestSizeMismatch)
+	}
+	return d.Equal(other), nil
+}
+
+// IsZeroDigest indicates whether the supplied digest is non-empty and contains only
+// zero bytes. EV_NO_ACTION events are recorded with digests of this form.
+func IsZeroDigest(d Digest) bool {
+	if len(d) == 0 {
+		return false
+	}
+	for _, b := range d {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ZeroDigest returns a digest of the size of the specified algorithm that contains
+// only zero bytes, which is the expected digest value for EV_NO_ACTION events. It
+// returns nil if the algorithm is not valid.
+func ZeroDigest(alg tpm2.HashAlgorithmId) Digest {
+	if !alg.IsValid() {
+		return nil
+	}
+	return make(Digest, alg.Size())
+}
+
 // DigestMap is a map of algorithms to digests.
 type DigestMap map[tpm2.HashAlgorithmId]Digest
 
+// Equal indicates whether this map contains the same algorithms as other,
+// with equal digests for each algorithm.
+func (m DigestMap) Equal(other DigestMap) bool {
+	if len(m) != len(other) {
+		return false
+	}
+	for alg, digest := range m {
+		otherDigest, ok := other[alg]
+		if !ok || !digest.Equal(otherDigest) {
+			return false
+		}
+	}
+	return true
+}
+
 func (e EventType) String() string {
 	switch e {
 	case EventTypePrebootCert:
@@ -92,11 +195,57 @@ func (e EventType) String() string {
 		return "EV_EFI_SPDM_FIRMWARE_BLOB"
 	case EventTypeEFISPDMFirmwareConfig:
 		return "EV_EFI_SPDM_FIRMWARE_CONFIG"
+	case EventTypeIMA:
+		return "IMA"
 	default:
 		return fmt.Sprintf("%08x", uint32(e))
 	}
 }
 
+var (
+	// specBIOS_1_21 corresponds to revision 1.21 of the "TCG PC Client Specific
+	// Implementation Specification for Conventional BIOS".
+	specBIOS_1_21 = Spec{PlatformType: PlatformTypeBIOS, Major: 1, Minor: 2, Errata: 1}
+
+	// specEFI_1_2 corresponds to the "TCG EFI Platform Specification For TPM Family 1.1
+	// or 1.2".
+	specEFI_1_2 = Spec{PlatformType: PlatformTypeEFI, Major: 1, Minor: 2}
+
+	// specEFI_2 corresponds to the first revision of the "TCG PC Client Platform Firmware
+	// Profile Specification".
+	specEFI_2 = Spec{PlatformType: PlatformTypeEFI, Major: 2}
+
+	// specEFI_2_rev105 corresponds to revision 1.05 of the "TCG PC Client Platform Firmware
+	// Profile Specification".
+	specEFI_2_rev105 = Spec{PlatformType: PlatformTypeEFI, Major: 2, Errata: 2}
+)
+
+// MinSpec returns the earliest specification that defines this event type. Event types
+// that aren't specific to EFI platforms are defined for all platform types, and for
+// these this returns the conventional BIOS specification that defines them. This returns
+// false for event types that aren't defined by any known specification.
+func (e EventType) MinSpec() (Spec, bool) {
+	switch e {
+	case EventTypePrebootCert, EventTypePostCode, EventTypeNoAction, EventTypeSeparator, EventTypeAction,
+		EventTypeEventTag, EventTypeSCRTMContents, EventTypeSCRTMVersion, EventTypeCPUMicrocode,
+		EventTypePlatformConfigFlags, EventTypeTableOfDevices, EventTypeCompactHash, EventTypeIPL,
+		EventTypeIPLPartitionData, EventTypeNonhostCode, EventTypeNonhostConfig, EventTypeNonhostInfo,
+		EventTypeOmitBootDeviceEvents:
+		return specBIOS_1_21, true
+	case EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIBootServicesApplication,
+		EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver, EventTypeEFIGPTEvent,
+		EventTypeEFIAction, EventTypeEFIPlatformFirmwareBlob, EventTypeEFIHandoffTables:
+		return specEFI_1_2, true
+	case EventTypeEFIPlatformFirmwareBlob2, EventTypeEFIHandoffTables2, EventTypeEFIVariableBoot2,
+		EventTypeEFIHCRTMEvent, EventTypeEFIVariableAuthority:
+		return specEFI_2, true
+	case EventTypeEFISPDMFirmwareBlob, EventTypeEFISPDMFirmwareConfig:
+		return specEFI_2_rev105, true
+	default:
+		return Spec{}, false
+	}
+}
+
 func (e EventType) Format(s fmt.State, f rune) {
 	switch f {
 	case 's', 'v':
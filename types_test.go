@@ -0,0 +1,109 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog_test
+
+import (
+	"github.com/canonical/go-tpm2"
+
+	"golang.org/x/xerrors"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/canonical/tcglog-parser"
+)
+
+type typesSuite struct{}
+
+var _ = Suite(&typesSuite{})
+
+func (s *typesSuite) TestPCRIndexUsage(c *C) {
+	c.Check(PCRIndex(0).Usage(), Equals, "SRTM, BIOS, Host Platform Extensions, Embedded Option ROMs and PI Drivers")
+	c.Check(PCRIndex(4).Usage(), Equals, "UEFI Boot Manager Code and Boot Attempts")
+	c.Check(PCRIndex(7).Usage(), Equals, "Secure Boot Policy")
+	c.Check(PCRIndex(12).Usage(), Equals, "Defined for use by the Static OS")
+	c.Check(PCRIndex(20).Usage(), Equals, "Defined for use by the Trusted OS")
+	c.Check(PCRIndex(24).Usage(), Equals, "")
+}
+
+func (s *typesSuite) TestDigestEqual(c *C) {
+	c.Check(Digest{1, 2, 3}.Equal(Digest{1, 2, 3}), Equals, true)
+	c.Check(Digest{1, 2, 3}.Equal(Digest{1, 2, 4}), Equals, false)
+	c.Check(Digest{1, 2, 3}.Equal(Digest{1, 2}), Equals, false)
+	c.Check(Digest(nil).Equal(Digest{}), Equals, true)
+}
+
+func (s *typesSuite) TestDigestMapEqual(c *C) {
+	a := DigestMap{tpm2.HashAlgorithmSHA1: Digest{1}, tpm2.HashAlgorithmSHA256: Digest{2}}
+	c.Check(a.Equal(DigestMap{tpm2.HashAlgorithmSHA256: Digest{2}, tpm2.HashAlgorithmSHA1: Digest{1}}), Equals, true)
+	c.Check(a.Equal(DigestMap{tpm2.HashAlgorithmSHA1: Digest{1}, tpm2.HashAlgorithmSHA256: Digest{3}}), Equals, false)
+	c.Check(a.Equal(DigestMap{tpm2.HashAlgorithmSHA1: Digest{1}}), Equals, false)
+	c.Check(a.Equal(DigestMap{tpm2.HashAlgorithmSHA1: Digest{1}, tpm2.HashAlgorithmSHA384: Digest{2}}), Equals, false)
+}
+
+func (s *typesSuite) TestIsZeroDigest(c *C) {
+	c.Check(IsZeroDigest(make(Digest, 20)), Equals, true)
+	c.Check(IsZeroDigest(Digest{0, 0, 1, 0}), Equals, false)
+	c.Check(IsZeroDigest(nil), Equals, false)
+}
+
+func (s *typesSuite) TestZeroDigest(c *C) {
+	c.Check(ZeroDigest(tpm2.HashAlgorithmSHA1), DeepEquals, make(Digest, 20))
+	c.Check(ZeroDigest(tpm2.HashAlgorithmSHA256), DeepEquals, make(Digest, 32))
+	c.Check(ZeroDigest(0x1234), IsNil)
+}
+
+func (s *typesSuite) TestNoActionEventsHaveZeroDigests(c *C) {
+	log := readTestLog(c, &LogOptions{})
+	for i, event := range log.Events {
+		if event.EventType != EventTypeNoAction {
+			continue
+		}
+		for alg, digest := range event.Digests {
+			c.Check(IsZeroDigest(digest), Equals, true, Commentf("event %d", i))
+			c.Check(digest, DeepEquals, ZeroDigest(alg), Commentf("event %d", i))
+		}
+	}
+}
+
+func (s *typesSuite) TestEventTypeMinSpec(c *C) {
+	for _, t := range []struct {
+		eventType EventType
+		spec      Spec
+	}{
+		{eventType: EventTypeSeparator, spec: Spec{PlatformType: PlatformTypeBIOS, Major: 1, Minor: 2, Errata: 1}},
+		{eventType: EventTypeOmitBootDeviceEvents, spec: Spec{PlatformType: PlatformTypeBIOS, Major: 1, Minor: 2, Errata: 1}},
+		{eventType: EventTypeEFIVariableBoot, spec: Spec{PlatformType: PlatformTypeEFI, Major: 1, Minor: 2}},
+		{eventType: EventTypeEFIHandoffTables, spec: Spec{PlatformType: PlatformTypeEFI, Major: 1, Minor: 2}},
+		{eventType: EventTypeEFIHCRTMEvent, spec: Spec{PlatformType: PlatformTypeEFI, Major: 2}},
+		{eventType: EventTypeEFIVariableAuthority, spec: Spec{PlatformType: PlatformTypeEFI, Major: 2}},
+		{eventType: EventTypeEFISPDMFirmwareBlob, spec: Spec{PlatformType: PlatformTypeEFI, Major: 2, Errata: 2}},
+	} {
+		spec, ok := t.eventType.MinSpec()
+		c.Check(ok, Equals, true, Commentf("%v", t.eventType))
+		c.Check(spec, Equals, t.spec, Commentf("%v", t.eventType))
+	}
+}
+
+func (s *typesSuite) TestEventTypeMinSpecUnknown(c *C) {
+	_, ok := EventType(0x8000ffff).MinSpec()
+	c.Check(ok, Equals, false)
+}
+
+func (s *typesSuite) TestDigestEqualChecked(c *C) {
+	a := decodeHexString(c, "9069ca78e7450a285173431b3e52c5c25299e473")
+	b := decodeHexString(c, "b2a83b0ebf2f8374299a5b2bdfc31ea955ad7236")
+
+	equal, err := Digest(a).EqualChecked(a)
+	c.Check(err, IsNil)
+	c.Check(equal, Equals, true)
+
+	equal, err = Digest(a).EqualChecked(b)
+	c.Check(err, IsNil)
+	c.Check(equal, Equals, false)
+
+	_, err = Digest(a).EqualChecked(make(Digest, 32))
+	c.Check(err, ErrorMatches, `cannot compare 20 byte digest with 32 byte digest: digest sizes don't match`)
+	c.Check(xerrors.Is(err, ErrDigestSizeMismatch), Equals, true)
+}